@@ -2,6 +2,8 @@ package core
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/gob"
 	"fmt"
 
@@ -17,6 +19,7 @@ type Header struct {
 	PrevBlockHash types.Hash // Hash do bloco anterior na rede
 	Timestamp     uint64     // Marca o tempo de criação do bloco.
 	Height        uint32     // Indica a posição do bloco na blockchain
+	ChainID       uint32     // Rede à qual o bloco pertence (ver GenesisConfig)
 }
 
 // O método bytes serializa o Header em bytes usando o formato gob,
@@ -29,6 +32,18 @@ func (h *Header) Bytes() []byte {
 	return buf.Bytes()
 }
 
+// Retorna o hash assinado pelo validador do bloco, separado por domínio (ver signingDigest).
+func (h *Header) SigningHash() types.Hash {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, h.Version)
+	buf.Write(h.Datahash[:])
+	buf.Write(h.PrevBlockHash[:])
+	binary.Write(buf, binary.BigEndian, h.Timestamp)
+	binary.Write(buf, binary.BigEndian, h.Height)
+
+	return signingDigest(headerSigningTag, h.ChainID, buf.Bytes())
+}
+
 // Block: Estrutura que representa um bloco.
 // O bloco contém um cabeçalho embutido (*Header), permitindo acesso direto aos seus campos:
 // Transactions, Validator, Signature e Hash
@@ -49,12 +64,35 @@ func (b *Block) AddTransaction(tx *Transaction) {
 	b.Transactions = append(b.Transactions, *tx)
 }
 
+// Calcula o hash do conteúdo das transações (Header.Datahash): sha256 dos IDs das transações, em ordem.
+// Como o ID cobre a transação assinada inteira (ver TxHasher), o cabeçalho se compromete com todas elas.
+func CalculateDataHash(txx []Transaction) types.Hash {
+	h := sha256.New()
+	for i := range txx {
+		id := TxHasher{}.Hash(&txx[i])
+		h.Write(id[:])
+	}
+	return types.HashFromBytes(h.Sum(nil))
+}
+
+// Verifica se o Datahash do cabeçalho corresponde às transações do bloco.
+func (b *Block) VerifyDataHash() error {
+	if dataHash := CalculateDataHash(b.Transactions); dataHash != b.Datahash {
+		return fmt.Errorf("block (%s) has invalid data hash (%s), expected (%s)", b.Hash(BlockHasher{}), b.Datahash, dataHash)
+	}
+	return nil
+}
+
 // Assina um Bloco - assina o cabeçalho do bloco usando uma chave privada
-// privKey.Sign(b.HeaderData()): Gera a assinatura criptográfica
+// Antes de assinar, o Datahash é preenchido com as transações atuais do bloco (ver CalculateDataHash).
+// privKey.SignHash(b.Header.SigningHash()): Gera a assinatura criptográfica
 // b.Validator = privKey.PublicKey(): Armazena a chave pública do assinante
 // b.Signature = sig: Salva a assinatura gerada
 func (b *Block) Sign(privKey crypto.PrivateKey) error {
-	sig, err := privKey.Sign(b.Header.Bytes()) // Assina os dados do cabeçalho do bloco
+	b.Datahash = CalculateDataHash(b.Transactions)
+	b.hash = types.Hash{}
+
+	sig, err := privKey.SignHash(b.Header.SigningHash()) // Assina o hash do cabeçalho do bloco
 	if err != nil {
 		return err
 	}
@@ -65,8 +103,21 @@ func (b *Block) Sign(privKey crypto.PrivateKey) error {
 	return nil
 }
 
+// Define uma assinatura do bloco produzida fora do nó, como a assinatura agregada (MuSig) de um comitê
+// de validadores: validator é a chave agregada do comitê e a assinatura serve de certificado do bloco.
+func (b *Block) SetSignature(validator crypto.PublicKey, sig *crypto.Signature) error {
+	if sig == nil || !sig.VerifyHash(validator, b.Header.SigningHash()) {
+		return fmt.Errorf("signature does not match the block header")
+	}
+
+	b.Validator = validator
+	b.Signature = sig
+	return nil
+}
+
 // Verifica a assinatura de um bloco. Garante que o bloco foi assinado corretamente, verifica
 // a assinatura comparando com a chave pública do validador. Se não houver assinatura, ou a assinatura for inválida, um erro é retornado
+// O Datahash só é conferido depois que todas as transações foram verificadas.
 func (b *Block) Verify() error {
 	if b.Signature == nil {
 		return fmt.Errorf("block has no signature")
@@ -78,7 +129,11 @@ func (b *Block) Verify() error {
 		}
 	}
 
-	if !b.Signature.Verify(b.Validator, b.Header.Bytes()) {
+	if err := b.VerifyDataHash(); err != nil {
+		return err
+	}
+
+	if !b.Signature.VerifyHash(b.Validator, b.Header.SigningHash()) {
 		return fmt.Errorf("block has invalid signature")
 	}
 
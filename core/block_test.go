@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bytes"
 	"testing"
 	"time"
 
@@ -29,9 +30,33 @@ func TestVerifyBlock(t *testing.T) {
 	assert.NotNil(t, b.Verify())
 }
 
+func TestBlockVerifyDataHash(t *testing.T) {
+	b := randomBlockWithSignature(t, 1, types.RandomHash())
+	assert.Equal(t, CalculateDataHash(b.Transactions), b.Datahash)
+	assert.Nil(t, b.Verify())
+
+	// Trocar as transações depois da assinatura quebra o compromisso do cabeçalho.
+	b.Transactions = []Transaction{*randomTxWithSignature(t)}
+	assert.NotNil(t, b.Verify())
+}
+
+func TestBlockEncodeDecode(t *testing.T) {
+	b := randomBlockWithSignature(t, 1, types.RandomHash())
+	buf := &bytes.Buffer{}
+	assert.Nil(t, b.Encode(NewGobBlockEncoder(buf)))
+
+	bDecoded := new(Block)
+	assert.Nil(t, bDecoded.Decode(NewGobBlockDecoder(buf)))
+	assert.Equal(t, b.Header, bDecoded.Header)
+	assert.Equal(t, b.Transactions, bDecoded.Transactions)
+	assert.Nil(t, bDecoded.Verify())
+	assert.Equal(t, b.Hash(BlockHasher{}), bDecoded.Hash(BlockHasher{}))
+}
+
 func randomBlock(height uint32, prevBlockHash types.Hash) *Block {
 	header := &Header{
 		Version:       1,
+		Datahash:      CalculateDataHash(nil),
 		PrevBlockHash: prevBlockHash,
 		Height:        height,
 		Timestamp:     uint64(time.Now().UnixNano()),
@@ -48,3 +73,34 @@ func randomBlockWithSignature(t *testing.T, height uint32, prevBlockHash types.H
 
 	return b
 }
+
+func TestBlockSignatureBoundToChainID(t *testing.T) {
+	b := randomBlock(1, types.RandomHash())
+	b.ChainID = TestnetChainID
+	assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+	assert.Nil(t, b.Verify())
+
+	b.ChainID = MainnetChainID
+	assert.NotNil(t, b.Verify())
+}
+
+func TestBlockCommitCertificate(t *testing.T) {
+	privs := make([]crypto.PrivateKey, 4)
+	pubs := make([]crypto.PublicKey, 4)
+	for i := range privs {
+		key, err := crypto.GenerateSchemeKey(crypto.SchemeSchnorrP256)
+		assert.Nil(t, err)
+		privs[i], pubs[i] = key, key.PublicKey()
+	}
+	committee, err := crypto.AggregatePublicKeys(pubs)
+	assert.Nil(t, err)
+
+	b := randomBlock(0, types.Hash{})
+	sig := musigSignHash(t, committee, privs, b.Header.SigningHash())
+	assert.Nil(t, b.SetSignature(committee.Key, sig))
+	assert.Nil(t, b.Verify())
+
+	// O certificado vale apenas para o cabeçalho assinado.
+	other := randomBlock(1, types.Hash{})
+	assert.NotNil(t, other.SetSignature(committee.Key, sig))
+}
@@ -2,16 +2,32 @@ package core
 
 import (
 	"fmt"
+	"maps"
 	"sync"
 
+	"github.com/FelipePn10/fadden/types"
 	"github.com/sirupsen/logrus"
 )
 
+// ChainEvent: Mudança na cadeia canônica. Em um bloco novo na ponta, Added tem só esse bloco.
+// Em uma reorganização, Removed tem os blocos abandonados (do mais alto para o mais baixo)
+// e Added os blocos do novo ramo (do mais baixo para o mais alto).
+type ChainEvent struct {
+	Added   []*Block
+	Removed []*Block
+}
+
+// ChainEventHandler: Função chamada a cada mudança na cadeia canônica, fora do lock da blockchain.
+type ChainEventHandler func(ChainEvent)
+
 type Blockchain struct { // Estrutura que representa a blockchain.
-	store     Storage      // Armazenamento dos blocos
-	lock      sync.RWMutex //
-	headers   []*Header    // Slice contendo os headers dos blocos da blockchain
-	validator Validator    // Validação dos blocos antes de serem adicionados
+	store     Storage                  // Armazenamento dos blocos, inclusive os de ramos laterais
+	lock      sync.RWMutex             //
+	headers   []*Header                // Slice contendo os headers dos blocos da cadeia canônica
+	nonces    map[types.Address]uint64 // Próximo nonce esperado de cada remetente
+	validator Validator                // Validação dos blocos antes de serem adicionados
+	handlers  []ChainEventHandler      // Inscritos nos eventos da cadeia
+	chainID   uint32                   // Rede da blockchain, definida pelo bloco gênesis
 }
 
 // Inicializa o store indicando que os blocos serão armazenados em memória,
@@ -20,7 +36,9 @@ type Blockchain struct { // Estrutura que representa a blockchain.
 func NewBlockchain(genesis *Block) (*Blockchain, error) {
 	bc := &Blockchain{
 		headers: []*Header{},
+		nonces:  make(map[types.Address]uint64),
 		store:   NewMemoryStorage(),
+		chainID: genesis.ChainID,
 	}
 	bc.validator = NewBlockValidator(bc)
 
@@ -29,20 +47,53 @@ func NewBlockchain(genesis *Block) (*Blockchain, error) {
 	return bc, err
 }
 
+// Cria uma blockchain a partir da configuração do bloco gênesis.
+func NewBlockchainFromGenesis(g GenesisConfig) (*Blockchain, error) {
+	return NewBlockchain(g.Block())
+}
+
+// Retorna o identificador da rede da blockchain.
+func (bc *Blockchain) ChainID() uint32 {
+	return bc.chainID
+}
+
 func (bc *Blockchain) SetValidator(v Validator) {
 	bc.validator = v
 }
 
+// Registra uma função para receber os eventos da cadeia (ver ChainEvent).
+func (bc *Blockchain) Subscribe(h ChainEventHandler) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.handlers = append(bc.handlers, h)
+}
+
 // Primeiro o bloco passa pela validação via ValidateBlock, se for válido,
-// o bloco é adicionado à blockchain
+// o bloco é adicionado à blockchain.
+// Um bloco cujo pai é conhecido mas não é a ponta da cadeia vai para um ramo lateral
+// (ver addSideBlock), o que pode causar uma reorganização. Ele também passa pelo validador.
 func (bc *Blockchain) AddBlock(b *Block) error {
+	hash := b.Hash(BlockHasher{})
+	if _, err := bc.store.Get(hash); err == nil {
+		return fmt.Errorf("chain already contains block (%d) with hash (%s)", b.Height, hash)
+	}
+
 	if err := bc.validator.ValidateBlock(b); err != nil {
 		return err
 	}
 
+	if bc.isSideBlock(b) {
+		return bc.addSideBlock(b)
+	}
 	return bc.addBlockWiothoutValidation(b)
 }
 
+// Retorna o bloco com o hash informado, esteja ele na cadeia canônica ou em um ramo lateral.
+func (bc *Blockchain) GetBlock(hash types.Hash) (*Block, error) {
+	return bc.store.Get(hash)
+}
+
 // Retorna o header do bloco na altura especificada.
 // Se a altura informada for maior que a altura atual da blockchain, retorna erro
 func (bc *Blockchain) GetHeader(height uint32) (*Header, error) {
@@ -69,12 +120,212 @@ func (bc *Blockchain) Height() uint32 {
 	return uint32(len(bc.headers) - 1)
 }
 
+// Retorna o próximo nonce esperado para as transações do remetente,
+// ou seja, o número de transações dele já incluídas na blockchain.
+func (bc *Blockchain) Nonce(addr types.Address) uint64 {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return bc.nonces[addr]
+}
+
+// Verifica se o pai do bloco é conhecido mas não é a ponta da cadeia canônica.
+func (bc *Blockchain) isSideBlock(b *Block) bool {
+	if _, err := bc.store.Get(b.PrevBlockHash); err != nil {
+		return false
+	}
+
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return BlockHasher{}.Hash(bc.headers[len(bc.headers)-1]) != b.PrevBlockHash
+}
+
+// Verifica se o bloco está na cadeia canônica. Deve ser chamado com bc.lock travado.
+func (bc *Blockchain) isCanonical(b *Block) bool {
+	return b.Height < uint32(len(bc.headers)) && BlockHasher{}.Hash(bc.headers[b.Height]) == b.Hash(BlockHasher{})
+}
+
+// Guarda um bloco de um ramo lateral, já validado. Se o ramo ficar mais alto que a cadeia canônica,
+// ele passa a ser a cadeia canônica: os blocos desde o ancestral comum são trocados e os nonces recalculados.
+// Com ramos de mesma altura, fica o que foi visto primeiro.
+func (bc *Blockchain) addSideBlock(b *Block) error {
+	bc.lock.Lock()
+	if b.Height <= uint32(len(bc.headers)-1) {
+		bc.lock.Unlock()
+
+		logrus.WithFields(logrus.Fields{
+			"height": b.Height,
+			"hash":   b.Hash(BlockHasher{}),
+		}).Info("adding side chain block")
+		return bc.store.Put(b)
+	}
+
+	ev, err := bc.reorganize(b)
+	handlers := bc.handlers
+	bc.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"height":  b.Height,
+		"hash":    b.Hash(BlockHasher{}),
+		"added":   len(ev.Added),
+		"removed": len(ev.Removed),
+	}).Info("chain reorganization")
+
+	for _, h := range handlers {
+		h(ev)
+	}
+	return nil
+}
+
+// Volta pelo ramo que termina em tip até o ancestral comum com a cadeia canônica. Retorna os blocos
+// do ramo fora da cadeia canônica (do mais baixo para o mais alto) e a altura do ancestral comum.
+// Deve ser chamado com bc.lock travado.
+func (bc *Blockchain) branch(tip *Block) ([]*Block, uint32, error) {
+	var branch []*Block
+	for cur := tip; !bc.isCanonical(cur); {
+		branch = append([]*Block{cur}, branch...)
+		parent, err := bc.store.Get(cur.PrevBlockHash)
+		if err != nil {
+			return nil, 0, err
+		}
+		cur = parent
+	}
+	if len(branch) == 0 {
+		return nil, tip.Height, nil
+	}
+	return branch, branch[0].Height - 1, nil
+}
+
+// Calcula os nonces das contas ao fim do ramo, verificando que as transações do ramo seguem a
+// sequência de nonces a partir do ancestral comum. Deve ser chamado com bc.lock travado.
+func (bc *Blockchain) branchNonces(branch []*Block, ancestor uint32) (map[types.Address]uint64, error) {
+	nonces, err := bc.ancestorNonces(ancestor)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range branch {
+		if err := checkNonces(nonces, b); err != nil {
+			return nil, err
+		}
+		applyNonces(nonces, b)
+	}
+	return nonces, nil
+}
+
+// Calcula os nonces das contas no bloco canônico da altura ancestor, desfazendo a partir de bc.nonces
+// os blocos canônicos acima dele: como cada bloco segue a sequência de nonces, o nonce de um remetente
+// no ancestral é o menor nonce dele nesses blocos. Deve ser chamado com bc.lock travado.
+func (bc *Blockchain) ancestorNonces(ancestor uint32) (map[types.Address]uint64, error) {
+	nonces := maps.Clone(bc.nonces)
+	for height := len(bc.headers) - 1; height > int(ancestor); height-- {
+		b, err := bc.store.Get(BlockHasher{}.Hash(bc.headers[height]))
+		if err != nil {
+			return nil, err
+		}
+		for i := range b.Transactions {
+			addr, ok := txSender(&b.Transactions[i])
+			if !ok {
+				continue
+			}
+			if nonce := b.Transactions[i].Nonce; nonce == 0 {
+				delete(nonces, addr)
+			} else if current, ok := nonces[addr]; ok && nonce < current {
+				nonces[addr] = nonce
+			}
+		}
+	}
+	return nonces, nil
+}
+
+// Troca a cadeia canônica pelo ramo que termina em tip. Deve ser chamado com bc.lock travado.
+func (bc *Blockchain) reorganize(tip *Block) (ChainEvent, error) {
+	branch, ancestor, err := bc.branch(tip)
+	if err != nil {
+		return ChainEvent{}, err
+	}
+	nonces, err := bc.branchNonces(branch, ancestor)
+	if err != nil {
+		return ChainEvent{}, err
+	}
+
+	var removed []*Block
+	for height := len(bc.headers) - 1; height > int(ancestor); height-- {
+		b, err := bc.store.Get(BlockHasher{}.Hash(bc.headers[height]))
+		if err != nil {
+			return ChainEvent{}, err
+		}
+		removed = append(removed, b)
+	}
+
+	if err := bc.store.Put(tip); err != nil {
+		return ChainEvent{}, err
+	}
+	bc.headers = bc.headers[:ancestor+1]
+	for _, b := range branch {
+		bc.headers = append(bc.headers, b.Header)
+	}
+	bc.nonces = nonces
+
+	return ChainEvent{Added: branch, Removed: removed}, nil
+}
+
+// Avança os nonces dos remetentes das transações do bloco.
+func applyNonces(nonces map[types.Address]uint64, b *Block) {
+	for i := range b.Transactions {
+		addr, ok := txSender(&b.Transactions[i])
+		if !ok {
+			continue
+		}
+		if tx := &b.Transactions[i]; tx.Nonce >= nonces[addr] {
+			nonces[addr] = tx.Nonce + 1
+		}
+	}
+}
+
+// Endereço do remetente da transação. Transações sem remetente não entram na contagem de nonces.
+func txSender(tx *Transaction) (types.Address, bool) {
+	addr, err := tx.SenderAddress()
+	return addr, err == nil
+}
+
+// Verifica se as transações de cada remetente no bloco seguem a sequência de nonces, sem lacunas nem repetições.
+func checkNonces(nonces map[types.Address]uint64, b *Block) error {
+	next := make(map[types.Address]uint64)
+	for i := range b.Transactions {
+		tx := &b.Transactions[i]
+		addr, ok := txSender(tx)
+		if !ok {
+			continue
+		}
+
+		expected, ok := next[addr]
+		if !ok {
+			expected = nonces[addr]
+		}
+		if tx.Nonce != expected {
+			return fmt.Errorf("transaction (%s) has nonce %d, expected %d", tx.Hash(TxHasher{}), tx.Nonce, expected)
+		}
+		next[addr] = expected + 1
+	}
+	return nil
+}
+
 // Adiciona diretamente um bloco à blockchain sem validar.
 // Registra no log a altura e o hash do bloco adicionado.
 // Armazena o bloco no store.
 func (bc *Blockchain) addBlockWiothoutValidation(b *Block) error {
-	bc.lock.RLock()
+	if err := bc.store.Put(b); err != nil {
+		return err
+	}
+
+	bc.lock.Lock()
 	bc.headers = append(bc.headers, b.Header)
+	applyNonces(bc.nonces, b)
+	handlers := bc.handlers
 	bc.lock.Unlock()
 
 	logrus.WithFields(logrus.Fields{
@@ -82,5 +333,32 @@ func (bc *Blockchain) addBlockWiothoutValidation(b *Block) error {
 		"hash":   b.Hash(BlockHasher{}),
 	}).Info("adding new block")
 
-	return bc.store.Put(b)
+	for _, h := range handlers {
+		h(ChainEvent{Added: []*Block{b}})
+	}
+	return nil
+}
+
+// Verifica a sequência de nonces do bloco em relação à ponta da cadeia canônica.
+func (bc *Blockchain) checkNonces(b *Block) error {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return checkNonces(bc.nonces, b)
+}
+
+// Verifica a sequência de nonces do bloco em relação ao ramo que termina no seu pai.
+func (bc *Blockchain) checkBranchNonces(parent, b *Block) error {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	branch, ancestor, err := bc.branch(parent)
+	if err != nil {
+		return err
+	}
+	nonces, err := bc.branchNonces(branch, ancestor)
+	if err != nil {
+		return err
+	}
+	return checkNonces(nonces, b)
 }
@@ -1,67 +1,285 @@
 package core
 
-// import (
-// 	"testing"
-
-// 	"github.com/FelipePn10/fadden/types"
-// 	"github.com/stretchr/testify/assert"
-// )
-
-// func TestAddBlock(t *testing.T) {
-// 	bc := newBlockChainGenesis(t)
-// 	lenBlocks := 1000
-
-// 	for i := 0; i < lenBlocks; i++ {
-// 		b := randomBlockWithSignature(t, uint32(i+1), getPrevblockHash(t, bc, uint32(i+1)))
-// 		assert.Nil(t, bc.AddBlock(b))
-// 	}
-
-// 	assert.Equal(t, bc.Height(), uint32(lenBlocks))
-// 	assert.Equal(t, len(bc.headers), lenBlocks+1)
-// 	assert.NotNil(t, bc.AddBlock(randomBlock(89, types.Hash{})))
-// }
-
-// func TestNewBlockchain(t *testing.T) {
-// 	bc := newBlockChainGenesis(t)
-// 	assert.NotNil(t, bc.validator)
-// 	assert.Equal(t, bc.Height(), uint32(0))
-// }
-
-// func TestHasBlock(t *testing.T) {
-// 	bc := newBlockChainGenesis(t)
-// 	assert.True(t, bc.HasBlock(0))
-// 	assert.False(t, bc.HasBlock(1))
-// 	assert.False(t, bc.HasBlock(100))
-// }
-
-// func TestGetHeader(t *testing.T) {
-// 	bc := newBlockChainGenesis(t)
-// 	lenBlocks := 1000
-
-// 	for i := 0; i < lenBlocks; i++ {
-// 		b := randomBlockWithSignature(t, uint32(i+1), getPrevblockHash(t, bc, uint32(i+1)))
-// 		assert.Nil(t, bc.AddBlock(b))
-// 		header, err := bc.GetHeader(b.Height)
-// 		assert.Nil(t, err)
-// 		assert.Equal(t, header, b.Header)
-// 	}
-// }
-
-// func TestAddBlockToHeigh(t *testing.T) {
-// 	bc := newBlockChainGenesis(t)
-
-// 	assert.Nil(t, bc.AddBlock(randomBlockWithSignature(t, 1, getPrevblockHash(t, bc, uint32(1)))))
-// 	assert.NotNil(t, bc.AddBlock(randomBlockWithSignature(t, 3, types.Hash{})))
-// }
-
-// func newBlockChainGenesis(t *testing.T) *Blockchain {
-// 	bc, err := NewBlockchain(randomBlock(0, types.Hash{}))
-// 	assert.Nil(t, err)
-// 	return bc
-// }
-
-// func getPrevblockHash(t *testing.T, bc *Blockchain, height uint32) types.Hash {
-// 	prevHeader, err := bc.GetHeader(height - 1)
-// 	assert.Nil(t, err)
-// 	return BlockHasher{}.Hash(prevHeader)
-// }
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FelipePn10/fadden/crypto"
+	"github.com/FelipePn10/fadden/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddBlock(t *testing.T) {
+	bc := newBlockChainGenesis(t)
+	lenBlocks := 1000
+
+	for i := 0; i < lenBlocks; i++ {
+		b := randomBlockWithSignature(t, uint32(i+1), getPrevblockHash(t, bc, uint32(i+1)))
+		assert.Nil(t, bc.AddBlock(b))
+	}
+
+	assert.Equal(t, bc.Height(), uint32(lenBlocks))
+	assert.Equal(t, len(bc.headers), lenBlocks+1)
+	assert.NotNil(t, bc.AddBlock(randomBlock(89, types.Hash{})))
+}
+
+func TestNewBlockchain(t *testing.T) {
+	bc := newBlockChainGenesis(t)
+	assert.NotNil(t, bc.validator)
+	assert.Equal(t, bc.Height(), uint32(0))
+}
+
+func TestHasBlock(t *testing.T) {
+	bc := newBlockChainGenesis(t)
+	assert.True(t, bc.HasBlock(0))
+	assert.False(t, bc.HasBlock(1))
+	assert.False(t, bc.HasBlock(100))
+}
+
+func TestGetHeader(t *testing.T) {
+	bc := newBlockChainGenesis(t)
+	lenBlocks := 1000
+
+	for i := 0; i < lenBlocks; i++ {
+		b := randomBlockWithSignature(t, uint32(i+1), getPrevblockHash(t, bc, uint32(i+1)))
+		assert.Nil(t, bc.AddBlock(b))
+		header, err := bc.GetHeader(b.Height)
+		assert.Nil(t, err)
+		assert.Equal(t, header, b.Header)
+	}
+}
+
+func TestAddBlockToHeigh(t *testing.T) {
+	bc := newBlockChainGenesis(t)
+
+	assert.Nil(t, bc.AddBlock(randomBlockWithSignature(t, 1, getPrevblockHash(t, bc, uint32(1)))))
+	assert.NotNil(t, bc.AddBlock(randomBlockWithSignature(t, 3, types.Hash{})))
+}
+
+func TestBlockchainNonces(t *testing.T) {
+	bc := newBlockChainGenesis(t)
+	privKey := crypto.GeneratePrivateKey()
+	addr := privKey.PublicKey().Address()
+
+	newTx := func(nonce uint64) Transaction {
+		tx := NewTransaction([]byte{byte(nonce)})
+		tx.Nonce = nonce
+		assert.Nil(t, tx.Sign(privKey))
+		return *tx
+	}
+	newBlock := func(txx ...Transaction) *Block {
+		b := NewBlock(&Header{
+			Version:       1,
+			PrevBlockHash: getPrevblockHash(t, bc, bc.Height()+1),
+			Height:        bc.Height() + 1,
+		}, txx)
+		assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+		return b
+	}
+
+	assert.Equal(t, uint64(0), bc.Nonce(addr))
+	assert.NotNil(t, bc.AddBlock(newBlock(newTx(1))))
+	assert.NotNil(t, bc.AddBlock(newBlock(newTx(0), newTx(0))))
+
+	assert.Nil(t, bc.AddBlock(newBlock(newTx(0), newTx(1))))
+	assert.Equal(t, uint64(2), bc.Nonce(addr))
+	assert.NotNil(t, bc.AddBlock(newBlock(newTx(1))))
+}
+
+func TestBlockchainReorganization(t *testing.T) {
+	bc := newBlockChainGenesis(t)
+	genesis := getPrevblockHash(t, bc, 1)
+
+	var events []ChainEvent
+	bc.Subscribe(func(ev ChainEvent) { events = append(events, ev) })
+
+	a1 := randomBlockWithSignature(t, 1, genesis)
+	a2 := randomBlockWithSignature(t, 2, a1.Hash(BlockHasher{}))
+	assert.Nil(t, bc.AddBlock(a1))
+	assert.Nil(t, bc.AddBlock(a2))
+	assert.Len(t, events, 2)
+	assert.NotNil(t, bc.AddBlock(a2))
+
+	// Um ramo lateral da mesma altura não muda a cadeia canônica.
+	b1 := randomBlockWithSignature(t, 1, genesis)
+	b2 := randomBlockWithSignature(t, 2, b1.Hash(BlockHasher{}))
+	assert.Nil(t, bc.AddBlock(b1))
+	assert.Nil(t, bc.AddBlock(b2))
+	assert.Len(t, events, 2)
+	header, _ := bc.GetHeader(2)
+	assert.Equal(t, a2.Header, header)
+
+	// Quando o ramo fica mais alto, ele passa a ser a cadeia canônica.
+	b3 := randomBlockWithSignature(t, 3, b2.Hash(BlockHasher{}))
+	assert.Nil(t, bc.AddBlock(b3))
+	assert.Equal(t, uint32(3), bc.Height())
+	assert.Len(t, events, 3)
+	assert.Equal(t, []*Block{b1, b2, b3}, events[2].Added)
+	assert.Equal(t, []*Block{a2, a1}, events[2].Removed)
+
+	for height, b := range []*Block{b1, b2, b3} {
+		header, err := bc.GetHeader(uint32(height + 1))
+		assert.Nil(t, err)
+		assert.Equal(t, b.Header, header)
+	}
+
+	assert.NotNil(t, bc.AddBlock(randomBlockWithSignature(t, 5, b3.Hash(BlockHasher{}))))
+}
+
+func TestBlockchainReorganizationRecomputesNonces(t *testing.T) {
+	bc := newBlockChainGenesis(t)
+	genesis := getPrevblockHash(t, bc, 1)
+	privKey := crypto.GeneratePrivateKey()
+	addr := privKey.PublicKey().Address()
+
+	newBlock := func(height uint32, prev types.Hash, nonces ...uint64) *Block {
+		b := randomBlock(height, prev)
+		for _, n := range nonces {
+			tx := NewTransaction([]byte{byte(height), byte(n)})
+			tx.Nonce = n
+			assert.Nil(t, tx.Sign(privKey))
+			b.AddTransaction(tx)
+		}
+		assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+		return b
+	}
+
+	a1 := newBlock(1, genesis, 0, 1, 2)
+	assert.Nil(t, bc.AddBlock(a1))
+	assert.Equal(t, uint64(3), bc.Nonce(addr))
+
+	b1 := newBlock(1, genesis, 0)
+	assert.Nil(t, bc.AddBlock(b1))
+	// Um ramo com lacuna nos nonces não pode virar a cadeia canônica.
+	assert.NotNil(t, bc.AddBlock(newBlock(2, b1.Hash(BlockHasher{}), 2)))
+	assert.Equal(t, uint32(1), bc.Height())
+
+	assert.Nil(t, bc.AddBlock(newBlock(2, b1.Hash(BlockHasher{}), 1)))
+	assert.Equal(t, uint32(2), bc.Height())
+	assert.Equal(t, uint64(2), bc.Nonce(addr))
+}
+
+func TestBlockchainAncestorNonces(t *testing.T) {
+	bc := newBlockChainGenesis(t)
+	k1, k2 := crypto.GeneratePrivateKey(), crypto.GeneratePrivateKey()
+	a1, a2 := k1.PublicKey().Address(), k2.PublicKey().Address()
+
+	type sent struct {
+		key   crypto.PrivateKey
+		nonce uint64
+	}
+	newBlock := func(height uint32, prev types.Hash, txx ...sent) *Block {
+		b := randomBlock(height, prev)
+		for _, s := range txx {
+			tx := NewTransaction([]byte{byte(height), byte(s.nonce)})
+			tx.Nonce = s.nonce
+			assert.Nil(t, tx.Sign(s.key))
+			b.AddTransaction(tx)
+		}
+		assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+		return b
+	}
+
+	b1 := newBlock(1, getPrevblockHash(t, bc, 1), sent{k1, 0}, sent{k1, 1})
+	assert.Nil(t, bc.AddBlock(b1))
+	assert.Nil(t, bc.AddBlock(newBlock(2, getPrevblockHash(t, bc, 2), sent{k1, 2}, sent{k2, 0})))
+	assert.Nil(t, bc.AddBlock(newBlock(3, getPrevblockHash(t, bc, 3), sent{k1, 3}, sent{k2, 1}, sent{k2, 2})))
+
+	for ancestor, want := range []map[types.Address]uint64{
+		{},
+		{a1: 2},
+		{a1: 3, a2: 1},
+		{a1: 4, a2: 3},
+	} {
+		nonces, err := bc.ancestorNonces(uint32(ancestor))
+		assert.Nil(t, err)
+		assert.Equal(t, want, nonces)
+	}
+
+	// Um ramo lateral a partir de b1 continua dos nonces de b1, não dos da ponta.
+	assert.NotNil(t, bc.AddBlock(newBlock(2, b1.Hash(BlockHasher{}), sent{k1, 4})))
+	assert.Nil(t, bc.AddBlock(newBlock(2, b1.Hash(BlockHasher{}), sent{k1, 2}, sent{k2, 0})))
+}
+
+type rejectingValidator struct {
+	Validator
+	reject types.Hash
+}
+
+func (v rejectingValidator) ValidateBlock(b *Block) error {
+	if b.Hash(BlockHasher{}) == v.reject {
+		return fmt.Errorf("rejected")
+	}
+	return v.Validator.ValidateBlock(b)
+}
+
+func TestBlockchainSideBlocksUseValidator(t *testing.T) {
+	bc := newBlockChainGenesis(t)
+	genesis := getPrevblockHash(t, bc, 1)
+
+	a1 := randomBlockWithSignature(t, 1, genesis)
+	assert.Nil(t, bc.AddBlock(a1))
+
+	b1 := randomBlockWithSignature(t, 1, genesis)
+	bc.SetValidator(rejectingValidator{Validator: bc.validator, reject: b1.Hash(BlockHasher{})})
+	assert.NotNil(t, bc.AddBlock(b1))
+	_, err := bc.GetBlock(b1.Hash(BlockHasher{}))
+	assert.NotNil(t, err)
+
+	// Sem a rejeição, o mesmo bloco entra no ramo lateral.
+	bc.SetValidator(NewBlockValidator(bc))
+	assert.Nil(t, bc.AddBlock(b1))
+	assert.Equal(t, a1.Header, bc.headers[1])
+}
+
+func TestBlockchainRejectsExpiredTx(t *testing.T) {
+	bc := newBlockChainGenesis(t)
+
+	newBlock := func(validUntil uint32) *Block {
+		tx := NewTransaction([]byte("foo"))
+		tx.ValidUntilHeight = validUntil
+		assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+		b := randomBlock(bc.Height()+1, getPrevblockHash(t, bc, bc.Height()+1))
+		b.AddTransaction(tx)
+		assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+		return b
+	}
+
+	assert.Nil(t, bc.AddBlock(newBlock(1)))
+	assert.NotNil(t, bc.AddBlock(newBlock(1)))
+	assert.Nil(t, bc.AddBlock(newBlock(2)))
+}
+
+func TestBlockchainChainID(t *testing.T) {
+	bc, err := NewBlockchainFromGenesis(GenesisConfig{ChainID: TestnetChainID})
+	assert.Nil(t, err)
+	assert.Equal(t, TestnetChainID, bc.ChainID())
+
+	newBlock := func(blockChainID, txChainID uint32) *Block {
+		tx := NewTransaction([]byte("foo"))
+		tx.ChainID = txChainID
+		assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+
+		b := randomBlock(1, getPrevblockHash(t, bc, 1))
+		b.ChainID = blockChainID
+		b.AddTransaction(tx)
+		assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+		return b
+	}
+
+	assert.NotNil(t, bc.AddBlock(newBlock(MainnetChainID, TestnetChainID)))
+	assert.NotNil(t, bc.AddBlock(newBlock(TestnetChainID, MainnetChainID)))
+	assert.Nil(t, bc.AddBlock(newBlock(TestnetChainID, TestnetChainID)))
+}
+
+func newBlockChainGenesis(t *testing.T) *Blockchain {
+	bc, err := NewBlockchain(randomBlock(0, types.Hash{}))
+	assert.Nil(t, err)
+	return bc
+}
+
+func getPrevblockHash(t *testing.T, bc *Blockchain, height uint32) types.Hash {
+	prevHeader, err := bc.GetHeader(height - 1)
+	assert.Nil(t, err)
+	return BlockHasher{}.Hash(prevHeader)
+}
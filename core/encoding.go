@@ -1,7 +1,6 @@
 package core
 
 import (
-	"crypto/elliptic"
 	"encoding/gob"
 	"io"
 )
@@ -23,20 +22,14 @@ type GobTxEncoder struct {
 	w io.Writer
 }
 
-// Encode implements Encoder.
-func (g *GobTxEncoder) Encode(T any) error {
-	panic("unimplemented")
-}
-
 func NewGobTxEncoder(w io.Writer) *GobTxEncoder {
-	gob.Register(elliptic.P256())
 	return &GobTxEncoder{
 		w: w,
 	}
 }
 
-func (e *GobTxDecoder) Decode(tx *Transaction) error {
-	return gob.NewDecoder(e.r).Decode(tx)
+func (e *GobTxEncoder) Encode(tx *Transaction) error {
+	return gob.NewEncoder(e.w).Encode(tx)
 }
 
 type GobTxDecoder struct {
@@ -44,12 +37,39 @@ type GobTxDecoder struct {
 }
 
 func NewGobTxDecoder(r io.Reader) *GobTxDecoder {
-	gob.Register(elliptic.P256())
 	return &GobTxDecoder{
 		r: r,
 	}
 }
 
-func (e *GobTxDecoder) Decoder(tx *Transaction) error {
+func (e *GobTxDecoder) Decode(tx *Transaction) error {
 	return gob.NewDecoder(e.r).Decode(tx)
 }
+
+type GobBlockEncoder struct {
+	w io.Writer
+}
+
+func NewGobBlockEncoder(w io.Writer) *GobBlockEncoder {
+	return &GobBlockEncoder{
+		w: w,
+	}
+}
+
+func (e *GobBlockEncoder) Encode(b *Block) error {
+	return gob.NewEncoder(e.w).Encode(b)
+}
+
+type GobBlockDecoder struct {
+	r io.Reader
+}
+
+func NewGobBlockDecoder(r io.Reader) *GobBlockDecoder {
+	return &GobBlockDecoder{
+		r: r,
+	}
+}
+
+func (d *GobBlockDecoder) Decode(b *Block) error {
+	return gob.NewDecoder(d.r).Decode(b)
+}
@@ -0,0 +1,27 @@
+package core
+
+import "github.com/FelipePn10/fadden/types"
+
+// Identificadores das redes conhecidas. Transações e blocos assinados para uma rede não valem nas outras.
+const (
+	MainnetChainID uint32 = 1
+	TestnetChainID uint32 = 2
+)
+
+// GenesisConfig: Configuração do bloco gênesis, que define a rede.
+type GenesisConfig struct {
+	ChainID   uint32
+	Timestamp uint64
+}
+
+// Cria o bloco gênesis da configuração. Ele não tem transações nem assinatura.
+func (g GenesisConfig) Block() *Block {
+	return NewBlock(&Header{
+		Version:       1,
+		Datahash:      CalculateDataHash(nil),
+		PrevBlockHash: types.Hash{},
+		Timestamp:     g.Timestamp,
+		Height:        0,
+		ChainID:       g.ChainID,
+	}, []Transaction{})
+}
@@ -1,8 +1,13 @@
 package core
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"math/big"
 
+	"github.com/FelipePn10/fadden/crypto"
 	"github.com/FelipePn10/fadden/types"
 )
 
@@ -22,9 +27,80 @@ func (BlockHasher) Hash(b *Header) types.Hash {
 	return types.Hash(h)
 }
 
+// Rótulos que separam os domínios de assinatura: a assinatura de um header nunca é válida
+// como assinatura de uma transação, e vice-versa.
+const (
+	headerSigningTag = "fadden/header/v1"
+	txSigningTag     = "fadden/tx/v1"
+)
+
+// Calcula o hash a ser assinado: sha256(rótulo || chain ID || codificação canônica).
+// Com o chain ID no hash, uma assinatura feita na testnet não vale na mainnet.
+func signingDigest(tag string, chainID uint32, encoding []byte) types.Hash {
+	buf := &bytes.Buffer{}
+	writeLengthPrefixed(buf, []byte(tag))
+	binary.Write(buf, binary.BigEndian, chainID)
+	buf.Write(encoding)
+
+	return types.Hash(sha256.Sum256(buf.Bytes()))
+}
+
+// Implementação de um Hasher para transações. O ID cobre a transação assinada inteira:
+// os campos assinados (ver Transaction.SigningHash) e a assinatura recuperável.
+// Como a assinatura com o ID de recuperação determina o remetente, o mesmo conteúdo enviado por
+// remetentes diferentes gera IDs diferentes, e omitir From (ver Transaction.OmitFrom) não muda o ID.
+// Em esquemas sem recuperação (ex: Ed25519), o ID também cobre o esquema e o remetente.
+// Nas transações multisig, o endereço da conta já está nos campos assinados e o ID cobre as assinaturas.
 type TxHasher struct {
 }
 
 func (TxHasher) Hash(tx *Transaction) types.Hash {
-	return types.Hash(sha256.Sum256(tx.Data))
+	buf := bytes.NewBuffer(tx.signingBytes())
+
+	writeSignature(buf, tx.Signature)
+
+	if tx.Signature != nil && tx.Signature.Scheme != crypto.SchemeP256 {
+		buf.WriteByte(byte(tx.Signature.Scheme))
+		if !tx.Signature.Recoverable() {
+			var from []byte
+			if !tx.From.IsZero() {
+				from = tx.From.ToSlice()
+			}
+			writeLengthPrefixed(buf, from)
+		}
+	}
+
+	for _, ms := range tx.Signatures {
+		buf.WriteByte(ms.Index)
+		if ms.Signature != nil {
+			buf.WriteByte(byte(ms.Signature.Scheme))
+		}
+		writeSignature(buf, ms.Signature)
+	}
+
+	return types.Hash(sha256.Sum256(buf.Bytes()))
+}
+
+// Marca, no lugar do tamanho, uma assinatura fora do formato recuperável.
+const malformedSignature = math.MaxUint32
+
+// Escreve a assinatura no formato recuperável, com prefixo de tamanho (vazia sem assinatura).
+// Uma assinatura recebida de um peer pode ter r ou s fora do formato (ver Signature.ToBytes); ela
+// nunca passa em Verify, mas o hash continua definido: depois da marca malformedSignature, r e s
+// entram com sinal e tamanho variável.
+func writeSignature(buf *bytes.Buffer, sig *crypto.Signature) {
+	var b []byte
+	if sig != nil {
+		var err error
+		if b, err = sig.ToRecoverableBytes(); err != nil {
+			binary.Write(buf, binary.BigEndian, uint32(malformedSignature))
+			for _, x := range []*big.Int{sig.R, sig.S} {
+				buf.WriteByte(byte(x.Sign() + 1))
+				writeLengthPrefixed(buf, x.Bytes())
+			}
+			buf.WriteByte(sig.V)
+			return
+		}
+	}
+	writeLengthPrefixed(buf, b)
 }
@@ -1,16 +1,44 @@
 package core
 
+import (
+	"fmt"
+	"sync"
+
+	"github.com/FelipePn10/fadden/types"
+)
+
 type Storage interface {
 	Put(*Block) error
+	Get(types.Hash) (*Block, error)
 }
 
+// MemoryStorage: Guarda os blocos em memória, indexados pelo hash.
 type MemoryStorage struct {
+	lock   sync.RWMutex
+	blocks map[types.Hash]*Block
 }
 
 func NewMemoryStorage() *MemoryStorage {
-	return &MemoryStorage{}
+	return &MemoryStorage{
+		blocks: make(map[types.Hash]*Block),
+	}
 }
 
 func (s *MemoryStorage) Put(b *Block) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.blocks[b.Hash(BlockHasher{})] = b
 	return nil
 }
+
+func (s *MemoryStorage) Get(hash types.Hash) (*Block, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	b, ok := s.blocks[hash]
+	if !ok {
+		return nil, fmt.Errorf("block (%s) not found", hash)
+	}
+	return b, nil
+}
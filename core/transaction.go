@@ -1,6 +1,8 @@
 package core
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 
 	"github.com/FelipePn10/fadden/crypto"
@@ -10,10 +12,26 @@ import (
 // Transaction: Estrutura que representa uma transação.
 type Transaction struct {
 	Data      []byte            // Dados da transação
-	From      crypto.PublicKey  // Chave pública do remetente
+	From      crypto.PublicKey  // Chave pública do remetente (opcional, ver Sender)
 	Signature *crypto.Signature // Guarda a assinatura digital da transação
+	Nonce     uint64            // Número de sequência das transações do remetente
+	Fee       uint64            // Taxa paga ao validador que incluir a transação
+	ChainID   uint32            // Rede em que a transação vale (ver GenesisConfig)
+
+	// Validade opcional (0 = sem limite): a transação só pode entrar em blocos até a altura
+	// ValidUntilHeight e com timestamp até Deadline (em nanossegundos, como Header.Timestamp).
+	ValidUntilHeight uint32
+	Deadline         uint64
+
+	Stamp uint64 // Carimbo da prova de trabalho anti-spam (ver Mine)
+
+	// Remetente multisig (ver SignMultisig): a política da conta e as assinaturas dos participantes,
+	// no lugar de From e Signature.
+	Multisig   *crypto.Multisig
+	Signatures []crypto.MultisigSignature
 
 	hash      types.Hash
+	sender    crypto.PublicKey // Remetente em cache, recuperado da assinatura
 	firstSeen int64
 }
 
@@ -23,6 +41,8 @@ func NewTransaction(data []byte) *Transaction {
 	}
 }
 
+// Retorna o ID da transação (ver TxHasher). O valor fica em cache: os campos não devem mudar
+// depois que o hash for calculado, exceto pelo Stamp, que não faz parte do ID.
 func (tx *Transaction) Hash(h Hasher[*Transaction]) types.Hash {
 	if tx.hash.IsZero() {
 		tx.hash = h.Hash(tx)
@@ -30,31 +50,158 @@ func (tx *Transaction) Hash(h Hasher[*Transaction]) types.Hash {
 	return tx.hash
 }
 
+// Retorna o hash assinado pelo remetente, separado por domínio (ver signingDigest). Ele cobre
+// todos os campos da transação, exceto o remetente, a assinatura e o carimbo de trabalho.
+func (tx *Transaction) SigningHash() types.Hash {
+	return signingDigest(txSigningTag, tx.ChainID, tx.signingBytes())
+}
+
+// Codificação canônica dos campos assinados: cada campo em big-endian, com Data prefixado pelo tamanho.
+// Nas transações multisig, o endereço da conta também é assinado: as assinaturas dos participantes
+// não servem para outra conta com as mesmas chaves.
+func (tx *Transaction) signingBytes() []byte {
+	buf := &bytes.Buffer{}
+	writeLengthPrefixed(buf, tx.Data)
+	binary.Write(buf, binary.BigEndian, tx.Nonce)
+	binary.Write(buf, binary.BigEndian, tx.Fee)
+	binary.Write(buf, binary.BigEndian, tx.ValidUntilHeight)
+	binary.Write(buf, binary.BigEndian, tx.Deadline)
+	binary.Write(buf, binary.BigEndian, tx.ChainID)
+	if tx.Multisig != nil {
+		addr := tx.Multisig.Address()
+		buf.Write(addr[:])
+	}
+	return buf.Bytes()
+}
+
+func writeLengthPrefixed(buf *bytes.Buffer, b []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(b)))
+	buf.Write(b)
+}
+
 // Assina a transação com uma chave privada.
 func (tx *Transaction) Sign(privKey crypto.PrivateKey) error {
-	sig, err := privKey.Sign(tx.Data)
+	sig, err := privKey.SignHash(tx.SigningHash())
 	if err != nil {
 		return err
 	}
 	tx.From = privKey.PublicKey()
 	tx.Signature = sig
+	tx.hash = types.Hash{} // O ID cobre a assinatura
+	tx.sender = crypto.PublicKey{}
+
+	return nil
+}
+
+// Remove a chave pública do remetente, que passa a ser recuperada da assinatura (ver Sender).
+// Economiza 33 bytes por transação; o ID não muda. Só vale para esquemas com recuperação
+// (P-256 e secp256k1): uma transação Ed25519 sem From não é válida.
+func (tx *Transaction) OmitFrom() {
+	tx.From = crypto.PublicKey{}
+}
+
+// Assina a transação como participante da conta multisig. Cada participante assina a mesma transação
+// (com Multisig já definido) e as assinaturas se acumulam em Signatures.
+func (tx *Transaction) SignMultisig(multisig *crypto.Multisig, privKey crypto.PrivateKey) error {
+	if tx.Multisig == nil {
+		tx.Multisig = multisig
+	} else if tx.Multisig.Address() != multisig.Address() {
+		return fmt.Errorf("transaction is already signed for multisig %s", tx.Multisig.Address())
+	}
+
+	sigs, err := multisig.SignHash(privKey, tx.SigningHash(), tx.Signatures)
+	if err != nil {
+		return err
+	}
+	tx.Signatures = sigs
+	tx.hash = types.Hash{}
 
 	return nil
 }
 
+// Retorna o endereço do remetente: o endereço da conta multisig ou o da chave do remetente (ver Sender).
+func (tx *Transaction) SenderAddress() (types.Address, error) {
+	if tx.Multisig != nil {
+		return tx.Multisig.Address(), nil
+	}
+	pub, err := tx.Sender()
+	if err != nil {
+		return types.Address{}, err
+	}
+	return pub.Address(), nil
+}
+
+// Retorna a chave pública do remetente: From, se presente, ou a chave recuperada da assinatura.
+// O resultado fica em cache, assim como o hash.
+func (tx *Transaction) Sender() (crypto.PublicKey, error) {
+	if !tx.sender.IsZero() {
+		return tx.sender, nil
+	}
+	if tx.Multisig != nil {
+		return crypto.PublicKey{}, fmt.Errorf("multisig transaction has no single sender key")
+	}
+	if !tx.From.IsZero() {
+		return tx.From, nil
+	}
+	if tx.Signature == nil {
+		return crypto.PublicKey{}, fmt.Errorf("transaction has no signature")
+	}
+
+	pub, err := crypto.RecoverPublicKey(tx.SigningHash(), tx.Signature)
+	if err != nil {
+		return crypto.PublicKey{}, err
+	}
+	tx.sender = pub
+	return pub, nil
+}
+
 // Valida se a transação foi assinada corretamente (se é legítima ou não).
+// Nos esquemas com recuperação, o remetente é sempre recuperado da assinatura; se From estiver presente,
+// precisa ser a mesma chave. Assim o ID de recuperação também é validado e a transação tem um único
+// remetente possível. Nos demais esquemas (ex: Ed25519), From é obrigatório.
+// Transações multisig precisam das assinaturas de pelo menos Threshold participantes distintos.
 func (tx *Transaction) Verify() error {
+	if tx.Multisig != nil {
+		if tx.Signature != nil || !tx.From.IsZero() {
+			return fmt.Errorf("multisig transaction must not carry From or Signature")
+		}
+		return tx.Multisig.VerifyHash(tx.SigningHash(), tx.Signatures)
+	}
+	if len(tx.Signatures) > 0 {
+		return fmt.Errorf("transaction has multisig signatures but no multisig policy")
+	}
 	if tx.Signature == nil {
 		return fmt.Errorf("transaction has no signature")
 	}
 
-	if !tx.Signature.Verify(tx.From, tx.Data) {
+	hash := tx.SigningHash()
+	pub := tx.From
+	if tx.Signature.Recoverable() {
+		recovered, err := crypto.RecoverPublicKey(hash, tx.Signature)
+		if err != nil {
+			return fmt.Errorf("invalid transaction signature: %w", err)
+		}
+		if !tx.From.IsZero() && !tx.From.Equal(recovered) {
+			return fmt.Errorf("transaction signature does not match sender %s", tx.From.Address())
+		}
+		pub = recovered
+	} else if pub.IsZero() {
+		return fmt.Errorf("transaction signed with %s must include the sender", tx.Signature.Scheme)
+	}
+	if !tx.Signature.VerifyHash(pub, hash) {
 		return fmt.Errorf("invalid transaction signature")
 	}
 
+	tx.sender = pub
 	return nil
 }
 
+// Verifica se a transação não pode mais entrar em um bloco com a altura e o timestamp informados.
+func (tx *Transaction) Expired(height uint32, timestamp uint64) bool {
+	return (tx.ValidUntilHeight != 0 && height > tx.ValidUntilHeight) ||
+		(tx.Deadline != 0 && timestamp > tx.Deadline)
+}
+
 func (tx *Transaction) Decode(dec Decoder[*Transaction]) error {
 	return dec.Decode(tx)
 }
@@ -1,9 +1,15 @@
 package core
 
 import (
+	"bytes"
+	"context"
+	"math/big"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/FelipePn10/fadden/crypto"
+	"github.com/FelipePn10/fadden/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,15 +37,16 @@ func TestVerifyTransaction(t *testing.T) {
 	assert.NotNil(t, tx.Verify())
 }
 
-// func TestTxEncodeDecode(t *testing.T) {
-// 	tx := randomBlockWithSignature(t, 0, types.Hash{})
-// 	buf := &bytes.Buffer{}
-// 	assert.Nil(t, tx.Encode(NewGobTxEncoder(buf)))
+func TestTxEncodeDecode(t *testing.T) {
+	tx := randomTxWithSignature(t)
+	buf := &bytes.Buffer{}
+	assert.Nil(t, tx.Encode(NewGobTxEncoder(buf)))
 
-// 	txDecoded := new(Transaction)
-// 	assert.Nil(t, txDecoded.Decode(NewGobTxDecoder(buf)))
-// 	assert.Equal(t, tx, txDecoded)
-// }
+	txDecoded := new(Transaction)
+	assert.Nil(t, txDecoded.Decode(NewGobTxDecoder(buf)))
+	assert.Equal(t, tx, txDecoded)
+	assert.Nil(t, txDecoded.Verify())
+}
 
 func randomTxWithSignature(t *testing.T) *Transaction {
 	privKey := crypto.GeneratePrivateKey()
@@ -50,3 +57,330 @@ func randomTxWithSignature(t *testing.T) *Transaction {
 
 	return tx
 }
+
+func TestTxExpired(t *testing.T) {
+	tx := NewTransaction([]byte("foo"))
+	assert.False(t, tx.Expired(1<<31, 1<<63))
+
+	tx.ValidUntilHeight = 10
+	assert.False(t, tx.Expired(10, 0))
+	assert.True(t, tx.Expired(11, 0))
+
+	tx.ValidUntilHeight = 0
+	tx.Deadline = 1000
+	assert.False(t, tx.Expired(100, 1000))
+	assert.True(t, tx.Expired(100, 1001))
+}
+
+func TestTxMineWork(t *testing.T) {
+	tx := randomTxWithSignature(t)
+	assert.Nil(t, tx.Mine(context.Background(), 12))
+	assert.GreaterOrEqual(t, tx.WorkBits(), 12)
+
+	// O carimbo vale apenas para a transação em que foi minerado.
+	other := NewTransaction([]byte("bar"))
+	other.Stamp = tx.Stamp
+	assert.NotEqual(t, tx.WorkHash(), other.WorkHash())
+}
+
+func TestTxMineBounded(t *testing.T) {
+	tx := randomTxWithSignature(t)
+	assert.NotNil(t, tx.Mine(context.Background(), MaxWorkBits+1))
+
+	// Uma exigência impossível na prática termina quando o contexto é cancelado.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, tx.Mine(ctx, MaxWorkBits), context.DeadlineExceeded)
+}
+
+func TestTxHashCommitsToSignedTx(t *testing.T) {
+	a, b := NewTransaction([]byte("foo")), NewTransaction([]byte("foo"))
+	assert.Equal(t, a.Hash(TxHasher{}), b.Hash(TxHasher{}))
+
+	// O mesmo conteúdo assinado por remetentes diferentes tem IDs diferentes.
+	assert.Nil(t, a.Sign(crypto.GeneratePrivateKey()))
+	assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+	assert.NotEqual(t, a.Hash(TxHasher{}), b.Hash(TxHasher{}))
+	assert.Equal(t, a.SigningHash(), b.SigningHash())
+
+	// O carimbo de trabalho não faz parte do ID nem do hash assinado.
+	c := *a
+	c.Stamp++
+	assert.Equal(t, a.Hash(TxHasher{}), TxHasher{}.Hash(&c))
+	assert.Nil(t, c.Verify())
+}
+
+func TestTxHashMalformedSignature(t *testing.T) {
+	huge := new(big.Int).Lsh(big.NewInt(1), 300)
+	tx := randomTxWithSignature(t)
+	valid := tx.Hash(TxHasher{})
+
+	// Uma assinatura com r fora do formato é rejeitada por Verify, e calcular o ID não entra em panic.
+	tx.Signature = &crypto.Signature{R: huge, S: big.NewInt(1)}
+	assert.ErrorIs(t, tx.Verify(), crypto.ErrInvalidSignature)
+	malformed := TxHasher{}.Hash(tx)
+	assert.NotEqual(t, valid, malformed)
+	assert.NotEqual(t, TxHasher{}.Hash(NewTransaction([]byte("foo"))), malformed)
+
+	tx.Signature = &crypto.Signature{R: new(big.Int).Neg(huge), S: big.NewInt(1)}
+	assert.NotEqual(t, malformed, TxHasher{}.Hash(tx))
+
+	// O mesmo vale para as assinaturas de uma transação multisig.
+	tx.Signature = nil
+	tx.Signatures = []crypto.MultisigSignature{{Index: 0, Signature: &crypto.Signature{R: big.NewInt(1), S: huge}}}
+	assert.NotPanics(t, func() { TxHasher{}.Hash(tx) })
+}
+
+func TestTxSignatureCoversAllFields(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	for _, mutate := range []func(*Transaction){
+		func(tx *Transaction) { tx.Data = []byte("bar") },
+		func(tx *Transaction) { tx.Nonce++ },
+		func(tx *Transaction) { tx.Fee++ },
+		func(tx *Transaction) { tx.ValidUntilHeight++ },
+		func(tx *Transaction) { tx.Deadline++ },
+	} {
+		tx := NewTransaction([]byte("foo"))
+		assert.Nil(t, tx.Sign(privKey))
+		mutate(tx)
+		assert.NotNil(t, tx.Verify())
+	}
+}
+
+func TestTxSignatureBoundToChainID(t *testing.T) {
+	tx := NewTransaction([]byte("foo"))
+	tx.ChainID = TestnetChainID
+	assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+	assert.Nil(t, tx.Verify())
+
+	// Reenviar a mesma transação assinada para outra rede não funciona.
+	tx.ChainID = MainnetChainID
+	assert.NotNil(t, tx.Verify())
+}
+
+func TestSigningDigestDomainSeparation(t *testing.T) {
+	encoding := []byte("same canonical bytes")
+	assert.NotEqual(t, signingDigest(txSigningTag, 1, encoding), signingDigest(headerSigningTag, 1, encoding))
+	assert.NotEqual(t, signingDigest(txSigningTag, 1, encoding), signingDigest(txSigningTag, 2, encoding))
+}
+
+func TestTxSignatureCoversLongData(t *testing.T) {
+	prefix := bytes.Repeat([]byte("x"), 64)
+	tx := NewTransaction(append(append([]byte{}, prefix...), "alice"...))
+	assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+
+	forged := *tx
+	forged.Data = append(append([]byte{}, prefix...), "mallory"...)
+	assert.NotNil(t, forged.Verify())
+}
+
+func TestTxWithoutFrom(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	tx := NewTransaction([]byte("foo"))
+	assert.Nil(t, tx.Sign(privKey))
+	id := tx.Hash(TxHasher{})
+
+	tx.OmitFrom()
+	tx.hash = types.Hash{}
+	assert.Equal(t, id, tx.Hash(TxHasher{}))
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, tx.Encode(NewGobTxEncoder(buf)))
+	decoded := new(Transaction)
+	assert.Nil(t, decoded.Decode(NewGobTxDecoder(buf)))
+	assert.Nil(t, decoded.From.Key)
+	assert.Nil(t, decoded.Verify())
+
+	sender, err := decoded.Sender()
+	assert.Nil(t, err)
+	assert.Equal(t, privKey.PublicKey().Address(), sender.Address())
+}
+
+func TestTxVerifyChecksRecoveryID(t *testing.T) {
+	tx := randomTxWithSignature(t)
+
+	// Com outro ID de recuperação, a assinatura aponta para outra chave e não confere com From.
+	tx.Signature = &crypto.Signature{R: tx.Signature.R, S: tx.Signature.S, V: tx.Signature.V ^ 1}
+	assert.NotNil(t, tx.Verify())
+}
+
+func TestTxSignatureSchemes(t *testing.T) {
+	for _, id := range []crypto.SchemeID{crypto.SchemeEd25519, crypto.SchemeSecp256k1} {
+		privKey, err := crypto.GenerateSchemeKey(id)
+		assert.Nil(t, err)
+
+		tx := NewTransaction([]byte("foo"))
+		assert.Nil(t, tx.Sign(privKey))
+		assert.Nil(t, tx.Verify(), id)
+
+		sender, err := tx.Sender()
+		assert.Nil(t, err)
+		assert.Equal(t, privKey.PublicKey().Address(), sender.Address())
+
+		// A transação sobrevive à serialização com o esquema da chave e da assinatura.
+		buf := &bytes.Buffer{}
+		assert.Nil(t, tx.Encode(NewGobTxEncoder(buf)))
+		decoded := new(Transaction)
+		assert.Nil(t, decoded.Decode(NewGobTxDecoder(buf)))
+		assert.Nil(t, decoded.Verify(), id)
+		assert.Equal(t, tx.Hash(TxHasher{}), decoded.Hash(TxHasher{}))
+
+		tx.Nonce++
+		assert.NotNil(t, tx.Verify(), id)
+	}
+}
+
+func TestTxEd25519RequiresFrom(t *testing.T) {
+	privKey, err := crypto.GenerateSchemeKey(crypto.SchemeEd25519)
+	assert.Nil(t, err)
+
+	tx := NewTransaction([]byte("foo"))
+	assert.Nil(t, tx.Sign(privKey))
+	tx.OmitFrom()
+	assert.NotNil(t, tx.Verify())
+
+	// Outra chave Ed25519 no From não serve.
+	other, err := crypto.GenerateSchemeKey(crypto.SchemeEd25519)
+	assert.Nil(t, err)
+	tx.From = other.PublicKey()
+	assert.NotNil(t, tx.Verify())
+}
+
+func TestTxSecp256k1WithoutFrom(t *testing.T) {
+	privKey, err := crypto.GenerateSchemeKey(crypto.SchemeSecp256k1)
+	assert.Nil(t, err)
+
+	tx := NewTransaction([]byte("foo"))
+	assert.Nil(t, tx.Sign(privKey))
+	id := tx.Hash(TxHasher{})
+
+	tx.OmitFrom()
+	assert.Nil(t, tx.Verify())
+	assert.Equal(t, id, TxHasher{}.Hash(tx))
+	sender, err := tx.Sender()
+	assert.Nil(t, err)
+	assert.Equal(t, privKey.PublicKey().Address(), sender.Address())
+}
+
+func TestTxMultisig(t *testing.T) {
+	privs := []crypto.PrivateKey{crypto.GeneratePrivateKey(), crypto.GeneratePrivateKey(), crypto.GeneratePrivateKey()}
+	pubs := []crypto.PublicKey{privs[0].PublicKey(), privs[1].PublicKey(), privs[2].PublicKey()}
+	multisig, err := crypto.NewMultisig(2, pubs)
+	assert.Nil(t, err)
+
+	tx := NewTransaction([]byte("foo"))
+	assert.Nil(t, tx.SignMultisig(multisig, privs[1]))
+	assert.NotNil(t, tx.Verify())
+
+	assert.Nil(t, tx.SignMultisig(multisig, privs[2]))
+	assert.Nil(t, tx.Verify())
+
+	addr, err := tx.SenderAddress()
+	assert.Nil(t, err)
+	assert.Equal(t, multisig.Address(), addr)
+	_, err = tx.Sender()
+	assert.NotNil(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, tx.Encode(NewGobTxEncoder(buf)))
+	decoded := new(Transaction)
+	assert.Nil(t, decoded.Decode(NewGobTxDecoder(buf)))
+	assert.Nil(t, decoded.Verify())
+	assert.Equal(t, tx.Hash(TxHasher{}), decoded.Hash(TxHasher{}))
+
+	// Alterar um campo invalida as assinaturas.
+	decoded.Fee++
+	assert.NotNil(t, decoded.Verify())
+
+	// Trocar o ID de recuperação muda o ID da transação, então a assinatura deixa de valer.
+	for v := byte(0); v < 4; v++ {
+		if v == tx.Signatures[0].Signature.V {
+			continue
+		}
+		flipped := *tx
+		flipped.Signatures = slices.Clone(tx.Signatures)
+		sig := *tx.Signatures[0].Signature
+		sig.V = v
+		flipped.Signatures[0].Signature = &sig
+
+		assert.NotEqual(t, TxHasher{}.Hash(tx), TxHasher{}.Hash(&flipped))
+		assert.NotNil(t, flipped.Verify())
+	}
+}
+
+func TestTxMultisigBoundToAccount(t *testing.T) {
+	privs := []crypto.PrivateKey{crypto.GeneratePrivateKey(), crypto.GeneratePrivateKey(), crypto.GeneratePrivateKey()}
+	pubs := []crypto.PublicKey{privs[0].PublicKey(), privs[1].PublicKey(), privs[2].PublicKey()}
+	treasury, err := crypto.NewMultisig(2, pubs)
+	assert.Nil(t, err)
+	other, err := crypto.NewMultisig(2, pubs[:2])
+	assert.Nil(t, err)
+
+	tx := NewTransaction([]byte("foo"))
+	assert.Nil(t, tx.SignMultisig(treasury, privs[0]))
+	assert.Nil(t, tx.SignMultisig(treasury, privs[1]))
+	assert.Nil(t, tx.Verify())
+	assert.NotNil(t, tx.SignMultisig(other, privs[0]))
+
+	// As mesmas assinaturas não valem para outra conta com as mesmas chaves.
+	replayed := *tx
+	replayed.Multisig = other
+	assert.NotNil(t, replayed.Verify())
+
+	// Uma transação multisig não pode ter também um remetente comum.
+	mixed := *tx
+	assert.Nil(t, mixed.Sign(privs[0]))
+	assert.NotNil(t, mixed.Verify())
+}
+
+func TestTxSchnorrAggregateSender(t *testing.T) {
+	privs := make([]crypto.PrivateKey, 3)
+	pubs := make([]crypto.PublicKey, 3)
+	for i := range privs {
+		key, err := crypto.GenerateSchemeKey(crypto.SchemeSchnorrP256)
+		assert.Nil(t, err)
+		privs[i], pubs[i] = key, key.PublicKey()
+	}
+	agg, err := crypto.AggregatePublicKeys(pubs)
+	assert.Nil(t, err)
+
+	// A conta é a chave agregada: uma única assinatura, sem revelar os participantes.
+	tx := NewTransaction([]byte("treasury payout"))
+	tx.From = agg.Key
+	tx.Signature = musigSignHash(t, agg, privs, tx.SigningHash())
+	assert.Nil(t, tx.Verify())
+
+	addr, err := tx.SenderAddress()
+	assert.Nil(t, err)
+	assert.Equal(t, agg.Key.Address(), addr)
+
+	tx.Fee++
+	assert.NotNil(t, tx.Verify())
+}
+
+func musigSignHash(t *testing.T, agg *crypto.AggregateKey, privs []crypto.PrivateKey, hash types.Hash) *crypto.Signature {
+	ordered := make([]crypto.PrivateKey, len(privs))
+	for _, key := range privs {
+		ordered[agg.IndexOf(key.PublicKey())] = key
+	}
+
+	nonces := make([]*crypto.MuSigNonce, len(ordered))
+	public := make([]crypto.MuSigPublicNonce, len(ordered))
+	for i, key := range ordered {
+		nonce, err := crypto.NewMuSigNonce(key, hash)
+		assert.Nil(t, err)
+		nonces[i], public[i] = nonce, nonce.Public
+	}
+
+	session, err := crypto.NewMuSigSession(agg, hash, public)
+	assert.Nil(t, err)
+	partials := make([]*big.Int, len(ordered))
+	for i, key := range ordered {
+		partials[i], err = session.Sign(key, nonces[i])
+		assert.Nil(t, err)
+	}
+
+	sig, err := session.Aggregate(partials)
+	assert.Nil(t, err)
+	return sig
+}
@@ -21,7 +21,12 @@ func NewBlockValidator(bc *Blockchain) *BlockValidator {
 }
 
 // Esse é o coração da validação. Ele realiza várias verificações para garantir que o bloco seja válido antes de ser adicionado.
+// Blocos de ramos laterais são validados em relação ao pai (ver validateSideBlock).
 func (v *BlockValidator) ValidateBlock(b *Block) error {
+	if v.bc.isSideBlock(b) {
+		return v.validateSideBlock(b)
+	}
+
 	// Verifica se o bloco já existe
 	if v.bc.HasBlock(b.Height) {
 		return fmt.Errorf("chain already contains block (%d) with hash (%s)", b.Height, b.Hash(BlockHasher{}))
@@ -48,5 +53,65 @@ func (v *BlockValidator) ValidateBlock(b *Block) error {
 		return err
 	}
 
+	// Verifica se o bloco e as transações são desta rede.
+	if err := checkChainID(v.bc.ChainID(), b); err != nil {
+		return err
+	}
+
+	// Verifica se alguma transação já expirou.
+	if err := checkExpiry(b); err != nil {
+		return err
+	}
+
+	// Verifica se as transações de cada remetente seguem a sequência de nonces da blockchain.
+	return v.bc.checkNonces(b)
+}
+
+// Valida um bloco cujo pai é conhecido mas não é a ponta da cadeia: as mesmas verificações da ponta,
+// com a altura e a sequência de nonces relativas ao ramo que termina no pai.
+func (v *BlockValidator) validateSideBlock(b *Block) error {
+	parent, err := v.bc.GetBlock(b.PrevBlockHash)
+	if err != nil {
+		return err
+	}
+	if b.Height != parent.Height+1 {
+		return fmt.Errorf("block (%s) has height %d, parent has %d", b.Hash(BlockHasher{}), b.Height, parent.Height)
+	}
+
+	if err := b.Verify(); err != nil {
+		return err
+	}
+	if err := checkChainID(v.bc.ChainID(), b); err != nil {
+		return err
+	}
+	if err := checkExpiry(b); err != nil {
+		return err
+	}
+
+	return v.bc.checkBranchNonces(parent, b)
+}
+
+// Verifica se o bloco e todas as suas transações pertencem à rede chainID.
+func checkChainID(chainID uint32, b *Block) error {
+	if b.ChainID != chainID {
+		return fmt.Errorf("block (%s) has chain id %d, expected %d", b.Hash(BlockHasher{}), b.ChainID, chainID)
+	}
+	for i := range b.Transactions {
+		tx := &b.Transactions[i]
+		if tx.ChainID != chainID {
+			return fmt.Errorf("transaction (%s) has chain id %d, expected %d", tx.Hash(TxHasher{}), tx.ChainID, chainID)
+		}
+	}
+	return nil
+}
+
+// Verifica se todas as transações do bloco ainda são válidas na altura e no timestamp do bloco.
+func checkExpiry(b *Block) error {
+	for i := range b.Transactions {
+		tx := &b.Transactions[i]
+		if tx.Expired(b.Height, b.Timestamp) {
+			return fmt.Errorf("transaction (%s) expired before block (%d)", tx.Hash(TxHasher{}), b.Height)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+
+	"github.com/FelipePn10/fadden/types"
+)
+
+// Prova de trabalho anti-spam, no estilo hashcash: quem envia a transação procura um Stamp tal que
+// sha256(hash da transação || Stamp) comece com um número mínimo de bits zero. Verificar custa
+// um hash, mas encontrar o carimbo custa em média 2^bits hashes, o que torna caro inundar a rede.
+
+// Retorna o hash de trabalho da transação com o Stamp atual.
+func (tx *Transaction) WorkHash() types.Hash {
+	return workHash(tx.Hash(TxHasher{}), tx.Stamp)
+}
+
+// Retorna o número de bits zero no início do hash de trabalho.
+func (tx *Transaction) WorkBits() int {
+	return leadingZeroBits(tx.WorkHash())
+}
+
+// Número máximo de bits de trabalho: o tamanho do hash.
+const MaxWorkBits = 8 * len(types.Hash{})
+
+// Intervalo, em tentativas, entre as verificações de cancelamento do contexto em Mine.
+const mineCheckInterval = 1 << 12
+
+// Procura um Stamp cujo hash de trabalho tenha pelo menos workBits bits zero no início.
+// Deve ser chamado depois de todos os campos cobertos pelo hash da transação estarem definidos.
+// Retorna erro se workBits passar de MaxWorkBits, se ctx for cancelado ou se os carimbos acabarem.
+func (tx *Transaction) Mine(ctx context.Context, workBits int) error {
+	if workBits > MaxWorkBits {
+		return fmt.Errorf("work bits %d exceed the maximum of %d", workBits, MaxWorkBits)
+	}
+
+	hash := tx.Hash(TxHasher{})
+	for stamp := uint64(0); ; stamp++ {
+		if leadingZeroBits(workHash(hash, stamp)) >= workBits {
+			tx.Stamp = stamp
+			return nil
+		}
+		if stamp%mineCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if stamp == math.MaxUint64 {
+			return fmt.Errorf("no stamp with %d work bits found", workBits)
+		}
+	}
+}
+
+func workHash(txHash types.Hash, stamp uint64) types.Hash {
+	buf := make([]byte, len(txHash)+8)
+	copy(buf, txHash[:])
+	binary.BigEndian.PutUint64(buf[len(txHash):], stamp)
+	return types.Hash(sha256.Sum256(buf))
+}
+
+func leadingZeroBits(h types.Hash) int {
+	n := 0
+	for _, b := range h {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,82 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/FelipePn10/fadden/types"
+)
+
+// Esquema Ed25519 (RFC 8032). As assinaturas já são determinísticas e não são maleáveis
+// (a biblioteca padrão recusa s >= L), mas não permitem recuperar a chave pública:
+// transações assinadas com Ed25519 precisam levar o remetente em From.
+//
+// A chave privada guarda a ed25519.PrivateKey (semente + chave pública, 64 bytes) em Raw, e a
+// pública os 32 bytes do ponto. A assinatura de 64 bytes ocupa R (32 primeiros bytes) e S (32 últimos),
+// então ToBytes devolve exatamente a assinatura Ed25519.
+type ed25519Scheme struct{}
+
+func (ed25519Scheme) ID() SchemeID { return SchemeEd25519 }
+func (ed25519Scheme) Name() string { return "ed25519" }
+
+func (ed25519Scheme) GenerateKey(rand io.Reader) (PrivateKey, error) {
+	_, priv, err := ed25519.GenerateKey(rand)
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	return PrivateKey{Scheme: SchemeEd25519, Raw: priv}, nil
+}
+
+func (ed25519Scheme) PublicKey(k PrivateKey) PublicKey {
+	pub := ed25519.PrivateKey(k.Raw).Public().(ed25519.PublicKey)
+	return PublicKey{Scheme: SchemeEd25519, Raw: pub}
+}
+
+// A chave privada é serializada pela semente de 32 bytes.
+func (ed25519Scheme) PrivateKeyBytes(k PrivateKey) []byte {
+	return ed25519.PrivateKey(k.Raw).Seed()
+}
+
+func (ed25519Scheme) PrivateKeyFromBytes(b []byte) (PrivateKey, error) {
+	if len(b) != ed25519.SeedSize {
+		return PrivateKey{}, fmt.Errorf("ed25519 seed must have %d bytes, got %d", ed25519.SeedSize, len(b))
+	}
+	return PrivateKey{Scheme: SchemeEd25519, Raw: ed25519.NewKeyFromSeed(b)}, nil
+}
+
+func (ed25519Scheme) PublicKeyBytes(k PublicKey) []byte {
+	return k.Raw
+}
+
+func (ed25519Scheme) PublicKeyFromBytes(b []byte) (PublicKey, error) {
+	if len(b) != ed25519.PublicKeySize {
+		return PublicKey{}, fmt.Errorf("ed25519 public key must have %d bytes, got %d", ed25519.PublicKeySize, len(b))
+	}
+	return PublicKey{Scheme: SchemeEd25519, Raw: append([]byte{}, b...)}, nil
+}
+
+func (ed25519Scheme) SignHash(k PrivateKey, hash types.Hash) (*Signature, error) {
+	if len(k.Raw) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid ed25519 private key")
+	}
+	sig := ed25519.Sign(ed25519.PrivateKey(k.Raw), hash[:])
+	return &Signature{
+		R:      new(big.Int).SetBytes(sig[:32]),
+		S:      new(big.Int).SetBytes(sig[32:]),
+		Scheme: SchemeEd25519,
+	}, nil
+}
+
+func (ed25519Scheme) VerifyHash(k PublicKey, hash types.Hash, sig *Signature) bool {
+	if len(k.Raw) != ed25519.PublicKeySize || sig.R == nil || sig.S == nil || sig.R.Sign() < 0 || sig.S.Sign() < 0 ||
+		sig.R.BitLen() > 256 || sig.S.BitLen() > 256 || sig.V != 0 {
+		return false
+	}
+	b, err := sig.ToBytes()
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(k.Raw), hash[:], b)
+}
@@ -0,0 +1,177 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Derivação hierárquica determinística de chaves (BIP-32, generalizada para outras curvas pelo SLIP-10).
+// A partir da semente (ver MnemonicToSeed) obtemos a chave mestre; cada chave filha é derivada da mãe,
+// do seu "chain code" e de um índice de 32 bits, com HMAC-SHA512. Um caminho como m/44'/0'/0'/0/3
+// identifica uma chave da árvore, então uma carteira inteira pode ser restaurada da frase.
+//
+// Índices a partir de HardenedOffset (escritos com ' ou h) são "hardened": a derivação usa a chave
+// privada da mãe, e vazar uma chave filha junto com o chain code da mãe não compromete as irmãs.
+// A Ed25519 só admite derivação hardened.
+
+const HardenedOffset uint32 = 1 << 31
+
+// Chave da HMAC que gera a chave mestre de cada esquema (SLIP-10).
+var hdMasterSecrets = map[SchemeID]string{
+	SchemeP256:      "Nist256p1 seed",
+	SchemeEd25519:   "ed25519 seed",
+	SchemeSecp256k1: "Bitcoin seed",
+}
+
+// HDKey: chave privada de uma árvore de derivação.
+type HDKey struct {
+	Key       PrivateKey
+	ChainCode [32]byte
+	Depth     uint8  // Distância até a chave mestre
+	Index     uint32 // Índice usado para derivar esta chave a partir da mãe
+}
+
+// Deriva a chave mestre do esquema a partir da semente (16 a 64 bytes).
+func NewMasterKey(seed []byte, scheme SchemeID) (*HDKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("HD seed must have 16 to 64 bytes, got %d", len(seed))
+	}
+	secret, ok := hdMasterSecrets[scheme]
+	if !ok {
+		return nil, fmt.Errorf("HD derivation is not supported for %s keys", scheme)
+	}
+
+	I := hmacSHA512([]byte(secret), seed)
+	for {
+		key, err := PrivateKeyFromSchemeBytes(scheme, I[:32])
+		if err == nil {
+			master := &HDKey{Key: key}
+			copy(master.ChainCode[:], I[32:])
+			return master, nil
+		}
+		// Escalar inválido (zero ou >= n): o SLIP-10 repete o HMAC sobre o resultado.
+		I = hmacSHA512([]byte(secret), I)
+	}
+}
+
+// Deriva a chave filha com o índice informado.
+func (k *HDKey) Child(index uint32) (*HDKey, error) {
+	hardened := index >= HardenedOffset
+	if k.Key.Scheme == SchemeEd25519 && !hardened {
+		return nil, fmt.Errorf("ed25519 keys only support hardened derivation")
+	}
+	if k.Depth == 255 {
+		return nil, fmt.Errorf("HD derivation depth limit reached")
+	}
+
+	var data []byte
+	if hardened {
+		data = append([]byte{0x00}, k.Key.Bytes()...)
+	} else {
+		data = k.Key.PublicKey().ToSlice()
+		if k.Key.Scheme != SchemeP256 {
+			data = data[1:] // Sem o prefixo do esquema: o SLIP-10 usa o ponto SEC1 comprimido.
+		}
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	I := hmacSHA512(k.ChainCode[:], data)
+	for {
+		key, err := k.childKey(I[:32])
+		if err == nil {
+			child := &HDKey{Key: key, Depth: k.Depth + 1, Index: index}
+			copy(child.ChainCode[:], I[32:])
+			return child, nil
+		}
+		// Resultado inválido (raro): o SLIP-10 tenta de novo com 0x01 || IR || índice.
+		data = append([]byte{0x01}, I[32:]...)
+		data = binary.BigEndian.AppendUint32(data, index)
+		I = hmacSHA512(k.ChainCode[:], data)
+	}
+}
+
+// Calcula a chave filha a partir de IL: IL + chave da mãe (mod n) nas curvas ECDSA, ou o próprio IL na Ed25519.
+func (k *HDKey) childKey(il []byte) (PrivateKey, error) {
+	s := ecdsaSchemeByID(k.Key.Scheme)
+	if s == nil {
+		return PrivateKeyFromSchemeBytes(k.Key.Scheme, il)
+	}
+
+	n := s.curve.Params().N
+	tweak := new(big.Int).SetBytes(il)
+	if tweak.Cmp(n) >= 0 {
+		return PrivateKey{}, fmt.Errorf("derived tweak out of range")
+	}
+	d := tweak.Add(tweak, k.Key.Key.D)
+	d.Mod(d, n)
+	return PrivateKeyFromSchemeBytes(k.Key.Scheme, d.FillBytes(make([]byte, 32)))
+}
+
+// Deriva a chave de um caminho relativo a esta chave, como "m/44'/0'/0'/0/3" (ver ParseDerivationPath).
+func (k *HDKey) Derive(path string) (*HDKey, error) {
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := k
+	for _, i := range indexes {
+		if key, err = key.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// Lê um caminho de derivação como "m/44'/0'/0'/0/3". Índices terminados em ' ou h são hardened.
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q must start with m", path)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		offset := uint32(0)
+		if trimmed, ok := strings.CutSuffix(part, "'"); ok {
+			part, offset = trimmed, HardenedOffset
+		} else if trimmed, ok := strings.CutSuffix(part, "h"); ok {
+			part, offset = trimmed, HardenedOffset
+		}
+
+		i, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(i) >= HardenedOffset {
+			return nil, fmt.Errorf("invalid index %q in derivation path %q", part, path)
+		}
+		indexes = append(indexes, uint32(i)+offset)
+	}
+	return indexes, nil
+}
+
+// Deriva a chave de um caminho a partir de uma frase mnemônica (ver MnemonicToSeed).
+func KeyFromMnemonic(mnemonic, passphrase string, scheme SchemeID, path string) (PrivateKey, error) {
+	seed, err := MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	master, err := NewMasterKey(seed, scheme)
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	key, err := master.Derive(path)
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	return key.Key, nil
+}
+
+func hmacSHA512(key, data []byte) []byte {
+	m := hmac.New(sha512.New, key)
+	m.Write(data)
+	return m.Sum(nil)
+}
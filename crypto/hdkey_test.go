@@ -0,0 +1,108 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Vetor de teste 1 do BIP-32 / SLIP-10, para cada curva: chain code e chave privada de m e m/0'.
+func TestHDKeyVectors(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+
+	for _, v := range []struct {
+		scheme                 SchemeID
+		masterChain, masterKey string
+		childChain, childKey   string
+	}{
+		{
+			scheme:      SchemeSecp256k1,
+			masterChain: "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508",
+			masterKey:   "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
+			childChain:  "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141",
+			childKey:    "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea",
+		},
+		{
+			scheme:      SchemeP256,
+			masterChain: "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea",
+			masterKey:   "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2",
+			childChain:  "3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11",
+			childKey:    "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c",
+		},
+		{
+			scheme:      SchemeEd25519,
+			masterChain: "90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb",
+			masterKey:   "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7",
+			childChain:  "8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69",
+			childKey:    "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3",
+		},
+	} {
+		master, err := NewMasterKey(seed, v.scheme)
+		assert.Nil(t, err)
+		assert.Equal(t, v.masterChain, hex.EncodeToString(master.ChainCode[:]), v.scheme)
+		assert.Equal(t, v.masterKey, master.Key.Hex(), v.scheme)
+
+		child, err := master.Derive("m/0'")
+		assert.Nil(t, err)
+		assert.Equal(t, v.childChain, hex.EncodeToString(child.ChainCode[:]), v.scheme)
+		assert.Equal(t, v.childKey, child.Key.Hex(), v.scheme)
+		assert.Equal(t, uint8(1), child.Depth)
+		assert.Equal(t, HardenedOffset, child.Index)
+	}
+}
+
+// Vetor 1 do BIP-32: m/0'/1 usa derivação normal (a partir da chave pública da mãe).
+func TestHDKeyNormalDerivation(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMasterKey(seed, SchemeSecp256k1)
+	assert.Nil(t, err)
+
+	key, err := master.Derive("m/0h/1")
+	assert.Nil(t, err)
+	assert.Equal(t, "2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19", hex.EncodeToString(key.ChainCode[:]))
+	assert.Equal(t, "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368", key.Key.Hex())
+
+	ed, err := NewMasterKey(seed, SchemeEd25519)
+	assert.Nil(t, err)
+	_, err = ed.Derive("m/0'/1")
+	assert.NotNil(t, err)
+}
+
+func TestParseDerivationPath(t *testing.T) {
+	indexes, err := ParseDerivationPath("m/44'/0h/7")
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{44 + HardenedOffset, HardenedOffset, 7}, indexes)
+
+	indexes, err = ParseDerivationPath("m")
+	assert.Nil(t, err)
+	assert.Empty(t, indexes)
+
+	for _, path := range []string{"", "44'/0", "m/x", "m/-1", "m/2147483648", "m//1"} {
+		_, err := ParseDerivationPath(path)
+		assert.NotNil(t, err, path)
+	}
+}
+
+func TestKeyFromMnemonic(t *testing.T) {
+	mnemonic, err := NewMnemonic(128)
+	assert.Nil(t, err)
+
+	// A mesma frase restaura as mesmas chaves; outra senha ou outro caminho geram outras.
+	a, err := KeyFromMnemonic(mnemonic, "", SchemeP256, "m/44'/0'/0'/0/0")
+	assert.Nil(t, err)
+	b, err := KeyFromMnemonic(mnemonic, "", SchemeP256, "m/44'/0'/0'/0/0")
+	assert.Nil(t, err)
+	assert.Equal(t, a.Hex(), b.Hex())
+
+	c, err := KeyFromMnemonic(mnemonic, "other", SchemeP256, "m/44'/0'/0'/0/0")
+	assert.Nil(t, err)
+	assert.NotEqual(t, a.Hex(), c.Hex())
+	d, err := KeyFromMnemonic(mnemonic, "", SchemeP256, "m/44'/0'/0'/0/1")
+	assert.Nil(t, err)
+	assert.NotEqual(t, a.Hex(), d.Hex())
+
+	sig, err := a.Sign([]byte("foo"))
+	assert.Nil(t, err)
+	assert.True(t, sig.Verify(a.PublicKey(), []byte("foo")))
+}
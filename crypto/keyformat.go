@@ -0,0 +1,132 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Formatos de importação e exportação das chaves, para que um nó mantenha a mesma identidade entre reinícios:
+//   - Chave privada: PEM com PKCS#8 (o formato do openssl) ou o escalar em hexadecimal (32 bytes).
+//   - Chave pública: SEC1 comprimido (33 bytes, 0x02/0x03 + X) ou não comprimido (65 bytes, 0x04 + X + Y).
+//   - Chaves de outros esquemas (ver Scheme): um byte schemeKeyPrefix + ID do esquema, seguido da chave
+//     no formato do esquema. O prefixo não colide com os prefixos SEC1 (0x02, 0x03 e 0x04).
+// Toda chave importada é validada: escalares fora de [1, n-1] e pontos fora da curva são recusados.
+
+const (
+	pemPrivateKeyType = "PRIVATE KEY"
+	schemeKeyPrefix   = 0x80
+)
+
+// Exporta a chave privada em PEM (PKCS#8).
+func (k PrivateKey) MarshalPEM() ([]byte, error) {
+	if k.Scheme != SchemeP256 {
+		return nil, fmt.Errorf("PEM export is only supported for %s keys, not %s", SchemeP256, k.Scheme)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.Key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemPrivateKeyType, Bytes: der}), nil
+}
+
+// Importa uma chave privada P-256 exportada por MarshalPEM.
+func PrivateKeyFromPEM(data []byte) (PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return PrivateKey{}, fmt.Errorf("no PEM data found")
+	}
+	if block.Type != pemPrivateKeyType {
+		return PrivateKey{}, fmt.Errorf("unexpected PEM block type %q", block.Type)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok || key.Curve != elliptic.P256() {
+		return PrivateKey{}, fmt.Errorf("PEM does not contain a P-256 ECDSA private key")
+	}
+
+	// Recria a chave a partir do escalar, garantindo que a chave pública corresponde a ele.
+	return privateKeyFromScalar(key.D.FillBytes(make([]byte, 32)))
+}
+
+// Exporta a chave privada em hexadecimal (ver PrivateKey.Bytes; 64 caracteres nos esquemas embutidos).
+// O esquema não faz parte do formato: PrivateKeyFromHex lê chaves P-256.
+func (k PrivateKey) Hex() string {
+	return hex.EncodeToString(k.Bytes())
+}
+
+// Importa uma chave privada exportada por Hex. O prefixo "0x" é opcional.
+func PrivateKeyFromHex(s string) (PrivateKey, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(s), "0x"))
+	if err != nil {
+		return PrivateKey{}, fmt.Errorf("invalid private key hex: %w", err)
+	}
+	return privateKeyFromScalar(b)
+}
+
+func privateKeyFromScalar(b []byte) (PrivateKey, error) {
+	if len(b) != 32 {
+		return PrivateKey{}, fmt.Errorf("private key must have 32 bytes, got %d", len(b))
+	}
+
+	// O ecdh valida que o escalar está em [1, n-1] e calcula o ponto público correspondente.
+	key, err := ecdh.P256().NewPrivateKey(b)
+	if err != nil {
+		return PrivateKey{}, fmt.Errorf("invalid private key: %w", err)
+	}
+	pub, err := PublicKeyFromBytes(key.PublicKey().Bytes())
+	if err != nil {
+		return PrivateKey{}, err
+	}
+
+	return PrivateKey{Key: &ecdsa.PrivateKey{
+		PublicKey: *pub.Key,
+		D:         new(big.Int).SetBytes(b),
+	}}, nil
+}
+
+// Serializa a chave pública no formato SEC1 não comprimido (65 bytes). Nas chaves de outros esquemas,
+// o prefixo do esquema é mantido; chaves que não são ECDSA não têm forma não comprimida (ver ToSlice).
+func (k PublicKey) ToUncompressedSlice() []byte {
+	if k.Key == nil {
+		return k.ToSlice()
+	}
+	b := elliptic.Marshal(k.Key, k.Key.X, k.Key.Y)
+	if k.Scheme != SchemeP256 {
+		b = append([]byte{schemeKeyPrefix | byte(k.Scheme)}, b...)
+	}
+	return b
+}
+
+// Importa uma chave pública serializada por ToSlice ou ToUncompressedSlice. Sem o prefixo de esquema,
+// a chave é P-256 no formato SEC1, comprimida (33 bytes) ou não comprimida (65 bytes), e o ponto
+// precisa estar na curva.
+func PublicKeyFromBytes(b []byte) (PublicKey, error) {
+	if len(b) > 0 && b[0]&schemeKeyPrefix != 0 {
+		s, err := SchemeByID(SchemeID(b[0] &^ schemeKeyPrefix))
+		if err != nil {
+			return PublicKey{}, err
+		}
+		return s.PublicKeyFromBytes(b[1:])
+	}
+	return p256Scheme.PublicKeyFromBytes(b)
+}
+
+// Importa uma chave pública SEC1 em hexadecimal.
+func PublicKeyFromHex(s string) (PublicKey, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(s), "0x"))
+	if err != nil {
+		return PublicKey{}, fmt.Errorf("invalid public key hex: %w", err)
+	}
+	return PublicKeyFromBytes(b)
+}
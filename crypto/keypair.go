@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -16,36 +17,52 @@ import (
 //----------------------------------------// //----------------------------------------// //----------------------------------------// //----------------------------------------//
 
 // Estrutura que representa uma chave privada.
+// Key guarda as chaves ECDSA (P-256 e secp256k1); os demais esquemas usam Raw (ver Scheme).
 type PrivateKey struct {
-	Key *ecdsa.PrivateKey
+	Key    *ecdsa.PrivateKey
+	Scheme SchemeID // Esquema da chave (zero = P-256)
+	Raw    []byte
 }
 
 // Estrutura que representa uma chave pública. E usada para verificar assinaturas e derivar endereços.
 type PublicKey struct {
-	Key *ecdsa.PublicKey
+	Key    *ecdsa.PublicKey
+	Scheme SchemeID // Esquema da chave (zero = P-256)
+	Raw    []byte
 }
 
 // --- Tanto o Private Key como o Public Key, são estruturas padrões em GO para representar chaves privadas e públicas de criptografia ECDSA. --- //
 
-// Estrutura que representa uma assinatura ECDSA, contendo os valores r e s.
+// Estrutura que representa uma assinatura ECDSA, contendo os valores r e s (na Ed25519, as duas metades da assinatura).
 // Representa uma assinatura digital para validação.
 type Signature struct {
 	S, R *big.Int // big.Int: Representa um número inteiro grande. É usado para armazenar os valores r e s da assinatura.
+	V    byte     // ID de recuperação da chave pública (0 a 3), usado no formato recuperável
+
+	Scheme SchemeID // Esquema que gerou a assinatura (zero = P-256)
+}
+
+// Função de resumo usada antes de assinar mensagens (hash-then-sign).
+func Digest(data []byte) types.Hash {
+	return types.Hash(sha256.Sum256(data))
 }
 
-// Assina dados usando a chave privada ECDSA.
-// Sign assina um hash (que deve ser o resultado do hash de uma mensagem maior) usando a chave privada, priv. Se o hash for maior que o comprimento de bits da ordem da curva da chave privada, o hash será truncado para esse comprimento. Ele retorna a assinatura como um par de inteiros. A maioria dos aplicativos deve usar [SignASN1] em vez de lidar diretamente com r, s.
-// rand.Reader: Garante que a geração da assinatura seja segura.
+// Assina dados usando a chave privada ECDSA. Os dados são resumidos com Digest antes de assinar:
+// o ecdsa.Sign trunca a entrada no tamanho da ordem da curva (32 bytes na P-256), então assinar
+// a mensagem diretamente faria mensagens com os mesmos 32 primeiros bytes terem a mesma assinatura.
 func (k PrivateKey) Sign(data []byte) (*Signature, error) {
-	r, s, err := ecdsa.Sign(rand.Reader, k.Key, data) // ecdsa.Sign: Gera os componentes r e s da assinatura.
-	if err != nil {                                   // Verifica se houve algum erro na geração da assinatura.
+	return k.SignHash(Digest(data))
+}
+
+// Assina um hash de 32 bytes já calculado (ex: o hash de assinatura de uma transação), com o esquema da chave.
+// Nas chaves ECDSA, o nonce segue o RFC 6979 misturado a bytes do rand.Reader (ver SignHashHedged): uma fonte
+// de entropia fraca não compromete a chave. Para assinaturas reproduzíveis, use SignHashDeterministic.
+func (k PrivateKey) SignHash(hash types.Hash) (*Signature, error) {
+	s, err := SchemeByID(k.Scheme)
+	if err != nil {
 		return nil, err
 	}
-
-	return &Signature{ // Retorna a assinatura gerada.
-		R: r,
-		S: s,
-	}, nil // Retorna nil para o erro, indicando que a assinatura foi gerada com sucesso.
+	return s.SignHash(k, hash)
 }
 
 // Gera uma chave privada ECDSA usando a curva P-256 (secp256r1).
@@ -65,18 +82,59 @@ func GeneratePrivateKey() PrivateKey {
 // A chave pública é derivada diretamente da chave privada no ECDSA.
 // A chave pública é usada para verificar assinaturas e derivar endereços.
 func (k PrivateKey) PublicKey() PublicKey {
-	return PublicKey{
-		Key: &k.Key.PublicKey,
-	}
+	return mustScheme(k.Scheme).PublicKey(k)
 }
 
 // Serializa a chave pública em um formato compacto (bytes).
 // Retorna a chave pública serializada em um formato compacto.
 // A chave pública é serializada em um formato compacto (33 bytes) que inclui o prefixo de compressão.
 // O prefixo de compressão é um byte que indica se a chave pública é par ou ímpar.
+// Chaves de outros esquemas são prefixadas por schemeKeyPrefix + ID do esquema (ver PublicKeyFromBytes).
 func (k PublicKey) ToSlice() []byte {
-	return elliptic.MarshalCompressed(k.Key, k.Key.X, k.Key.Y)
-	// elliptic.MarshalCompressed: Converte as coordenadas (x, y) da chave pública em bytes compactos (ex: 0x02 ou 0x03 + coordenada x).
+	if k.Scheme == SchemeP256 {
+		return elliptic.MarshalCompressed(k.Key, k.Key.X, k.Key.Y)
+		// elliptic.MarshalCompressed: Converte as coordenadas (x, y) da chave pública em bytes compactos (ex: 0x02 ou 0x03 + coordenada x).
+	}
+	return append([]byte{schemeKeyPrefix | byte(k.Scheme)}, mustScheme(k.Scheme).PublicKeyBytes(k)...)
+}
+
+// Indica se a chave está vazia (ex: o From omitido de uma transação).
+func (k PublicKey) IsZero() bool {
+	return k.Key == nil && k.Raw == nil
+}
+
+// Compara duas chaves públicas, inclusive o esquema.
+func (k PublicKey) Equal(other PublicKey) bool {
+	if k.IsZero() || other.IsZero() {
+		return k.IsZero() && other.IsZero()
+	}
+	return bytes.Equal(k.ToSlice(), other.ToSlice())
+}
+
+// GobEncode: Serializa a chave pública no formato compacto para o gob.
+// O gob não consegue serializar a curva elíptica de um ecdsa.PublicKey, então a chave trafega como bytes.
+// Uma chave vazia é serializada como um slice vazio.
+func (k PublicKey) GobEncode() ([]byte, error) {
+	if k.IsZero() {
+		return []byte{}, nil
+	}
+	return k.ToSlice(), nil
+}
+
+// GobDecode: Desserializa uma chave pública serializada por GobEncode.
+func (k *PublicKey) GobDecode(b []byte) error {
+	if len(b) == 0 {
+		*k = PublicKey{}
+		return nil
+	}
+
+	pub, err := PublicKeyFromBytes(b)
+	if err != nil {
+		return err
+	}
+
+	*k = pub
+	return nil
 }
 
 // Deriva um endereço (ex: de uma carteira) a partir da chave pública.
@@ -89,6 +147,20 @@ func (k PublicKey) Address() types.Address {
 	return types.AddressFromBytes(h[len(h)-28:]) // Retorna o endereço derivado da chave pública. (28 bytes)
 }
 
-func (sig Signature) Verify(pubKey PublicKey, data []byte) bool { // Verifica se uma assinatura é válida para os dados e chave pública fornecidos.
-	return ecdsa.Verify(pubKey.Key, data, sig.R, sig.S) // ecdsa.Verify: Retorna true se a assinatura for válida.
+// Verifica se uma assinatura feita com Sign é válida para os dados e chave pública fornecidos.
+func (sig Signature) Verify(pubKey PublicKey, data []byte) bool {
+	return sig.VerifyHash(pubKey, Digest(data))
+}
+
+// Verifica uma assinatura feita com SignHash, usando o esquema da chave pública.
+// A assinatura precisa ser do mesmo esquema da chave.
+func (sig Signature) VerifyHash(pubKey PublicKey, hash types.Hash) bool {
+	if pubKey.IsZero() || sig.Scheme != pubKey.Scheme {
+		return false
+	}
+	s, err := SchemeByID(pubKey.Scheme)
+	if err != nil {
+		return false
+	}
+	return s.VerifyHash(pubKey, hash, &sig)
 }
@@ -36,3 +36,28 @@ func TestKeypaiSignVerifyFail(t *testing.T) {
 	assert.False(t, sig.Verify(PublicKey, []byte("Hello, World")))
 
 }
+
+// TestSignLongMessages: Mensagens que só diferem depois dos 32 primeiros bytes não podem
+// compartilhar a assinatura (o ecdsa.Sign truncaria a mensagem se ela não fosse resumida antes).
+func TestSignLongMessages(t *testing.T) {
+	privKey := GeneratePrivateKey()
+	a := []byte("0123456789abcdef0123456789abcdef-pay alice")
+	b := []byte("0123456789abcdef0123456789abcdef-pay mallory")
+
+	sig, err := privKey.Sign(a)
+	assert.Nil(t, err)
+	assert.True(t, sig.Verify(privKey.PublicKey(), a))
+	assert.False(t, sig.Verify(privKey.PublicKey(), b))
+}
+
+func TestSignHashVerifyHash(t *testing.T) {
+	privKey := GeneratePrivateKey()
+	msg := []byte("Hello, World!")
+	hash := Digest(msg)
+
+	sig, err := privKey.SignHash(hash)
+	assert.Nil(t, err)
+	assert.True(t, sig.VerifyHash(privKey.PublicKey(), hash))
+	assert.True(t, sig.Verify(privKey.PublicKey(), msg))
+	assert.False(t, sig.VerifyHash(privKey.PublicKey(), Digest([]byte("other"))))
+}
@@ -0,0 +1,317 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/FelipePn10/fadden/types"
+)
+
+// Keystore: arquivos JSON com uma chave privada cifrada por uma senha.
+// A senha passa pelo scrypt (ver scryptKey) para gerar uma chave AES-256, e a chave privada é cifrada
+// com AES-GCM. O GCM autentica o conteúdo: uma senha errada ou qualquer alteração no arquivo
+// (inclusive no endereço e nos parâmetros do scrypt, que entram como dados adicionais) faz a decifragem falhar.
+
+const keystoreVersion = 1
+
+// Parâmetros do scrypt. StandardScrypt custa cerca de 256MB e um segundo por chave;
+// LightScrypt é para testes e ambientes com pouca memória.
+var (
+	StandardScrypt = ScryptParams{N: 1 << 18, R: 8, P: 1}
+	LightScrypt    = ScryptParams{N: 1 << 12, R: 8, P: 1}
+)
+
+var (
+	ErrDecrypt         = errors.New("could not decrypt key with given passphrase")
+	ErrAccountNotFound = errors.New("account not found in keystore")
+)
+
+type ScryptParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+// Recusa parâmetros acima de StandardScrypt: um arquivo do keystore não pode obrigar o nó a gastar
+// mais memória ou tempo do que o padrão só para descobrir que a senha está errada.
+func (p ScryptParams) validate() error {
+	if p.N <= 0 || p.R <= 0 || p.P <= 0 {
+		return fmt.Errorf("invalid scrypt parameters (n=%d, r=%d, p=%d)", p.N, p.R, p.P)
+	}
+	if p.N > StandardScrypt.N || p.R > StandardScrypt.R || p.P > StandardScrypt.P {
+		return fmt.Errorf("scrypt parameters (n=%d, r=%d, p=%d) exceed the maximum (n=%d, r=%d, p=%d)",
+			p.N, p.R, p.P, StandardScrypt.N, StandardScrypt.R, StandardScrypt.P)
+	}
+	return nil
+}
+
+// Formato do arquivo do keystore. Os campos binários são hexadecimais.
+// Scheme é o nome do esquema da chave (ver Scheme), omitido nas chaves P-256.
+type encryptedKeyJSON struct {
+	Version int    `json:"version"`
+	Address string `json:"address"`
+	Scheme  string `json:"scheme,omitempty"`
+	Crypto  struct {
+		Cipher     string       `json:"cipher"`
+		CipherText string       `json:"ciphertext"`
+		Nonce      string       `json:"nonce"`
+		KDF        string       `json:"kdf"`
+		KDFParams  ScryptParams `json:"kdfparams"`
+		Salt       string       `json:"salt"`
+	} `json:"crypto"`
+}
+
+// Cifra a chave privada com a senha e retorna o conteúdo do arquivo do keystore.
+// Os parâmetros do scrypt não podem passar de StandardScrypt.
+func EncryptKey(key PrivateKey, passphrase string, params ScryptParams) ([]byte, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	ek := &encryptedKeyJSON{
+		Version: keystoreVersion,
+		Address: key.PublicKey().Address().String(),
+	}
+	if key.Scheme != SchemeP256 {
+		ek.Scheme = key.Scheme.String()
+	}
+	ek.Crypto.Cipher = "aes-256-gcm"
+	ek.Crypto.KDF = "scrypt"
+	ek.Crypto.KDFParams = params
+	ek.Crypto.Salt = hex.EncodeToString(salt)
+
+	aead, err := keystoreCipher(passphrase, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	ek.Crypto.Nonce = hex.EncodeToString(nonce)
+	ek.Crypto.CipherText = hex.EncodeToString(aead.Seal(nil, nonce, key.Bytes(), ek.additionalData()))
+
+	return json.MarshalIndent(ek, "", "  ")
+}
+
+// Decifra um arquivo do keystore. Retorna ErrDecrypt se a senha estiver errada ou o arquivo tiver sido alterado.
+// Arquivos com parâmetros do scrypt acima de StandardScrypt são recusados antes de derivar a chave.
+func DecryptKey(data []byte, passphrase string) (PrivateKey, error) {
+	ek := new(encryptedKeyJSON)
+	if err := json.Unmarshal(data, ek); err != nil {
+		return PrivateKey{}, fmt.Errorf("invalid keystore file: %w", err)
+	}
+	if ek.Version != keystoreVersion || ek.Crypto.Cipher != "aes-256-gcm" || ek.Crypto.KDF != "scrypt" {
+		return PrivateKey{}, fmt.Errorf("unsupported keystore format (version %d, cipher %q, kdf %q)",
+			ek.Version, ek.Crypto.Cipher, ek.Crypto.KDF)
+	}
+
+	scheme := Scheme(p256Scheme)
+	if ek.Scheme != "" {
+		s, err := SchemeByName(ek.Scheme)
+		if err != nil {
+			return PrivateKey{}, err
+		}
+		scheme = s
+	}
+
+	salt, err := hex.DecodeString(ek.Crypto.Salt)
+	if err != nil {
+		return PrivateKey{}, fmt.Errorf("invalid keystore salt: %w", err)
+	}
+	nonce, err := hex.DecodeString(ek.Crypto.Nonce)
+	if err != nil {
+		return PrivateKey{}, fmt.Errorf("invalid keystore nonce: %w", err)
+	}
+	ciphertext, err := hex.DecodeString(ek.Crypto.CipherText)
+	if err != nil {
+		return PrivateKey{}, fmt.Errorf("invalid keystore ciphertext: %w", err)
+	}
+
+	if err := ek.Crypto.KDFParams.validate(); err != nil {
+		return PrivateKey{}, err
+	}
+	aead, err := keystoreCipher(passphrase, salt, ek.Crypto.KDFParams)
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return PrivateKey{}, fmt.Errorf("invalid keystore nonce size %d", len(nonce))
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, ek.additionalData())
+	if err != nil {
+		return PrivateKey{}, ErrDecrypt
+	}
+
+	key, err := scheme.PrivateKeyFromBytes(plaintext)
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	if key.PublicKey().Address().String() != ek.Address {
+		return PrivateKey{}, fmt.Errorf("keystore address %s does not match the decrypted key", ek.Address)
+	}
+
+	return key, nil
+}
+
+// Dados autenticados junto com a chave: alterar o endereço, o esquema ou os parâmetros invalida o arquivo.
+func (ek *encryptedKeyJSON) additionalData() []byte {
+	p := ek.Crypto.KDFParams
+	ad := fmt.Sprintf("%d|%s|%s|%d|%d|%d", ek.Version, ek.Address, ek.Crypto.KDF, p.N, p.R, p.P)
+	if ek.Scheme != "" {
+		ad += "|" + ek.Scheme
+	}
+	return []byte(ad)
+}
+
+func keystoreCipher(passphrase string, salt []byte, params ScryptParams) (cipher.AEAD, error) {
+	derived, err := scryptKey([]byte(passphrase), salt, params.N, params.R, params.P, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// KeystoreOpts define as opções do diretório de chaves (padrão: StandardScrypt).
+type KeystoreOpts struct {
+	Scrypt ScryptParams
+}
+
+// Keystore: Diretório com um arquivo cifrado por conta, nomeado pelo endereço (<endereço>.json).
+type Keystore struct {
+	dir  string
+	opts KeystoreOpts
+}
+
+func NewKeystore(dir string, opts KeystoreOpts) (*Keystore, error) {
+	if opts.Scrypt == (ScryptParams{}) {
+		opts.Scrypt = StandardScrypt
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &Keystore{dir: dir, opts: opts}, nil
+}
+
+// Lista os endereços das contas do diretório, em ordem.
+func (ks *Keystore) Accounts() ([]types.Address, error) {
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []types.Address
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		b, err := hex.DecodeString(name)
+		if err != nil || len(b) != len(types.Address{}) {
+			continue
+		}
+		accounts = append(accounts, types.AddressFromBytes(b))
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].String() < accounts[j].String()
+	})
+	return accounts, nil
+}
+
+// Gera uma nova chave P-256 e a guarda cifrada com a senha.
+func (ks *Keystore) NewAccount(passphrase string) (types.Address, error) {
+	return ks.Import(GeneratePrivateKey(), passphrase)
+}
+
+// Guarda uma chave existente cifrada com a senha. Falha se a conta já existir.
+// O arquivo é escrito e gravado em disco (Sync) com um nome temporário e só então ligado ao nome
+// final, assim uma escrita interrompida nunca deixa um arquivo de conta incompleto.
+func (ks *Keystore) Import(key PrivateKey, passphrase string) (types.Address, error) {
+	addr := key.PublicKey().Address()
+
+	data, err := EncryptKey(key, passphrase, ks.opts.Scrypt)
+	if err != nil {
+		return types.Address{}, err
+	}
+
+	f, err := os.CreateTemp(ks.dir, "."+addr.String()+".tmp-*")
+	if err != nil {
+		return types.Address{}, err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if err := writeKeyFile(f, data); err != nil {
+		return types.Address{}, err
+	}
+
+	// Diferente de um rename, o link falha se a conta já existir.
+	err = os.Link(tmp, ks.path(addr))
+	if errors.Is(err, os.ErrExist) {
+		return types.Address{}, fmt.Errorf("account %s already exists", addr)
+	}
+	if err != nil {
+		return types.Address{}, err
+	}
+
+	return addr, nil
+}
+
+func writeKeyFile(f *os.File, data []byte) error {
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Decifra a chave da conta com a senha.
+func (ks *Keystore) Unlock(addr types.Address, passphrase string) (PrivateKey, error) {
+	data, err := os.ReadFile(ks.path(addr))
+	if errors.Is(err, os.ErrNotExist) {
+		return PrivateKey{}, fmt.Errorf("%w: %s", ErrAccountNotFound, addr)
+	}
+	if err != nil {
+		return PrivateKey{}, err
+	}
+
+	key, err := DecryptKey(data, passphrase)
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	if key.PublicKey().Address() != addr {
+		return PrivateKey{}, fmt.Errorf("keystore file for %s contains another key", addr)
+	}
+	return key, nil
+}
+
+func (ks *Keystore) path(addr types.Address) string {
+	return filepath.Join(ks.dir, addr.String()+".json")
+}
@@ -0,0 +1,144 @@
+package crypto
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FelipePn10/fadden/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptDecryptKey(t *testing.T) {
+	privKey := GeneratePrivateKey()
+	data, err := EncryptKey(privKey, "correct horse", LightScrypt)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), privKey.Hex())
+
+	decrypted, err := DecryptKey(data, "correct horse")
+	assert.Nil(t, err)
+	assert.Equal(t, privKey.Key.D, decrypted.Key.D)
+
+	_, err = DecryptKey(data, "wrong horse")
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestDecryptKeyDetectsTampering(t *testing.T) {
+	data, err := EncryptKey(GeneratePrivateKey(), "pass", LightScrypt)
+	assert.Nil(t, err)
+
+	for name, tamper := range map[string]func(*encryptedKeyJSON){
+		"ciphertext": func(ek *encryptedKeyJSON) {
+			b := []byte(ek.Crypto.CipherText)
+			b[0] ^= 1
+			ek.Crypto.CipherText = string(b)
+		},
+		"address": func(ek *encryptedKeyJSON) {
+			ek.Address = GeneratePrivateKey().PublicKey().Address().String()
+		},
+		"kdf params": func(ek *encryptedKeyJSON) {
+			ek.Crypto.KDFParams.N *= 2
+		},
+	} {
+		ek := new(encryptedKeyJSON)
+		assert.Nil(t, json.Unmarshal(data, ek))
+		tamper(ek)
+		tampered, err := json.Marshal(ek)
+		assert.Nil(t, err)
+
+		_, err = DecryptKey(tampered, "pass")
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestDecryptKeyRejectsExpensiveKDFParams(t *testing.T) {
+	data, err := EncryptKey(GeneratePrivateKey(), "pass", LightScrypt)
+	assert.Nil(t, err)
+
+	for _, params := range []ScryptParams{
+		{N: 1 << 30, R: 8, P: 1},
+		{N: 1 << 12, R: 1 << 20, P: 1},
+		{N: 1 << 12, R: 8, P: 1 << 20},
+		{N: 0, R: 8, P: 1},
+	} {
+		ek := new(encryptedKeyJSON)
+		assert.Nil(t, json.Unmarshal(data, ek))
+		ek.Crypto.KDFParams = params
+		tampered, err := json.Marshal(ek)
+		assert.Nil(t, err)
+
+		// A recusa vem antes do scrypt: não é ErrDecrypt.
+		_, err = DecryptKey(tampered, "pass")
+		assert.NotNil(t, err)
+		assert.NotErrorIs(t, err, ErrDecrypt)
+	}
+
+	_, err = EncryptKey(GeneratePrivateKey(), "pass", ScryptParams{N: 1 << 19, R: 8, P: 1})
+	assert.NotNil(t, err)
+}
+
+func TestKeystoreAccounts(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "keystore")
+	ks, err := NewKeystore(dir, KeystoreOpts{Scrypt: LightScrypt})
+	assert.Nil(t, err)
+
+	accounts, err := ks.Accounts()
+	assert.Nil(t, err)
+	assert.Empty(t, accounts)
+
+	a, err := ks.NewAccount("pass-a")
+	assert.Nil(t, err)
+	imported := GeneratePrivateKey()
+	b, err := ks.Import(imported, "pass-b")
+	assert.Nil(t, err)
+	assert.Equal(t, imported.PublicKey().Address(), b)
+
+	_, err = ks.Import(imported, "other")
+	assert.NotNil(t, err)
+
+	// Arquivos que não são contas são ignorados.
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hi"), 0o600))
+
+	accounts, err = ks.Accounts()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, accounts, []types.Address{a, b})
+
+	key, err := ks.Unlock(b, "pass-b")
+	assert.Nil(t, err)
+	assert.Equal(t, imported.Key.D, key.Key.D)
+
+	_, err = ks.Unlock(a, "pass-b")
+	assert.ErrorIs(t, err, ErrDecrypt)
+	_, err = ks.Unlock(GeneratePrivateKey().PublicKey().Address(), "pass-a")
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+}
+
+func TestKeystoreImportIsAtomic(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := NewKeystore(dir, KeystoreOpts{Scrypt: LightScrypt})
+	assert.Nil(t, err)
+
+	// Restos de uma escrita interrompida não impedem a importação nem aparecem como contas.
+	key := GeneratePrivateKey()
+	addr := key.PublicKey().Address()
+	stray := filepath.Join(dir, "."+addr.String()+".tmp-1")
+	assert.Nil(t, os.WriteFile(stray, []byte("{\"version\""), 0o600))
+
+	imported, err := ks.Import(key, "pass")
+	assert.Nil(t, err)
+	assert.Equal(t, addr, imported)
+
+	accounts, err := ks.Accounts()
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{addr}, accounts)
+
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2) // A conta e o resto antigo; o arquivo temporário da importação foi removido.
+
+	_, err = ks.Import(key, "pass")
+	assert.NotNil(t, err)
+	_, err = ks.Unlock(addr, "pass")
+	assert.Nil(t, err)
+}
@@ -0,0 +1,133 @@
+package crypto
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+// Frases mnemônicas (BIP-39). A entropia (128 a 256 bits) recebe um checksum com os primeiros
+// bits do seu SHA-256 (1 bit a cada 32 de entropia) e é dividida em grupos de 11 bits, cada um
+// indexando uma palavra da lista de 2048 palavras. Assim, 128 bits viram 12 palavras e 256 bits, 24.
+// A frase é convertida em uma semente de 64 bytes com PBKDF2-HMAC-SHA512 (2048 iterações), que
+// alimenta a derivação hierárquica das chaves (ver NewMasterKey).
+//
+// A lista é a lista inglesa oficial do BIP-39, então as frases são compatíveis com outras carteiras.
+// O BIP-39 pede a normalização NFKD da frase e da senha. A frase usa só palavras em ASCII e a senha
+// precisa ser ASCII, para a qual a normalização não muda nada: uma senha com acentos daria aqui uma
+// semente diferente da de outras carteiras e é recusada com ErrMnemonicPassphrase.
+
+//go:embed wordlists/english.txt
+var englishWordlist string
+
+var (
+	mnemonicWords = strings.Fields(englishWordlist)
+	mnemonicIndex = func() map[string]int {
+		index := make(map[string]int, len(mnemonicWords))
+		for i, w := range mnemonicWords {
+			index[w] = i
+		}
+		return index
+	}()
+)
+
+var (
+	ErrInvalidMnemonic    = errors.New("invalid mnemonic")
+	ErrMnemonicChecksum   = errors.New("mnemonic checksum mismatch")
+	ErrMnemonicPassphrase = errors.New("mnemonic passphrase must be ASCII")
+)
+
+// Gera uma frase mnemônica com bits de entropia (128, 160, 192, 224 ou 256).
+func NewMnemonic(bits int) (string, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", fmt.Errorf("mnemonic entropy must be 128 to 256 bits in steps of 32, got %d", bits)
+	}
+
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return MnemonicFromEntropy(entropy)
+}
+
+// Converte a entropia (16 a 32 bytes, múltiplo de 4) em uma frase mnemônica.
+func MnemonicFromEntropy(entropy []byte) (string, error) {
+	n := len(entropy)
+	if n < 16 || n > 32 || n%4 != 0 {
+		return "", fmt.Errorf("mnemonic entropy must be 16 to 32 bytes in steps of 4, got %d", n)
+	}
+
+	// Concatena a entropia e os bits do checksum em um inteiro e lê 11 bits por palavra.
+	checksumBits := n / 4
+	h := sha256.Sum256(entropy)
+	x := new(big.Int).SetBytes(entropy)
+	x.Lsh(x, uint(checksumBits))
+	x.Or(x, big.NewInt(int64(h[0]>>(8-checksumBits))))
+
+	count := (n*8 + checksumBits) / 11
+	words := make([]string, count)
+	mask := big.NewInt(2047)
+	for i := count - 1; i >= 0; i-- {
+		words[i] = mnemonicWords[new(big.Int).And(x, mask).Int64()]
+		x.Rsh(x, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// Converte a frase de volta na entropia, validando as palavras e o checksum.
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("%w: expected 12, 15, 18, 21 or 24 words, got %d", ErrInvalidMnemonic, len(words))
+	}
+
+	x := new(big.Int)
+	for _, w := range words {
+		i, ok := mnemonicIndex[w]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown word %q", ErrInvalidMnemonic, w)
+		}
+		x.Lsh(x, 11)
+		x.Or(x, big.NewInt(int64(i)))
+	}
+
+	checksumBits := len(words) / 3
+	checksum := new(big.Int).And(x, big.NewInt(1<<checksumBits-1)).Int64()
+	x.Rsh(x, uint(checksumBits))
+
+	entropy := x.FillBytes(make([]byte, checksumBits*4))
+	h := sha256.Sum256(entropy)
+	if int64(h[0]>>(8-checksumBits)) != checksum {
+		return nil, ErrMnemonicChecksum
+	}
+	return entropy, nil
+}
+
+// Valida as palavras e o checksum da frase.
+func ValidateMnemonic(mnemonic string) error {
+	_, err := MnemonicToEntropy(mnemonic)
+	return err
+}
+
+// Deriva a semente de 64 bytes da frase e de uma senha opcional. A frase é validada antes:
+// um erro de digitação seria, de outra forma, uma carteira diferente (e vazia).
+// Senhas com caracteres fora do ASCII retornam ErrMnemonicPassphrase.
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(passphrase); i++ {
+		if passphrase[i] >= utf8.RuneSelf {
+			return nil, ErrMnemonicPassphrase
+		}
+	}
+	normalized := strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+	return pbkdf2.Key(sha512.New, normalized, []byte("mnemonic"+passphrase), 2048, 64)
+}
@@ -0,0 +1,88 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Vetores do BIP-39 (lista inglesa, senha "TREZOR").
+func TestMnemonicVectors(t *testing.T) {
+	for _, v := range []struct {
+		entropy, mnemonic, seed string
+	}{
+		{
+			entropy:  "00000000000000000000000000000000",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			seed:     "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellow",
+			seed:     "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			entropy:  "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+			mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+			seed:     "dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad",
+		},
+	} {
+		entropy, _ := hex.DecodeString(v.entropy)
+		mnemonic, err := MnemonicFromEntropy(entropy)
+		assert.Nil(t, err)
+		assert.Equal(t, v.mnemonic, mnemonic)
+
+		decoded, err := MnemonicToEntropy(mnemonic)
+		assert.Nil(t, err)
+		assert.Equal(t, entropy, decoded)
+
+		seed, err := MnemonicToSeed(mnemonic, "TREZOR")
+		assert.Nil(t, err)
+		assert.Equal(t, v.seed, hex.EncodeToString(seed))
+	}
+}
+
+func TestNewMnemonic(t *testing.T) {
+	for bits, words := range map[int]int{128: 12, 160: 15, 192: 18, 224: 21, 256: 24} {
+		mnemonic, err := NewMnemonic(bits)
+		assert.Nil(t, err)
+		assert.Len(t, strings.Fields(mnemonic), words)
+		assert.Nil(t, ValidateMnemonic(mnemonic))
+	}
+
+	_, err := NewMnemonic(100)
+	assert.NotNil(t, err)
+}
+
+func TestMnemonicRejectsInvalid(t *testing.T) {
+	valid := strings.Repeat("abandon ", 11) + "about"
+
+	// Espaços extras e maiúsculas não importam.
+	assert.Nil(t, ValidateMnemonic("  "+strings.ToUpper(valid)+"\n"))
+
+	assert.ErrorIs(t, ValidateMnemonic(strings.Repeat("abandon ", 12)), ErrMnemonicChecksum)
+	assert.ErrorIs(t, ValidateMnemonic(strings.Repeat("abandon ", 11)+"bitcoins"), ErrInvalidMnemonic)
+	assert.ErrorIs(t, ValidateMnemonic(strings.Repeat("abandon ", 8)+"about"), ErrInvalidMnemonic)
+
+	_, err := MnemonicToSeed(strings.Repeat("abandon ", 12), "")
+	assert.ErrorIs(t, err, ErrMnemonicChecksum)
+
+	// "é" pode ser escrito com um ou dois code points: sem NFKD, as duas formas dariam sementes diferentes.
+	_, err = MnemonicToSeed(valid, "caf\u00e9")
+	assert.ErrorIs(t, err, ErrMnemonicPassphrase)
+	_, err = MnemonicToSeed(valid, "cafe\u0301")
+	assert.ErrorIs(t, err, ErrMnemonicPassphrase)
+}
+
+func TestMnemonicWordlist(t *testing.T) {
+	assert.Len(t, mnemonicWords, 2048)
+	assert.Len(t, mnemonicIndex, 2048)
+	assert.Equal(t, "abandon", mnemonicWords[0])
+	assert.Equal(t, "zoo", mnemonicWords[2047])
+
+	_, err := MnemonicFromEntropy(bytes.Repeat([]byte{1}, 15))
+	assert.NotNil(t, err)
+}
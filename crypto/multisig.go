@@ -0,0 +1,159 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/FelipePn10/fadden/types"
+)
+
+// Contas multisig M-de-N: um conjunto de N chaves públicas e um limite M. Uma autorização da conta
+// precisa de assinaturas válidas de pelo menos M chaves distintas do conjunto.
+//
+// As chaves ficam ordenadas pela serialização (ToSlice), então o mesmo conjunto sempre gera o mesmo
+// endereço, não importa a ordem em que as chaves foram informadas. Cada assinatura indica o índice
+// da chave que a produziu; os índices precisam ser estritamente crescentes, o que impede contar duas
+// vezes o mesmo participante e dá uma única forma para cada conjunto de assinaturas.
+
+// Número máximo de chaves de uma conta multisig.
+const MaxMultisigKeys = 16
+
+const multisigAddressTag = "fadden/multisig/v1"
+
+// Multisig: política de uma conta multisig.
+type Multisig struct {
+	Threshold uint8
+	Keys      []PublicKey
+}
+
+// MultisigSignature: assinatura de um participante, identificado pelo índice da chave em Multisig.Keys.
+type MultisigSignature struct {
+	Index     uint8
+	Signature *Signature
+}
+
+// Cria uma política M-de-N, ordenando as chaves. Chaves repetidas são recusadas.
+func NewMultisig(threshold int, keys []PublicKey) (*Multisig, error) {
+	sorted := append([]PublicKey{}, keys...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].ToSlice(), sorted[j].ToSlice()) < 0
+	})
+
+	if threshold < 1 || threshold > len(keys) {
+		return nil, fmt.Errorf("multisig threshold must be between 1 and %d, got %d", len(keys), threshold)
+	}
+	m := &Multisig{Threshold: uint8(threshold), Keys: sorted}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Verifica se a política está na forma canônica: limite em [1, N], N <= MaxMultisigKeys e
+// chaves estritamente ordenadas (o que também exclui chaves repetidas).
+func (m *Multisig) validate() error {
+	if len(m.Keys) == 0 || len(m.Keys) > MaxMultisigKeys {
+		return fmt.Errorf("multisig must have 1 to %d keys, got %d", MaxMultisigKeys, len(m.Keys))
+	}
+	if m.Threshold < 1 || int(m.Threshold) > len(m.Keys) {
+		return fmt.Errorf("multisig threshold must be between 1 and %d, got %d", len(m.Keys), m.Threshold)
+	}
+
+	var prev []byte
+	for _, k := range m.Keys {
+		if k.IsZero() {
+			return fmt.Errorf("multisig has an empty key")
+		}
+		cur := k.ToSlice()
+		if prev != nil && bytes.Compare(prev, cur) >= 0 {
+			return fmt.Errorf("multisig keys must be sorted and distinct")
+		}
+		prev = cur
+	}
+	return nil
+}
+
+// Endereço da conta: sha256(rótulo || M || chaves), com os mesmos 28 bytes finais de PublicKey.Address.
+// O rótulo impede que o endereço de uma conta multisig coincida com o de uma chave.
+func (m *Multisig) Address() types.Address {
+	buf := &bytes.Buffer{}
+	buf.WriteString(multisigAddressTag)
+	buf.WriteByte(m.Threshold)
+	for _, k := range m.Keys {
+		b := k.ToSlice()
+		binary.Write(buf, binary.BigEndian, uint32(len(b)))
+		buf.Write(b)
+	}
+
+	h := sha256.Sum256(buf.Bytes())
+	return types.AddressFromBytes(h[len(h)-28:])
+}
+
+// Retorna o índice da chave na política, ou -1 se ela não fizer parte.
+func (m *Multisig) IndexOf(key PublicKey) int {
+	for i, k := range m.Keys {
+		if k.Equal(key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Verifica as assinaturas do hash: pelo menos Threshold assinaturas, de chaves distintas, todas válidas.
+// Uma assinatura inválida invalida o conjunto, mesmo que as demais bastem: assim ninguém consegue
+// alterar uma autorização (e o ID da transação) acrescentando assinaturas sem valor.
+// Pelo mesmo motivo, nas assinaturas recuperáveis o ID de recuperação V precisa recuperar a chave do
+// participante: o ECDSA em si ignora V, e outro V mudaria o ID da transação sem invalidar a assinatura.
+func (m *Multisig) VerifyHash(hash types.Hash, sigs []MultisigSignature) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	if len(sigs) < int(m.Threshold) {
+		return fmt.Errorf("multisig needs %d signatures, got %d", m.Threshold, len(sigs))
+	}
+
+	for i, s := range sigs {
+		if int(s.Index) >= len(m.Keys) {
+			return fmt.Errorf("multisig signature index %d out of range", s.Index)
+		}
+		if i > 0 && s.Index <= sigs[i-1].Index {
+			return fmt.Errorf("multisig signature indexes must be strictly increasing")
+		}
+		if s.Signature == nil || !s.Signature.VerifyHash(m.Keys[s.Index], hash) {
+			return fmt.Errorf("invalid multisig signature for key %d", s.Index)
+		}
+		if s.Signature.Recoverable() {
+			if pub, err := RecoverPublicKey(hash, s.Signature); err != nil || !pub.Equal(m.Keys[s.Index]) {
+				return fmt.Errorf("multisig signature for key %d has the wrong recovery id", s.Index)
+			}
+		}
+	}
+	return nil
+}
+
+// Assina o hash como participante da política e insere a assinatura no conjunto, na posição do índice
+// da chave. Uma assinatura anterior da mesma chave é substituída.
+func (m *Multisig) SignHash(key PrivateKey, hash types.Hash, sigs []MultisigSignature) ([]MultisigSignature, error) {
+	index := m.IndexOf(key.PublicKey())
+	if index < 0 {
+		return nil, fmt.Errorf("key %s is not part of the multisig", key.PublicKey().Address())
+	}
+
+	sig, err := key.SignHash(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]MultisigSignature, 0, len(sigs)+1)
+	for _, s := range sigs {
+		if int(s.Index) != index {
+			out = append(out, s)
+		}
+	}
+	out = append(out, MultisigSignature{Index: uint8(index), Signature: sig})
+	sort.Slice(out, func(i, j int) bool { return out[i].Index < out[j].Index })
+	return out, nil
+}
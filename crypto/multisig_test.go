@@ -0,0 +1,105 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func multisigKeys(t *testing.T, n int) ([]PrivateKey, []PublicKey) {
+	privs := make([]PrivateKey, n)
+	pubs := make([]PublicKey, n)
+	for i := range privs {
+		privs[i] = GeneratePrivateKey()
+		pubs[i] = privs[i].PublicKey()
+	}
+	return privs, pubs
+}
+
+func TestMultisigAddress(t *testing.T) {
+	_, pubs := multisigKeys(t, 3)
+
+	a, err := NewMultisig(2, pubs)
+	assert.Nil(t, err)
+	b, err := NewMultisig(2, []PublicKey{pubs[2], pubs[0], pubs[1]})
+	assert.Nil(t, err)
+	assert.Equal(t, a.Address(), b.Address())
+
+	// O limite e o conjunto de chaves fazem parte do endereço.
+	c, err := NewMultisig(3, pubs)
+	assert.Nil(t, err)
+	assert.NotEqual(t, a.Address(), c.Address())
+	d, err := NewMultisig(1, pubs[:1])
+	assert.Nil(t, err)
+	assert.NotEqual(t, pubs[0].Address(), d.Address())
+}
+
+func TestNewMultisigRejectsInvalid(t *testing.T) {
+	_, pubs := multisigKeys(t, MaxMultisigKeys+1)
+
+	_, err := NewMultisig(0, pubs[:2])
+	assert.NotNil(t, err)
+	_, err = NewMultisig(3, pubs[:2])
+	assert.NotNil(t, err)
+	_, err = NewMultisig(1, []PublicKey{pubs[0], pubs[0]})
+	assert.NotNil(t, err)
+	_, err = NewMultisig(1, pubs)
+	assert.NotNil(t, err)
+	_, err = NewMultisig(1, nil)
+	assert.NotNil(t, err)
+}
+
+func TestMultisigVerifyHash(t *testing.T) {
+	privs, pubs := multisigKeys(t, 3)
+	m, err := NewMultisig(2, pubs)
+	assert.Nil(t, err)
+	hash := Digest([]byte("foo"))
+
+	sigs, err := m.SignHash(privs[2], hash, nil)
+	assert.Nil(t, err)
+	assert.NotNil(t, m.VerifyHash(hash, sigs))
+
+	// A mesma chave assinando de novo não conta duas vezes.
+	again, err := m.SignHash(privs[2], hash, sigs)
+	assert.Nil(t, err)
+	assert.Len(t, again, 1)
+	assert.NotNil(t, m.VerifyHash(hash, append(sigs, sigs[0])))
+
+	sigs, err = m.SignHash(privs[0], hash, sigs)
+	assert.Nil(t, err)
+	assert.Nil(t, m.VerifyHash(hash, sigs))
+	assert.NotNil(t, m.VerifyHash(Digest([]byte("bar")), sigs))
+
+	// Fora de ordem, com índice inválido ou com uma assinatura inválida a mais, o conjunto é recusado.
+	assert.NotNil(t, m.VerifyHash(hash, []MultisigSignature{sigs[1], sigs[0]}))
+	assert.NotNil(t, m.VerifyHash(hash, append(sigs[:1:1], MultisigSignature{Index: 3, Signature: sigs[1].Signature})))
+	assert.NotNil(t, m.VerifyHash(hash, []MultisigSignature{sigs[0], {Index: 1, Signature: sigs[1].Signature}, sigs[1]}))
+
+	_, err = m.SignHash(GeneratePrivateKey(), hash, nil)
+	assert.NotNil(t, err)
+
+	// O ECDSA aceita qualquer V, mas o conjunto só aceita o V que recupera a chave do participante.
+	flipped := *sigs[0].Signature
+	flipped.V ^= 1
+	assert.True(t, flipped.VerifyHash(m.Keys[sigs[0].Index], hash))
+	assert.NotNil(t, m.VerifyHash(hash, []MultisigSignature{{Index: sigs[0].Index, Signature: &flipped}, sigs[1]}))
+}
+
+func TestMultisigMixedSchemes(t *testing.T) {
+	ed, err := GenerateSchemeKey(SchemeEd25519)
+	assert.Nil(t, err)
+	k1, err := GenerateSchemeKey(SchemeSecp256k1)
+	assert.Nil(t, err)
+	p256 := GeneratePrivateKey()
+
+	m, err := NewMultisig(3, []PublicKey{ed.PublicKey(), k1.PublicKey(), p256.PublicKey()})
+	assert.Nil(t, err)
+
+	hash := Digest([]byte("foo"))
+	var sigs []MultisigSignature
+	for _, key := range []PrivateKey{ed, k1, p256} {
+		sigs, err = m.SignHash(key, hash, sigs)
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, m.VerifyHash(hash, sigs))
+}
@@ -0,0 +1,282 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/FelipePn10/fadden/types"
+)
+
+// Agregação de assinaturas Schnorr no estilo MuSig2. N participantes produzem uma única assinatura
+// Schnorr comum (64 bytes) para uma chave agregada, que é uma chave pública Schnorr como qualquer
+// outra: quem verifica não sabe quantos nem quais participantes assinaram.
+//
+// Agregação das chaves: X = soma(a_i * X_i), com a_i = H(L || X_i) e L = H(X_1 || ... || X_n).
+// Os coeficientes a_i impedem que um participante escolha a sua chave em função das outras para
+// controlar sozinho a chave agregada ("rogue key attack").
+//
+// Protocolo de assinatura, em duas rodadas:
+//  1. Cada participante gera um par de nonces secretos (k1, k2) e divulga os pontos R1 = k1*G e
+//     R2 = k2*G (ver NewMuSigNonce). Essa rodada pode acontecer antes de a mensagem ser conhecida.
+//  2. Com todos os nonces públicos, cada um calcula b = H(X || R1 || R2 || m), com R1 e R2 somados,
+//     o nonce agregado R = R1 + b*R2 e o desafio e = H(R || X || m), e divulga a assinatura parcial
+//     s_i = k1 + b*k2 + e*a_i*x_i. A soma das parciais é o s da assinatura (e, s).
+//
+// Um par de nonces nunca pode ser usado em duas sessões: isso revela a chave privada. Por isso os
+// nonces são aleatórios (e não determinísticos) e MuSigSession.Sign apaga o par depois de usá-lo.
+
+const (
+	musigKeyListTag   = "fadden/musig/keyagg-list"
+	musigKeyCoefTag   = "fadden/musig/keyagg-coef"
+	musigNonceTag     = "fadden/musig/nonce"
+	musigNonceCoefTag = "fadden/musig/noncecoef"
+
+	// Tamanho de um nonce público: dois pontos SEC1 comprimidos.
+	MuSigPublicNonceSize = 66
+)
+
+// AggregateKey: chave agregada de um conjunto de chaves Schnorr.
+type AggregateKey struct {
+	Key   PublicKey   // Chave agregada, usada para verificar a assinatura final
+	Keys  []PublicKey // Chaves dos participantes, ordenadas
+	coefs []*big.Int
+}
+
+// Agrega chaves públicas Schnorr. As chaves são ordenadas, então a ordem informada não importa.
+func AggregatePublicKeys(keys []PublicKey) (*AggregateKey, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys to aggregate")
+	}
+	sorted := append([]PublicKey{}, keys...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].ToSlice(), sorted[j].ToSlice()) < 0
+	})
+
+	encoded := make([][]byte, len(sorted))
+	for i, k := range sorted {
+		if k.Scheme != SchemeSchnorrP256 || k.Key == nil {
+			return nil, fmt.Errorf("key %d is not a %s key", i, SchemeSchnorrP256)
+		}
+		encoded[i] = k.ToSlice()
+		if i > 0 && bytes.Equal(encoded[i], encoded[i-1]) {
+			return nil, fmt.Errorf("duplicate key in aggregation")
+		}
+	}
+
+	curve := elliptic.P256()
+	list := taggedHash(musigKeyListTag, encoded...)
+	agg := &AggregateKey{Keys: sorted, coefs: make([]*big.Int, len(sorted))}
+
+	x, y := new(big.Int), new(big.Int)
+	for i, k := range sorted {
+		agg.coefs[i] = taggedHashInt(musigKeyCoefTag, list[:], encoded[i])
+		px, py := curve.ScalarMult(k.Key.X, k.Key.Y, agg.coefs[i].FillBytes(make([]byte, 32)))
+		x, y = curve.Add(x, y, px, py)
+	}
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, fmt.Errorf("aggregate key is the point at infinity")
+	}
+
+	pub, err := schnorrScheme{}.PublicKeyFromBytes(elliptic.MarshalCompressed(curve, x, y))
+	if err != nil {
+		return nil, err
+	}
+	agg.Key = pub
+	return agg, nil
+}
+
+// Retorna o índice da chave entre os participantes, ou -1 se ela não fizer parte.
+func (a *AggregateKey) IndexOf(key PublicKey) int {
+	for i, k := range a.Keys {
+		if k.Equal(key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// MuSigPublicNonce: os pontos R1 e R2 de um participante, comprimidos.
+type MuSigPublicNonce [MuSigPublicNonceSize]byte
+
+// MuSigNonce: par de nonces secretos de um participante. Deve ser usado em uma única sessão.
+type MuSigNonce struct {
+	Public MuSigPublicNonce
+	k1, k2 *big.Int
+}
+
+// Gera um par de nonces para assinar com a chave. Os nonces vêm de 32 bytes de rand.Reader, misturados
+// à chave e ao hash. A segurança depende inteiramente dessa aleatoriedade: a mistura não protege contra
+// uma fonte fraca, pois em uma nova sessão para o mesmo hash a chave e o hash se repetem. Se rand.Reader
+// falhar, o erro é retornado e a sessão não deve continuar. Usar o mesmo MuSigNonce em duas sessões
+// revela a chave privada.
+func NewMuSigNonce(key PrivateKey, hash types.Hash) (*MuSigNonce, error) {
+	if key.Scheme != SchemeSchnorrP256 {
+		return nil, fmt.Errorf("MuSig requires %s keys", SchemeSchnorrP256)
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+
+	curve := elliptic.P256()
+	n := &MuSigNonce{}
+	secret := key.Bytes()
+	for i, k := range []**big.Int{&n.k1, &n.k2} {
+		*k = taggedHashInt(musigNonceTag, random, secret, key.PublicKey().ToSlice(), hash[:], []byte{byte(i)})
+		if (*k).Sign() == 0 {
+			return nil, fmt.Errorf("invalid MuSig nonce")
+		}
+		x, y := curve.ScalarBaseMult((*k).FillBytes(make([]byte, 32)))
+		copy(n.Public[i*33:], elliptic.MarshalCompressed(curve, x, y))
+	}
+	return n, nil
+}
+
+// MuSigSession: sessão de assinatura de um hash por todos os participantes de uma chave agregada.
+type MuSigSession struct {
+	agg  *AggregateKey
+	hash types.Hash
+	b, e *big.Int
+
+	// Pontos R1 + b*R2 de cada participante, para verificar as assinaturas parciais.
+	nonces []point
+}
+
+type point struct {
+	x, y *big.Int
+}
+
+// Inicia a sessão com os nonces públicos de todos os participantes, na ordem de AggregateKey.Keys.
+func NewMuSigSession(agg *AggregateKey, hash types.Hash, nonces []MuSigPublicNonce) (*MuSigSession, error) {
+	if len(nonces) != len(agg.Keys) {
+		return nil, fmt.Errorf("expected %d MuSig nonces, got %d", len(agg.Keys), len(nonces))
+	}
+
+	curve := elliptic.P256()
+	r1, r2 := make([]point, len(nonces)), make([]point, len(nonces))
+	sum1, sum2 := point{new(big.Int), new(big.Int)}, point{new(big.Int), new(big.Int)}
+	for i, nonce := range nonces {
+		for j, r := range []*point{&r1[i], &r2[i]} {
+			x, y := elliptic.UnmarshalCompressed(curve, nonce[j*33:(j+1)*33])
+			if x == nil {
+				return nil, fmt.Errorf("invalid MuSig nonce from participant %d", i)
+			}
+			*r = point{x, y}
+		}
+		sum1.x, sum1.y = curve.Add(sum1.x, sum1.y, r1[i].x, r1[i].y)
+		sum2.x, sum2.y = curve.Add(sum2.x, sum2.y, r2[i].x, r2[i].y)
+	}
+
+	// b = H(X || R1 || R2 || m). Somas no infinito (só com nonces maliciosos) tornam a sessão inválida.
+	if isInfinity(sum1) || isInfinity(sum2) {
+		return nil, fmt.Errorf("aggregate MuSig nonce is the point at infinity")
+	}
+	b := taggedHashInt(musigNonceCoefTag,
+		agg.Key.ToSlice(),
+		elliptic.MarshalCompressed(curve, sum1.x, sum1.y),
+		elliptic.MarshalCompressed(curve, sum2.x, sum2.y),
+		hash[:],
+	)
+	bBytes := b.FillBytes(make([]byte, 32))
+
+	// R = R1 + b*R2
+	bx, by := curve.ScalarMult(sum2.x, sum2.y, bBytes)
+	rx, ry := curve.Add(sum1.x, sum1.y, bx, by)
+	if rx.Sign() == 0 && ry.Sign() == 0 {
+		return nil, fmt.Errorf("aggregate MuSig nonce is the point at infinity")
+	}
+
+	e := schnorrChallenge(rx, ry, agg.Key.Key.X, agg.Key.Key.Y, hash)
+	if e.Sign() == 0 {
+		return nil, fmt.Errorf("invalid MuSig challenge")
+	}
+
+	s := &MuSigSession{agg: agg, hash: hash, b: b, e: e, nonces: make([]point, len(nonces))}
+	for i := range nonces {
+		x, y := curve.ScalarMult(r2[i].x, r2[i].y, bBytes)
+		x, y = curve.Add(r1[i].x, r1[i].y, x, y)
+		s.nonces[i] = point{x, y}
+	}
+	return s, nil
+}
+
+// Produz a assinatura parcial do participante. O par de nonces é apagado: usá-lo de novo é um erro.
+func (s *MuSigSession) Sign(key PrivateKey, nonce *MuSigNonce) (*big.Int, error) {
+	i := s.agg.IndexOf(key.PublicKey())
+	if i < 0 {
+		return nil, fmt.Errorf("key %s is not part of the aggregate key", key.PublicKey().Address())
+	}
+	if nonce.k1 == nil || nonce.k2 == nil {
+		return nil, fmt.Errorf("MuSig nonce was already used")
+	}
+
+	// s_i = k1 + b*k2 + e*a_i*x_i
+	n := elliptic.P256().Params().N
+	partial := new(big.Int).Mul(s.e, s.agg.coefs[i])
+	partial.Mul(partial, key.Key.D)
+	partial.Add(partial, nonce.k1)
+	partial.Add(partial, new(big.Int).Mul(s.b, nonce.k2))
+	partial.Mod(partial, n)
+
+	nonce.k1, nonce.k2 = nil, nil
+
+	if err := s.VerifyPartial(i, partial); err != nil {
+		return nil, fmt.Errorf("MuSig nonce does not match the session: %w", err)
+	}
+	return partial, nil
+}
+
+// Verifica a assinatura parcial do participante i: s_i*G = R1_i + b*R2_i + e*a_i*X_i.
+// Permite identificar quem enviou uma parcial inválida antes de agregar.
+func (s *MuSigSession) VerifyPartial(i int, partial *big.Int) error {
+	curve := elliptic.P256()
+	n := curve.Params().N
+	if i < 0 || i >= len(s.agg.Keys) {
+		return fmt.Errorf("participant %d out of range", i)
+	}
+	if partial == nil || partial.Sign() < 0 || partial.Cmp(n) >= 0 {
+		return fmt.Errorf("partial signature of participant %d out of range", i)
+	}
+
+	lx, ly := curve.ScalarBaseMult(partial.FillBytes(make([]byte, 32)))
+
+	ea := new(big.Int).Mul(s.e, s.agg.coefs[i])
+	ea.Mod(ea, n)
+	key := s.agg.Keys[i].Key
+	x, y := curve.ScalarMult(key.X, key.Y, ea.FillBytes(make([]byte, 32)))
+	x, y = curve.Add(s.nonces[i].x, s.nonces[i].y, x, y)
+
+	if lx.Cmp(x) != 0 || ly.Cmp(y) != 0 {
+		return fmt.Errorf("invalid partial signature from participant %d", i)
+	}
+	return nil
+}
+
+// Soma as assinaturas parciais de todos os participantes, na ordem de AggregateKey.Keys, na assinatura
+// final, válida para a chave agregada.
+func (s *MuSigSession) Aggregate(partials []*big.Int) (*Signature, error) {
+	if len(partials) != len(s.agg.Keys) {
+		return nil, fmt.Errorf("expected %d partial signatures, got %d", len(s.agg.Keys), len(partials))
+	}
+
+	n := elliptic.P256().Params().N
+	sum := new(big.Int)
+	for i, partial := range partials {
+		if err := s.VerifyPartial(i, partial); err != nil {
+			return nil, err
+		}
+		sum.Add(sum, partial)
+	}
+	sum.Mod(sum, n)
+
+	return &Signature{R: new(big.Int).Set(s.e), S: sum, Scheme: SchemeSchnorrP256}, nil
+}
+
+func isInfinity(p point) bool {
+	return p.x.Sign() == 0 && p.y.Sign() == 0
+}
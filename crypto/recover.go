@@ -0,0 +1,96 @@
+package crypto
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/FelipePn10/fadden/types"
+)
+
+// Recuperação da chave pública a partir de uma assinatura ECDSA (SEC 1, seção 4.1.6).
+// A assinatura (r, s) guarda apenas a coordenada x do ponto aleatório R = k*G, reduzida módulo n.
+// O ID de recuperação V completa a informação: o bit 0 é a paridade de R.y e o bit 1 indica
+// que R.x = r + n (raro na P-256 e na secp256k1, pois n é só um pouco menor que p). Com R, a chave pública é
+//
+//	Q = r⁻¹ * (s*R - e*G)
+//
+// onde e é o hash assinado. Isso permite omitir a chave pública das transações.
+
+// Recupera a chave pública que assinou o hash, se o esquema da assinatura permitir (ver RecoverableScheme).
+func RecoverPublicKey(hash types.Hash, sig *Signature) (PublicKey, error) {
+	s, err := SchemeByID(sig.Scheme)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	rs, ok := s.(RecoverableScheme)
+	if !ok {
+		return PublicKey{}, fmt.Errorf("%s signatures do not support public key recovery", s.Name())
+	}
+	return rs.RecoverPublicKey(hash, sig)
+}
+
+// Indica se a chave pública pode ser recuperada da assinatura (ver RecoverPublicKey).
+func (sig Signature) Recoverable() bool {
+	s, err := SchemeByID(sig.Scheme)
+	if err != nil {
+		return false
+	}
+	_, ok := s.(RecoverableScheme)
+	return ok
+}
+
+// Recupera a chave pública ECDSA. Falha se V for inválido ou se R não for um ponto da curva.
+func (s *ecdsaScheme) RecoverPublicKey(hash types.Hash, sig *Signature) (PublicKey, error) {
+	if err := sig.validate(); err != nil {
+		return PublicKey{}, err
+	}
+	if sig.V > 3 {
+		return PublicKey{}, fmt.Errorf("%w: recovery id %d out of range", ErrInvalidSignature, sig.V)
+	}
+
+	curve := s.curve
+	params := curve.Params()
+
+	x := new(big.Int).Set(sig.R)
+	if sig.V&2 != 0 {
+		x.Add(x, params.N)
+	}
+	if x.Cmp(params.P) >= 0 {
+		return PublicKey{}, fmt.Errorf("%w: recovery id %d gives x out of range", ErrInvalidSignature, sig.V)
+	}
+
+	// Reconstrói R a partir de x e da paridade de y, no formato SEC1 comprimido.
+	compressed := make([]byte, 33)
+	compressed[0] = 0x02 | sig.V&1
+	x.FillBytes(compressed[1:])
+	rx, ry := s.unmarshalCompressed(curve, compressed)
+	if rx == nil {
+		return PublicKey{}, fmt.Errorf("%w: r is not the x coordinate of a curve point", ErrInvalidSignature)
+	}
+
+	// Q = u1*G + u2*R, com u1 = -e/r e u2 = s/r (mod n).
+	rInv := new(big.Int).ModInverse(sig.R, params.N)
+	e := new(big.Int).SetBytes(hash[:])
+	u1 := new(big.Int).Mul(e, rInv)
+	u1.Neg(u1).Mod(u1, params.N)
+	u2 := new(big.Int).Mul(sig.S, rInv)
+	u2.Mod(u2, params.N)
+
+	x1, y1 := curve.ScalarBaseMult(u1.FillBytes(make([]byte, 32)))
+	x2, y2 := curve.ScalarMult(rx, ry, u2.FillBytes(make([]byte, 32)))
+	qx, qy := curve.Add(x1, y1, x2, y2)
+	if qx.Sign() == 0 && qy.Sign() == 0 {
+		return PublicKey{}, fmt.Errorf("%w: recovered point at infinity", ErrInvalidSignature)
+	}
+
+	uncompressed := make([]byte, 65)
+	uncompressed[0] = 0x04
+	qx.FillBytes(uncompressed[1:33])
+	qy.FillBytes(uncompressed[33:])
+	return s.PublicKeyFromBytes(uncompressed)
+}
+
+// Recupera a chave pública de uma assinatura feita com Sign.
+func (sig Signature) RecoverPublicKey(data []byte) (PublicKey, error) {
+	return RecoverPublicKey(Digest(data), &sig)
+}
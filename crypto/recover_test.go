@@ -0,0 +1,66 @@
+package crypto
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverPublicKey(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		privKey := GeneratePrivateKey()
+		sig, err := privKey.Sign([]byte("foo"))
+		assert.Nil(t, err)
+
+		pub, err := sig.RecoverPublicKey([]byte("foo"))
+		assert.Nil(t, err)
+		assert.Equal(t, privKey.PublicKey().ToSlice(), pub.ToSlice())
+
+		// Com outra mensagem, a chave recuperada é outra.
+		other, err := sig.RecoverPublicKey([]byte("bar"))
+		assert.Nil(t, err)
+		assert.NotEqual(t, privKey.PublicKey().ToSlice(), other.ToSlice())
+	}
+}
+
+func TestRecoverPublicKeyFromRecoverableBytes(t *testing.T) {
+	privKey := GeneratePrivateKey()
+	hash := Digest([]byte("foo"))
+	sig, err := privKey.SignHash(hash)
+	assert.Nil(t, err)
+
+	parsed, err := SignatureFromRecoverableBytes(recoverableBytes(t, sig))
+	assert.Nil(t, err)
+	pub, err := RecoverPublicKey(hash, parsed)
+	assert.Nil(t, err)
+	assert.Equal(t, privKey.PublicKey().Address(), pub.Address())
+
+	// Trocar a paridade leva a outra chave, para a qual a assinatura também é válida.
+	flipped := &Signature{R: sig.R, S: sig.S, V: sig.V ^ 1}
+	pub, err = RecoverPublicKey(hash, flipped)
+	assert.Nil(t, err)
+	assert.NotEqual(t, privKey.PublicKey().Address(), pub.Address())
+}
+
+func TestNormalizeKeepsRecoveredKey(t *testing.T) {
+	privKey := GeneratePrivateKey()
+	hash := Digest([]byte("foo"))
+	sig, err := privKey.SignHash(hash)
+	assert.Nil(t, err)
+
+	high := Signature{R: sig.R, S: new(big.Int).Sub(curveOrder, sig.S), V: sig.V ^ 1}
+	pub, err := RecoverPublicKey(hash, high.Normalize())
+	assert.Nil(t, err)
+	assert.Equal(t, privKey.PublicKey().Address(), pub.Address())
+}
+
+func TestRecoverPublicKeyRejectsInvalid(t *testing.T) {
+	sig, err := GeneratePrivateKey().Sign([]byte("foo"))
+	assert.Nil(t, err)
+
+	_, err = RecoverPublicKey(Digest([]byte("foo")), &Signature{R: sig.R, S: sig.S, V: 4})
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = RecoverPublicKey(Digest([]byte("foo")), &Signature{R: big.NewInt(0), S: sig.S})
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
@@ -0,0 +1,142 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/FelipePn10/fadden/types"
+)
+
+// Nonces determinísticos (RFC 6979). O nonce k de uma assinatura ECDSA precisa ser secreto e único:
+// repetir k em duas mensagens, ou deixar parte dele previsível, revela a chave privada. Em vez de
+// depender do rand.Reader, o k é gerado por um HMAC-DRBG (HMAC-SHA256) alimentado pela chave privada
+// e pelo hash assinado. A mesma chave e a mesma mensagem sempre produzem a mesma assinatura.
+//
+// No modo "hedged" (RFC 6979, seção 3.6), bytes aleatórios entram no DRBG como dado adicional:
+// com uma boa fonte de entropia as assinaturas variam, e com uma fonte ruim o k continua tão
+// seguro quanto no modo determinístico.
+
+// Tamanho dos bytes aleatórios misturados ao nonce no modo hedged.
+const hedgeSize = 32
+
+// Assina dados com um nonce determinístico (ver SignHashDeterministic).
+func (k PrivateKey) SignDeterministic(data []byte) (*Signature, error) {
+	return k.SignHashDeterministic(Digest(data))
+}
+
+// Assina um hash com o nonce do RFC 6979: a assinatura é reproduzível e não usa o rand.Reader.
+// Esquemas que não são ECDSA usam a própria assinatura (a Ed25519 já é determinística).
+func (k PrivateKey) SignHashDeterministic(hash types.Hash) (*Signature, error) {
+	return k.signHash(hash, nil)
+}
+
+// Assina um hash com o nonce do RFC 6979 misturado a bytes lidos de rand (modo hedged).
+func (k PrivateKey) SignHashHedged(hash types.Hash, rand io.Reader) (*Signature, error) {
+	extra := make([]byte, hedgeSize)
+	if _, err := io.ReadFull(rand, extra); err != nil {
+		return nil, err
+	}
+	return k.signHash(hash, extra)
+}
+
+func (k PrivateKey) signHash(hash types.Hash, extra []byte) (*Signature, error) {
+	s := ecdsaSchemeByID(k.Scheme)
+	if s == nil {
+		scheme, err := SchemeByID(k.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		return scheme.SignHash(k, hash)
+	}
+
+	nonces := newRFC6979(s.curve.Params().N, k.Key.D, hash, extra)
+	for i := 0; i < 16; i++ {
+		if sig := s.signWithNonce(k.Key.D, hash, nonces.next()); sig != nil {
+			return sig.Normalize(), nil // Forma canônica (ver IsLowS).
+		}
+	}
+	return nil, fmt.Errorf("could not find a valid signature nonce")
+}
+
+// Calcula a assinatura ECDSA com o nonce k, antes da normalização. Também calcula o ID de recuperação
+// a partir do ponto R = k*G. Retorna nil se r ou s forem zero (o próximo nonce deve ser usado).
+func (s *ecdsaScheme) signWithNonce(d *big.Int, hash types.Hash, k *big.Int) *Signature {
+	n := s.curve.Params().N
+
+	rx, ry := s.curve.ScalarBaseMult(k.FillBytes(make([]byte, 32)))
+	r := new(big.Int).Mod(rx, n)
+	if r.Sign() == 0 {
+		return nil
+	}
+
+	// s = k⁻¹ * (e + r*d) mod n
+	e := new(big.Int).SetBytes(hash[:])
+	sv := new(big.Int).Mul(r, d)
+	sv.Add(sv, e)
+	sv.Mul(sv, new(big.Int).ModInverse(k, n))
+	sv.Mod(sv, n)
+	if sv.Sign() == 0 {
+		return nil
+	}
+
+	v := byte(ry.Bit(0))
+	if rx.Cmp(n) >= 0 {
+		v |= 2
+	}
+	return &Signature{R: r, S: sv, V: v, Scheme: s.id}
+}
+
+// Gerador de nonces do RFC 6979 (seção 3.2) com HMAC-SHA256, para curvas de ordem n com 256 bits (qlen = hlen).
+type rfc6979 struct {
+	k, v []byte
+	n    *big.Int
+}
+
+func newRFC6979(n, d *big.Int, hash types.Hash, extra []byte) *rfc6979 {
+	// bits2octets(h1): o hash reduzido módulo n.
+	h := new(big.Int).SetBytes(hash[:])
+	h.Mod(h, n)
+
+	seed := make([]byte, 0, 64+len(extra))
+	seed = append(seed, d.FillBytes(make([]byte, 32))...)
+	seed = append(seed, h.FillBytes(make([]byte, 32))...)
+	seed = append(seed, extra...)
+
+	g := &rfc6979{k: make([]byte, sha256.Size), v: make([]byte, sha256.Size), n: n}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+
+	g.k = g.mac(g.v, []byte{0x00}, seed)
+	g.v = g.mac(g.v)
+	g.k = g.mac(g.v, []byte{0x01}, seed)
+	g.v = g.mac(g.v)
+	return g
+}
+
+// Retorna o próximo candidato a nonce em [1, n-1]. Chamadas seguintes (quando r ou s dão zero)
+// continuam a sequência, como manda o RFC.
+func (g *rfc6979) next() *big.Int {
+	for {
+		g.v = g.mac(g.v)
+		k := new(big.Int).SetBytes(g.v)
+		if k.Sign() > 0 && k.Cmp(g.n) < 0 {
+			g.k = g.mac(g.v, []byte{0x00})
+			g.v = g.mac(g.v)
+			return k
+		}
+		g.k = g.mac(g.v, []byte{0x00})
+		g.v = g.mac(g.v)
+	}
+}
+
+func (g *rfc6979) mac(parts ...[]byte) []byte {
+	m := hmac.New(sha256.New, g.k)
+	for _, p := range parts {
+		m.Write(p)
+	}
+	return m.Sum(nil)
+}
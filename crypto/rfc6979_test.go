@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/FelipePn10/fadden/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func hexInt(t *testing.T, s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	assert.True(t, ok)
+	return n
+}
+
+// Vetores do RFC 6979, apêndice A.2.5 (P-256 com SHA-256).
+func TestRFC6979Vectors(t *testing.T) {
+	key, err := PrivateKeyFromHex("C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721")
+	assert.Nil(t, err)
+	assert.Equal(t, hexInt(t, "60FED4BA255A9D31C961EB74C6356D68C049B8923B61FA6CE669622E60F29FB6"), key.Key.X)
+	assert.Equal(t, hexInt(t, "7903FE1008B8BC99A41AE9E95628BC64F2F1B20C2D7E9F5177A3C294D4462299"), key.Key.Y)
+
+	for _, v := range []struct {
+		msg     string
+		k, r, s string
+	}{
+		{
+			msg: "sample",
+			k:   "A6E3C57DD01ABE90086538398355DD4C3B17AA873382B0F24D6129493D8AAD60",
+			r:   "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+			s:   "F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8",
+		},
+		{
+			msg: "test",
+			k:   "D16B6AE827F17175E040871A1C7EC3500192C4C92677336EC2537ACAEE0008E0",
+			r:   "F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+			s:   "019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083",
+		},
+	} {
+		hash := types.Hash(sha256.Sum256([]byte(v.msg)))
+
+		k := newRFC6979(curveOrder, key.Key.D, hash, nil).next()
+		assert.Equal(t, hexInt(t, v.k), k, v.msg)
+
+		raw := p256Scheme.signWithNonce(key.Key.D, hash, k)
+		assert.Equal(t, hexInt(t, v.r), raw.R, v.msg)
+		assert.Equal(t, hexInt(t, v.s), raw.S, v.msg)
+
+		// A assinatura publicada é a forma bruta; SignHashDeterministic retorna a forma canônica.
+		sig, err := key.SignHashDeterministic(hash)
+		assert.Nil(t, err)
+		assert.Equal(t, raw.Normalize(), sig)
+		assert.True(t, sig.VerifyHash(key.PublicKey(), hash))
+
+		pub, err := RecoverPublicKey(hash, sig)
+		assert.Nil(t, err)
+		assert.Equal(t, key.PublicKey().Address(), pub.Address())
+	}
+}
+
+func TestSignDeterministic(t *testing.T) {
+	key := GeneratePrivateKey()
+	a, err := key.SignDeterministic([]byte("foo"))
+	assert.Nil(t, err)
+	b, err := key.SignDeterministic([]byte("foo"))
+	assert.Nil(t, err)
+	assert.Equal(t, recoverableBytes(t, a), recoverableBytes(t, b))
+
+	c, err := key.SignDeterministic([]byte("bar"))
+	assert.Nil(t, err)
+	assert.NotEqual(t, a.R, c.R)
+}
+
+func TestSignHashHedged(t *testing.T) {
+	key := GeneratePrivateKey()
+	hash := Digest([]byte("foo"))
+
+	// Com os mesmos bytes extras, o resultado é reproduzível; com outros, o nonce muda.
+	extra := bytes.Repeat([]byte{0xaa}, hedgeSize)
+	a, err := key.SignHashHedged(hash, bytes.NewReader(extra))
+	assert.Nil(t, err)
+	b, err := key.SignHashHedged(hash, bytes.NewReader(extra))
+	assert.Nil(t, err)
+	assert.Equal(t, a, b)
+
+	deterministic, err := key.SignHashDeterministic(hash)
+	assert.Nil(t, err)
+	assert.NotEqual(t, deterministic.R, a.R)
+	assert.True(t, a.VerifyHash(key.PublicKey(), hash))
+
+	// Uma fonte sem bytes suficientes é um erro, não uma assinatura com nonce fraco.
+	_, err = key.SignHashHedged(hash, bytes.NewReader(extra[:4]))
+	assert.NotNil(t, err)
+}
+
+func TestSignHashRandomized(t *testing.T) {
+	key := GeneratePrivateKey()
+	a, err := key.Sign([]byte("foo"))
+	assert.Nil(t, err)
+	b, err := key.Sign([]byte("foo"))
+	assert.Nil(t, err)
+	assert.NotEqual(t, compactBytes(t, a), compactBytes(t, b))
+}
@@ -0,0 +1,269 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/FelipePn10/fadden/types"
+)
+
+// Esquemas de assinatura. Cada chave e cada assinatura carrega o ID do esquema que a gerou, e as
+// operações (assinar, verificar, derivar a chave pública, serializar) são delegadas ao esquema
+// registrado com esse ID. P-256 é o esquema padrão (ID zero), então as chaves e assinaturas já
+// existentes continuam válidas e com o mesmo endereço.
+//
+// Esquemas embutidos:
+//   - SchemeP256: ECDSA sobre a NIST P-256.
+//   - SchemeEd25519: Ed25519 (RFC 8032), da biblioteca padrão.
+//   - SchemeSecp256k1: ECDSA sobre a secp256k1 (ver Secp256k1).
+//   - SchemeSchnorrP256: Schnorr sobre a P-256, com agregação de chaves e assinaturas (ver schnorr.go).
+//
+// As chaves ECDSA e Schnorr usam o campo Key (com a curva do esquema); os demais esquemas usam Raw.
+
+type SchemeID byte
+
+const (
+	SchemeP256 SchemeID = iota
+	SchemeEd25519
+	SchemeSecp256k1
+	SchemeSchnorrP256
+)
+
+// Scheme: um esquema de assinatura registrado com RegisterScheme.
+type Scheme interface {
+	ID() SchemeID
+	Name() string
+
+	GenerateKey(rand io.Reader) (PrivateKey, error)
+	PublicKey(k PrivateKey) PublicKey
+
+	// Serialização das chaves no formato do esquema, sem o ID.
+	PrivateKeyBytes(k PrivateKey) []byte
+	PrivateKeyFromBytes(b []byte) (PrivateKey, error)
+	PublicKeyBytes(k PublicKey) []byte
+	PublicKeyFromBytes(b []byte) (PublicKey, error)
+
+	SignHash(k PrivateKey, hash types.Hash) (*Signature, error)
+	VerifyHash(k PublicKey, hash types.Hash, sig *Signature) bool
+}
+
+// RecoverableScheme: esquema que permite recuperar a chave pública a partir da assinatura (ver RecoverPublicKey).
+type RecoverableScheme interface {
+	Scheme
+	RecoverPublicKey(hash types.Hash, sig *Signature) (PublicKey, error)
+}
+
+var (
+	schemesLock sync.RWMutex
+	schemes     = make(map[SchemeID]Scheme)
+)
+
+func init() {
+	RegisterScheme(p256Scheme)
+	RegisterScheme(ed25519Scheme{})
+	RegisterScheme(secp256k1Scheme)
+	RegisterScheme(schnorrScheme{})
+}
+
+// Registra um esquema de assinatura. Registrar dois esquemas com o mesmo ID é um erro de programação.
+func RegisterScheme(s Scheme) {
+	schemesLock.Lock()
+	defer schemesLock.Unlock()
+
+	if _, ok := schemes[s.ID()]; ok {
+		panic(fmt.Sprintf("signature scheme %d (%s) already registered", s.ID(), s.Name()))
+	}
+	schemes[s.ID()] = s
+}
+
+// Retorna o esquema registrado com o ID.
+func SchemeByID(id SchemeID) (Scheme, error) {
+	schemesLock.RLock()
+	defer schemesLock.RUnlock()
+
+	s, ok := schemes[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown signature scheme %d", id)
+	}
+	return s, nil
+}
+
+// Retorna o esquema de uma chave já construída. As chaves só são criadas por esquemas registrados,
+// então um ID desconhecido aqui é um erro de programação.
+func mustScheme(id SchemeID) Scheme {
+	s, err := SchemeByID(id)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Retorna o esquema registrado com o nome (ex: "ed25519").
+func SchemeByName(name string) (Scheme, error) {
+	schemesLock.RLock()
+	defer schemesLock.RUnlock()
+
+	for _, s := range schemes {
+		if s.Name() == name {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signature scheme %q", name)
+}
+
+func (id SchemeID) String() string {
+	if s, err := SchemeByID(id); err == nil {
+		return s.Name()
+	}
+	return fmt.Sprintf("scheme(%d)", byte(id))
+}
+
+// Gera uma chave privada do esquema informado.
+func GenerateSchemeKey(id SchemeID) (PrivateKey, error) {
+	s, err := SchemeByID(id)
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	return s.GenerateKey(rand.Reader)
+}
+
+// Importa uma chave privada serializada por PrivateKey.Bytes.
+func PrivateKeyFromSchemeBytes(id SchemeID, b []byte) (PrivateKey, error) {
+	s, err := SchemeByID(id)
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	return s.PrivateKeyFromBytes(b)
+}
+
+// Serializa a chave privada no formato do esquema (o escalar de 32 bytes nas chaves ECDSA, a semente nas Ed25519).
+func (k PrivateKey) Bytes() []byte {
+	return mustScheme(k.Scheme).PrivateKeyBytes(k)
+}
+
+// Esquema ECDSA sobre uma curva de 256 bits. A P-256 e a secp256k1 compartilham a assinatura
+// (RFC 6979, ver rfc6979.go), a forma canônica low-S e a recuperação da chave pública.
+type ecdsaScheme struct {
+	id    SchemeID
+	name  string
+	curve elliptic.Curve
+
+	// Leitura de pontos SEC1 comprimidos (a da biblioteca padrão só vale para as curvas NIST).
+	unmarshalCompressed func(elliptic.Curve, []byte) (*big.Int, *big.Int)
+}
+
+var (
+	p256Scheme = &ecdsaScheme{
+		id:                  SchemeP256,
+		name:                "p256",
+		curve:               elliptic.P256(),
+		unmarshalCompressed: elliptic.UnmarshalCompressed,
+	}
+	secp256k1Scheme = &ecdsaScheme{
+		id:                  SchemeSecp256k1,
+		name:                "secp256k1",
+		curve:               secp256k1,
+		unmarshalCompressed: unmarshalCompressedSecp256k1,
+	}
+)
+
+// Retorna o esquema ECDSA do ID, ou nil se o esquema não for ECDSA.
+func ecdsaSchemeByID(id SchemeID) *ecdsaScheme {
+	s, _ := SchemeByID(id)
+	es, _ := s.(*ecdsaScheme)
+	return es
+}
+
+func (s *ecdsaScheme) ID() SchemeID { return s.id }
+func (s *ecdsaScheme) Name() string { return s.name }
+
+func (s *ecdsaScheme) GenerateKey(rand io.Reader) (PrivateKey, error) {
+	if s.id == SchemeP256 {
+		key, err := ecdsa.GenerateKey(s.curve, rand)
+		if err != nil {
+			return PrivateKey{}, err
+		}
+		return PrivateKey{Key: key}, nil
+	}
+
+	// O ecdsa.GenerateKey não aceita curvas fora da biblioteca padrão; sorteamos o escalar em [1, n-1].
+	b := make([]byte, 32)
+	for {
+		if _, err := io.ReadFull(rand, b); err != nil {
+			return PrivateKey{}, err
+		}
+		if key, err := s.PrivateKeyFromBytes(b); err == nil {
+			return key, nil
+		}
+	}
+}
+
+func (s *ecdsaScheme) PublicKey(k PrivateKey) PublicKey {
+	return PublicKey{Key: &k.Key.PublicKey, Scheme: s.id}
+}
+
+func (s *ecdsaScheme) PrivateKeyBytes(k PrivateKey) []byte {
+	return k.Key.D.FillBytes(make([]byte, 32))
+}
+
+func (s *ecdsaScheme) PrivateKeyFromBytes(b []byte) (PrivateKey, error) {
+	if s.id == SchemeP256 {
+		return privateKeyFromScalar(b)
+	}
+	if len(b) != 32 {
+		return PrivateKey{}, fmt.Errorf("private key must have 32 bytes, got %d", len(b))
+	}
+
+	d := new(big.Int).SetBytes(b)
+	if d.Sign() == 0 || d.Cmp(s.curve.Params().N) >= 0 {
+		return PrivateKey{}, fmt.Errorf("invalid private key: scalar out of range")
+	}
+	x, y := s.curve.ScalarBaseMult(b)
+	return PrivateKey{
+		Key:    &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: s.curve, X: x, Y: y}, D: d},
+		Scheme: s.id,
+	}, nil
+}
+
+// Chave pública no formato SEC1 comprimido (33 bytes).
+func (s *ecdsaScheme) PublicKeyBytes(k PublicKey) []byte {
+	return elliptic.MarshalCompressed(s.curve, k.Key.X, k.Key.Y)
+}
+
+// Lê uma chave pública SEC1, comprimida (33 bytes) ou não comprimida (65 bytes). O ponto precisa estar na curva.
+func (s *ecdsaScheme) PublicKeyFromBytes(b []byte) (PublicKey, error) {
+	var x, y *big.Int
+	switch {
+	case len(b) == 33 && (b[0] == 0x02 || b[0] == 0x03):
+		x, y = s.unmarshalCompressed(s.curve, b)
+	case len(b) == 65 && b[0] == 0x04:
+		x, y = elliptic.Unmarshal(s.curve, b)
+	default:
+		return PublicKey{}, fmt.Errorf("invalid public key encoding of %d bytes", len(b))
+	}
+
+	// Unmarshal retorna nil para pontos fora da curva (ou coordenadas fora do corpo).
+	if x == nil {
+		return PublicKey{}, fmt.Errorf("public key is not a point on the curve")
+	}
+
+	return PublicKey{Key: &ecdsa.PublicKey{Curve: s.curve, X: x, Y: y}, Scheme: s.id}, nil
+}
+
+// Assina com o nonce do RFC 6979 misturado a bytes do rand.Reader (ver SignHashHedged).
+func (s *ecdsaScheme) SignHash(k PrivateKey, hash types.Hash) (*Signature, error) {
+	return k.SignHashHedged(hash, rand.Reader)
+}
+
+// Assinaturas com r ou s fora de [1, n-1] ou fora da forma canônica (ver IsLowS) são recusadas.
+func (s *ecdsaScheme) VerifyHash(k PublicKey, hash types.Hash, sig *Signature) bool {
+	if k.Key == nil || sig.validate() != nil {
+		return false
+	}
+	return ecdsa.Verify(k.Key, hash[:], sig.R, sig.S) // ecdsa.Verify: Retorna true se a assinatura for válida.
+}
@@ -0,0 +1,168 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/FelipePn10/fadden/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var allSchemes = []SchemeID{SchemeP256, SchemeEd25519, SchemeSecp256k1, SchemeSchnorrP256}
+
+func TestSchemesSignAndVerify(t *testing.T) {
+	for _, id := range allSchemes {
+		key, err := GenerateSchemeKey(id)
+		assert.Nil(t, err, id)
+		pub := key.PublicKey()
+		assert.Equal(t, id, pub.Scheme)
+
+		sig, err := key.Sign([]byte("foo"))
+		assert.Nil(t, err, id)
+		assert.Equal(t, id, sig.Scheme)
+		assert.True(t, sig.Verify(pub, []byte("foo")), id)
+		assert.False(t, sig.Verify(pub, []byte("bar")), id)
+
+		// A assinatura de um esquema não vale com a chave de outro.
+		other, err := GenerateSchemeKey((id + 1) % SchemeID(len(allSchemes)))
+		assert.Nil(t, err)
+		assert.False(t, sig.Verify(other.PublicKey(), []byte("foo")), id)
+	}
+}
+
+func TestSchemeKeySerialization(t *testing.T) {
+	addresses := make(map[types.Address]bool)
+	for _, id := range allSchemes {
+		key, err := GenerateSchemeKey(id)
+		assert.Nil(t, err)
+		pub := key.PublicKey()
+
+		parsed, err := PublicKeyFromBytes(pub.ToSlice())
+		assert.Nil(t, err, id)
+		assert.True(t, pub.Equal(parsed), id)
+		assert.Equal(t, pub.Address(), parsed.Address())
+
+		parsed, err = PublicKeyFromBytes(pub.ToUncompressedSlice())
+		assert.Nil(t, err, id)
+		assert.True(t, pub.Equal(parsed), id)
+
+		restored, err := PrivateKeyFromSchemeBytes(id, key.Bytes())
+		assert.Nil(t, err, id)
+		assert.True(t, pub.Equal(restored.PublicKey()), id)
+
+		buf := &bytes.Buffer{}
+		assert.Nil(t, gob.NewEncoder(buf).Encode(pub))
+		var decoded PublicKey
+		assert.Nil(t, gob.NewDecoder(buf).Decode(&decoded))
+		assert.True(t, pub.Equal(decoded), id)
+
+		addresses[pub.Address()] = true
+	}
+	assert.Len(t, addresses, len(allSchemes))
+}
+
+func TestP256KeysStayUnprefixed(t *testing.T) {
+	pub := GeneratePrivateKey().PublicKey()
+	assert.Len(t, pub.ToSlice(), 33)
+	assert.Contains(t, []byte{0x02, 0x03}, pub.ToSlice()[0])
+}
+
+func TestUnknownScheme(t *testing.T) {
+	_, err := GenerateSchemeKey(42)
+	assert.NotNil(t, err)
+	_, err = PublicKeyFromBytes(append([]byte{schemeKeyPrefix | 42}, make([]byte, 32)...))
+	assert.NotNil(t, err)
+	assert.Panics(t, func() { RegisterScheme(ed25519Scheme{}) })
+}
+
+func TestSecp256k1Curve(t *testing.T) {
+	key, err := PrivateKeyFromSchemeBytes(SchemeSecp256k1, big.NewInt(1).FillBytes(make([]byte, 32)))
+	assert.Nil(t, err)
+	assert.Equal(t, "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		hex.EncodeToString(key.PublicKey().ToSlice()[1:]))
+
+	key, err = PrivateKeyFromSchemeBytes(SchemeSecp256k1, big.NewInt(2).FillBytes(make([]byte, 32)))
+	assert.Nil(t, err)
+	assert.Equal(t, hexInt(t, "C6047F9441ED7D6D3045406E95C07CD85C778E4B8CEF3CA7ABAC09B95C709EE5"), key.Key.X)
+	assert.Equal(t, hexInt(t, "1AE168FEA63DC339A3C58419466CEAEEF7F632653266D0E1236431A950CFE52A"), key.Key.Y)
+
+	c := Secp256k1()
+	gx, gy := c.Params().Gx, c.Params().Gy
+	assert.True(t, c.IsOnCurve(gx, gy))
+	x, y := c.Double(gx, gy)
+	assert.Equal(t, key.Key.X, x)
+	assert.Equal(t, key.Key.Y, y)
+
+	// n*G é o ponto no infinito.
+	x, y = c.ScalarBaseMult(c.Params().N.Bytes())
+	assert.Zero(t, x.Sign())
+	assert.Zero(t, y.Sign())
+
+	_, err = PrivateKeyFromSchemeBytes(SchemeSecp256k1, c.Params().N.Bytes())
+	assert.NotNil(t, err)
+}
+
+func TestSecp256k1Deterministic(t *testing.T) {
+	key, err := PrivateKeyFromSchemeBytes(SchemeSecp256k1, big.NewInt(1).FillBytes(make([]byte, 32)))
+	assert.Nil(t, err)
+	hash := types.Hash(sha256.Sum256([]byte("Satoshi Nakamoto")))
+
+	k := newRFC6979(Secp256k1().Params().N, key.Key.D, hash, nil).next()
+	assert.Equal(t, hexInt(t, "8F8A276C19F4149656B280621E358CCE24F5F52542772691EE69063B74F15D15"), k)
+
+	sig, err := key.SignHashDeterministic(hash)
+	assert.Nil(t, err)
+	assert.Equal(t, hexInt(t, "934B1EA10A4B3C1757E2B0C017D0B6143CE3C9A7E6A4A49860D7A6AB210EE3D8"), sig.R)
+	assert.Equal(t, hexInt(t, "2442CE9D2B916064108014783E923EC36B49743E2FFA1C4496F01A512AAFD9E5"), sig.S)
+	assert.True(t, sig.VerifyHash(key.PublicKey(), hash))
+
+	pub, err := RecoverPublicKey(hash, sig)
+	assert.Nil(t, err)
+	assert.True(t, key.PublicKey().Equal(pub))
+}
+
+func TestEd25519Signatures(t *testing.T) {
+	key, err := GenerateSchemeKey(SchemeEd25519)
+	assert.Nil(t, err)
+	hash := Digest([]byte("foo"))
+
+	// Ed25519 é determinística e tem 64 bytes.
+	a, err := key.SignHash(hash)
+	assert.Nil(t, err)
+	b, err := key.SignHashHedged(hash, bytes.NewReader(make([]byte, hedgeSize)))
+	assert.Nil(t, err)
+	assert.Equal(t, compactBytes(t, a), compactBytes(t, b))
+	assert.Len(t, compactBytes(t, a), 64)
+
+	assert.False(t, a.Recoverable())
+	_, err = RecoverPublicKey(hash, a)
+	assert.NotNil(t, err)
+
+	// Negar r ou s não produz outra assinatura válida.
+	assert.True(t, a.VerifyHash(key.PublicKey(), hash))
+	negR := &Signature{R: new(big.Int).Neg(a.R), S: a.S, Scheme: SchemeEd25519}
+	negS := &Signature{R: a.R, S: new(big.Int).Neg(a.S), Scheme: SchemeEd25519}
+	assert.False(t, negR.VerifyHash(key.PublicKey(), hash))
+	assert.False(t, negS.VerifyHash(key.PublicKey(), hash))
+
+	_, err = key.MarshalPEM()
+	assert.NotNil(t, err)
+}
+
+func TestKeystoreSchemes(t *testing.T) {
+	for _, id := range allSchemes {
+		key, err := GenerateSchemeKey(id)
+		assert.Nil(t, err)
+
+		data, err := EncryptKey(key, "secret", LightScrypt)
+		assert.Nil(t, err)
+		decrypted, err := DecryptKey(data, "secret")
+		assert.Nil(t, err, id)
+		assert.Equal(t, id, decrypted.Scheme)
+		assert.True(t, key.PublicKey().Equal(decrypted.PublicKey()), id)
+	}
+}
@@ -0,0 +1,141 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/FelipePn10/fadden/types"
+)
+
+// Assinaturas Schnorr sobre a P-256. Com a chave privada x, a pública X = x*G e um nonce k:
+//
+//	R = k*G,  e = H(R || X || m),  s = k + e*x (mod n)
+//
+// A assinatura é o par (e, s), guardado em Signature.R e Signature.S (64 bytes no formato compacto).
+// Para verificar, calcula-se R' = s*G - e*X e confere-se H(R' || X || m) = e. Ao contrário do ECDSA,
+// a equação é linear nas chaves e nos nonces, o que permite somar assinaturas de vários participantes
+// em uma única assinatura para a soma das chaves (ver AggregatePublicKeys e MuSigSession).
+//
+// O hash H é um "tagged hash" (como no BIP-340): sha256(sha256(rótulo) || sha256(rótulo) || dados),
+// o que separa os usos do SHA-256 no protocolo.
+
+const schnorrChallengeTag = "fadden/schnorr/challenge"
+
+type schnorrScheme struct{}
+
+func (schnorrScheme) ID() SchemeID { return SchemeSchnorrP256 }
+func (schnorrScheme) Name() string { return "schnorr-p256" }
+
+// As chaves são chaves da P-256 com outro esquema: o mesmo escalar gera endereços diferentes em cada esquema.
+func (s schnorrScheme) GenerateKey(rand io.Reader) (PrivateKey, error) {
+	key, err := p256Scheme.GenerateKey(rand)
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	key.Scheme = SchemeSchnorrP256
+	return key, nil
+}
+
+func (schnorrScheme) PublicKey(k PrivateKey) PublicKey {
+	return PublicKey{Key: &k.Key.PublicKey, Scheme: SchemeSchnorrP256}
+}
+
+func (schnorrScheme) PrivateKeyBytes(k PrivateKey) []byte {
+	return p256Scheme.PrivateKeyBytes(k)
+}
+
+func (schnorrScheme) PrivateKeyFromBytes(b []byte) (PrivateKey, error) {
+	key, err := p256Scheme.PrivateKeyFromBytes(b)
+	key.Scheme = SchemeSchnorrP256
+	return key, err
+}
+
+func (schnorrScheme) PublicKeyBytes(k PublicKey) []byte {
+	return p256Scheme.PublicKeyBytes(k)
+}
+
+func (schnorrScheme) PublicKeyFromBytes(b []byte) (PublicKey, error) {
+	key, err := p256Scheme.PublicKeyFromBytes(b)
+	key.Scheme = SchemeSchnorrP256
+	return key, err
+}
+
+// Assina com um nonce derivado da chave, do hash e de 32 bytes aleatórios (RFC 6979 em modo hedged).
+func (schnorrScheme) SignHash(k PrivateKey, hash types.Hash) (*Signature, error) {
+	extra := make([]byte, hedgeSize)
+	if _, err := io.ReadFull(rand.Reader, extra); err != nil {
+		return nil, err
+	}
+
+	curve := elliptic.P256()
+	n := curve.Params().N
+	nonces := newRFC6979(n, k.Key.D, hash, extra)
+	for i := 0; i < 16; i++ {
+		nonce := nonces.next()
+		rx, ry := curve.ScalarBaseMult(nonce.FillBytes(make([]byte, 32)))
+
+		e := schnorrChallenge(rx, ry, k.Key.X, k.Key.Y, hash)
+		if e.Sign() == 0 {
+			continue
+		}
+		s := new(big.Int).Mul(e, k.Key.D)
+		s.Add(s, nonce)
+		s.Mod(s, n)
+		return &Signature{R: e, S: s, Scheme: SchemeSchnorrP256}, nil
+	}
+	return nil, fmt.Errorf("could not find a valid signature nonce")
+}
+
+func (schnorrScheme) VerifyHash(k PublicKey, hash types.Hash, sig *Signature) bool {
+	curve := elliptic.P256()
+	n := curve.Params().N
+	if k.Key == nil || sig.R == nil || sig.S == nil || sig.V != 0 ||
+		sig.R.Sign() <= 0 || sig.R.Cmp(n) >= 0 || sig.S.Sign() < 0 || sig.S.Cmp(n) >= 0 {
+		return false
+	}
+
+	// R' = s*G - e*X = s*G + (n - e)*X
+	x1, y1 := curve.ScalarBaseMult(sig.S.FillBytes(make([]byte, 32)))
+	negE := new(big.Int).Sub(n, sig.R)
+	x2, y2 := curve.ScalarMult(k.Key.X, k.Key.Y, negE.FillBytes(make([]byte, 32)))
+	rx, ry := curve.Add(x1, y1, x2, y2)
+	if rx.Sign() == 0 && ry.Sign() == 0 {
+		return false
+	}
+
+	return schnorrChallenge(rx, ry, k.Key.X, k.Key.Y, hash).Cmp(sig.R) == 0
+}
+
+// Calcula o desafio e = H(R || X || m) mod n.
+func schnorrChallenge(rx, ry, px, py *big.Int, hash types.Hash) *big.Int {
+	curve := elliptic.P256()
+	return taggedHashInt(schnorrChallengeTag,
+		elliptic.MarshalCompressed(curve, rx, ry),
+		elliptic.MarshalCompressed(curve, px, py),
+		hash[:],
+	)
+}
+
+// Tagged hash reduzido módulo a ordem da P-256.
+func taggedHashInt(tag string, parts ...[]byte) *big.Int {
+	h := taggedHash(tag, parts...)
+	e := new(big.Int).SetBytes(h[:])
+	return e.Mod(e, elliptic.P256().Params().N)
+}
+
+func taggedHash(tag string, parts ...[]byte) [32]byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, p := range parts {
+		h.Write(p)
+	}
+	var out [32]byte
+	h.Sum(out[:0])
+	return out
+}
@@ -0,0 +1,147 @@
+package crypto
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/FelipePn10/fadden/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func schnorrKeys(t *testing.T, n int) ([]PrivateKey, []PublicKey) {
+	privs := make([]PrivateKey, n)
+	pubs := make([]PublicKey, n)
+	for i := range privs {
+		key, err := GenerateSchemeKey(SchemeSchnorrP256)
+		assert.Nil(t, err)
+		privs[i], pubs[i] = key, key.PublicKey()
+	}
+	return privs, pubs
+}
+
+func TestSchnorrSignAndVerify(t *testing.T) {
+	privs, pubs := schnorrKeys(t, 2)
+
+	sig, err := privs[0].Sign([]byte("foo"))
+	assert.Nil(t, err)
+	assert.True(t, sig.Verify(pubs[0], []byte("foo")))
+	assert.False(t, sig.Verify(pubs[0], []byte("bar")))
+	assert.False(t, sig.Verify(pubs[1], []byte("foo")))
+	assert.False(t, sig.Recoverable())
+
+	tampered := *sig
+	tampered.S = new(big.Int).Add(sig.S, big.NewInt(1))
+	assert.False(t, tampered.Verify(pubs[0], []byte("foo")))
+
+	// O mesmo escalar como chave P-256 tem outro endereço e não aceita a assinatura Schnorr.
+	ecdsaKey, err := PrivateKeyFromSchemeBytes(SchemeP256, privs[0].Bytes())
+	assert.Nil(t, err)
+	assert.NotEqual(t, pubs[0].Address(), ecdsaKey.PublicKey().Address())
+	assert.False(t, sig.Verify(ecdsaKey.PublicKey(), []byte("foo")))
+}
+
+// Executa o protocolo MuSig completo com as chaves informadas.
+func musigSign(t *testing.T, privs []PrivateKey, agg *AggregateKey, hash types.Hash) *Signature {
+	ordered := make([]PrivateKey, len(privs))
+	for _, key := range privs {
+		ordered[agg.IndexOf(key.PublicKey())] = key
+	}
+
+	nonces := make([]*MuSigNonce, len(ordered))
+	public := make([]MuSigPublicNonce, len(ordered))
+	for i, key := range ordered {
+		nonce, err := NewMuSigNonce(key, hash)
+		assert.Nil(t, err)
+		nonces[i], public[i] = nonce, nonce.Public
+	}
+
+	session, err := NewMuSigSession(agg, hash, public)
+	assert.Nil(t, err)
+
+	partials := make([]*big.Int, len(ordered))
+	for i, key := range ordered {
+		partials[i], err = session.Sign(key, nonces[i])
+		assert.Nil(t, err)
+	}
+
+	sig, err := session.Aggregate(partials)
+	assert.Nil(t, err)
+	return sig
+}
+
+func TestMuSigAggregation(t *testing.T) {
+	privs, pubs := schnorrKeys(t, 3)
+	agg, err := AggregatePublicKeys(pubs)
+	assert.Nil(t, err)
+
+	// A ordem das chaves não muda a chave agregada.
+	other, err := AggregatePublicKeys([]PublicKey{pubs[2], pubs[0], pubs[1]})
+	assert.Nil(t, err)
+	assert.True(t, agg.Key.Equal(other.Key))
+
+	hash := Digest([]byte("block commit"))
+	sig := musigSign(t, privs, agg, hash)
+
+	// Uma assinatura Schnorr comum de 64 bytes, válida para a chave agregada.
+	assert.Len(t, compactBytes(t, sig), CompactSignatureSize)
+	assert.True(t, sig.VerifyHash(agg.Key, hash))
+	assert.False(t, sig.VerifyHash(agg.Key, Digest([]byte("other"))))
+	for _, pub := range pubs {
+		assert.False(t, sig.VerifyHash(pub, hash))
+	}
+
+	// Um subconjunto dos participantes tem outra chave agregada.
+	subset, err := AggregatePublicKeys(pubs[:2])
+	assert.Nil(t, err)
+	assert.False(t, sig.VerifyHash(subset.Key, hash))
+}
+
+func TestMuSigRejectsMisuse(t *testing.T) {
+	privs, pubs := schnorrKeys(t, 2)
+	agg, err := AggregatePublicKeys(pubs)
+	assert.Nil(t, err)
+	hash := Digest([]byte("foo"))
+
+	_, err = AggregatePublicKeys([]PublicKey{pubs[0], pubs[0]})
+	assert.NotNil(t, err)
+	_, err = AggregatePublicKeys([]PublicKey{GeneratePrivateKey().PublicKey()})
+	assert.NotNil(t, err)
+
+	ordered := make([]PrivateKey, 2)
+	for _, key := range privs {
+		ordered[agg.IndexOf(key.PublicKey())] = key
+	}
+
+	n0, err := NewMuSigNonce(ordered[0], hash)
+	assert.Nil(t, err)
+	n1, err := NewMuSigNonce(ordered[1], hash)
+	assert.Nil(t, err)
+	session, err := NewMuSigSession(agg, hash, []MuSigPublicNonce{n0.Public, n1.Public})
+	assert.Nil(t, err)
+
+	p0, err := session.Sign(ordered[0], n0)
+	assert.Nil(t, err)
+
+	// Um par de nonces não pode ser reutilizado.
+	_, err = session.Sign(ordered[0], n0)
+	assert.NotNil(t, err)
+
+	// Uma parcial inválida é atribuída ao participante que a enviou.
+	p1, err := session.Sign(ordered[1], n1)
+	assert.Nil(t, err)
+	bad := new(big.Int).Add(p1, big.NewInt(1))
+	assert.NotNil(t, session.VerifyPartial(1, bad))
+	_, err = session.Aggregate([]*big.Int{p0, bad})
+	assert.NotNil(t, err)
+
+	sig, err := session.Aggregate([]*big.Int{p0, p1})
+	assert.Nil(t, err)
+	assert.True(t, sig.VerifyHash(agg.Key, hash))
+
+	// Quem não participa da chave agregada não assina.
+	outsider, _ := schnorrKeys(t, 1)
+	nonce, err := NewMuSigNonce(outsider[0], hash)
+	assert.Nil(t, err)
+	_, err = session.Sign(outsider[0], nonce)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,31 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Vetores de teste da RFC 7914, seção 12.
+func TestScryptVectors(t *testing.T) {
+	for _, v := range []struct {
+		password, salt string
+		N, r, p        int
+		expected       string
+	}{
+		{"", "", 16, 1, 1, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+		{"password", "NaCl", 1024, 8, 16, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+	} {
+		key, err := scryptKey([]byte(v.password), []byte(v.salt), v.N, v.r, v.p, 64)
+		assert.Nil(t, err)
+		assert.Equal(t, v.expected, hex.EncodeToString(key))
+	}
+}
+
+func TestScryptInvalidParams(t *testing.T) {
+	_, err := scryptKey([]byte("p"), []byte("s"), 1000, 8, 1, 32)
+	assert.NotNil(t, err)
+	_, err = scryptKey([]byte("p"), []byte("s"), 1024, 0, 1, 32)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,256 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"math/big"
+)
+
+// Curva secp256k1 (SEC 2, a curva do Bitcoin): y² = x³ + 7 sobre o corpo primo p.
+// A biblioteca padrão só implementa as curvas NIST, cujas fórmulas genéricas (elliptic.CurveParams)
+// assumem a = -3; aqui a = 0, então as operações são implementadas em coordenadas jacobianas.
+// A implementação usa big.Int e não é de tempo constante: serve para verificar assinaturas e para
+// ferramentas, não para assinar em máquinas compartilhadas com código não confiável.
+
+type secp256k1Curve struct {
+	params *elliptic.CurveParams
+}
+
+var secp256k1 = newSecp256k1()
+
+func newSecp256k1() *secp256k1Curve {
+	hex := func(s string) *big.Int {
+		n, _ := new(big.Int).SetString(s, 16)
+		return n
+	}
+	return &secp256k1Curve{params: &elliptic.CurveParams{
+		Name:    "secp256k1",
+		BitSize: 256,
+		P:       hex("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F"),
+		N:       hex("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141"),
+		B:       big.NewInt(7),
+		Gx:      hex("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798"),
+		Gy:      hex("483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8"),
+	}}
+}
+
+// Retorna a curva secp256k1.
+func Secp256k1() elliptic.Curve {
+	return secp256k1
+}
+
+func (c *secp256k1Curve) Params() *elliptic.CurveParams {
+	return c.params
+}
+
+// Calcula x³ + 7 mod p.
+func (c *secp256k1Curve) polynomial(x *big.Int) *big.Int {
+	x3 := new(big.Int).Mul(x, x)
+	x3.Mul(x3, x)
+	x3.Add(x3, c.params.B)
+	return x3.Mod(x3, c.params.P)
+}
+
+func (c *secp256k1Curve) IsOnCurve(x, y *big.Int) bool {
+	p := c.params.P
+	if x.Sign() < 0 || x.Cmp(p) >= 0 || y.Sign() < 0 || y.Cmp(p) >= 0 {
+		return false
+	}
+	y2 := new(big.Int).Mul(y, y)
+	y2.Mod(y2, p)
+	return y2.Cmp(c.polynomial(x)) == 0
+}
+
+// Calcula y a partir de x e da paridade, como no formato SEC1 comprimido. Como p ≡ 3 (mod 4),
+// a raiz quadrada é (x³ + 7)^((p+1)/4). Retorna nil se x não for a coordenada de um ponto da curva.
+func (c *secp256k1Curve) decompress(x *big.Int, odd bool) *big.Int {
+	p := c.params.P
+	if x.Cmp(p) >= 0 {
+		return nil
+	}
+
+	y2 := c.polynomial(x)
+	exp := new(big.Int).Add(p, big.NewInt(1))
+	exp.Rsh(exp, 2)
+	y := new(big.Int).Exp(y2, exp, p)
+
+	if check := new(big.Int).Mul(y, y); check.Mod(check, p).Cmp(y2) != 0 {
+		return nil
+	}
+	if y.Bit(0) == 1 != odd {
+		y.Sub(p, y)
+	}
+	return y
+}
+
+// Lê um ponto SEC1 comprimido da secp256k1 (elliptic.UnmarshalCompressed só funciona com a = -3).
+func unmarshalCompressedSecp256k1(curve elliptic.Curve, b []byte) (*big.Int, *big.Int) {
+	if len(b) != 33 || (b[0] != 0x02 && b[0] != 0x03) {
+		return nil, nil
+	}
+	x := new(big.Int).SetBytes(b[1:])
+	y := secp256k1.decompress(x, b[0] == 0x03)
+	if y == nil {
+		return nil, nil
+	}
+	return x, y
+}
+
+// Ponto em coordenadas jacobianas: (X/Z², Y/Z³). Z = 0 representa o ponto no infinito.
+type jacobianPoint struct {
+	x, y, z *big.Int
+}
+
+func (c *secp256k1Curve) toJacobian(x, y *big.Int) jacobianPoint {
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}
+	}
+	return jacobianPoint{new(big.Int).Set(x), new(big.Int).Set(y), big.NewInt(1)}
+}
+
+func (c *secp256k1Curve) toAffine(pt jacobianPoint) (*big.Int, *big.Int) {
+	if pt.z.Sign() == 0 {
+		return new(big.Int), new(big.Int)
+	}
+	p := c.params.P
+	zinv := new(big.Int).ModInverse(pt.z, p)
+	zinv2 := new(big.Int).Mul(zinv, zinv)
+
+	x := new(big.Int).Mul(pt.x, zinv2)
+	x.Mod(x, p)
+	y := zinv2.Mul(zinv2, zinv)
+	y.Mul(y, pt.y)
+	y.Mod(y, p)
+	return x, y
+}
+
+// Duplicação com a = 0 ("dbl-2009-l").
+func (c *secp256k1Curve) double(pt jacobianPoint) jacobianPoint {
+	p := c.params.P
+	if pt.z.Sign() == 0 || pt.y.Sign() == 0 {
+		return jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}
+	}
+
+	a := new(big.Int).Mul(pt.x, pt.x)
+	a.Mod(a, p)
+	b := new(big.Int).Mul(pt.y, pt.y)
+	b.Mod(b, p)
+	cc := new(big.Int).Mul(b, b)
+	cc.Mod(cc, p)
+
+	// d = 2 * ((x + b)² - a - c)
+	d := new(big.Int).Add(pt.x, b)
+	d.Mul(d, d)
+	d.Sub(d, a)
+	d.Sub(d, cc)
+	d.Lsh(d, 1)
+	d.Mod(d, p)
+
+	e := new(big.Int).Mul(a, big.NewInt(3))
+	f := new(big.Int).Mul(e, e)
+
+	x3 := new(big.Int).Sub(f, new(big.Int).Lsh(d, 1))
+	x3.Mod(x3, p)
+
+	y3 := new(big.Int).Sub(d, x3)
+	y3.Mul(y3, e)
+	y3.Sub(y3, new(big.Int).Lsh(cc, 3))
+	y3.Mod(y3, p)
+
+	z3 := new(big.Int).Mul(pt.y, pt.z)
+	z3.Lsh(z3, 1)
+	z3.Mod(z3, p)
+
+	return jacobianPoint{x3, y3, z3}
+}
+
+// Soma de pontos ("add-2007-bl").
+func (c *secp256k1Curve) add(p1, p2 jacobianPoint) jacobianPoint {
+	if p1.z.Sign() == 0 {
+		return p2
+	}
+	if p2.z.Sign() == 0 {
+		return p1
+	}
+	p := c.params.P
+
+	z1z1 := new(big.Int).Mul(p1.z, p1.z)
+	z1z1.Mod(z1z1, p)
+	z2z2 := new(big.Int).Mul(p2.z, p2.z)
+	z2z2.Mod(z2z2, p)
+
+	u1 := new(big.Int).Mul(p1.x, z2z2)
+	u1.Mod(u1, p)
+	u2 := new(big.Int).Mul(p2.x, z1z1)
+	u2.Mod(u2, p)
+
+	s1 := new(big.Int).Mul(p1.y, p2.z)
+	s1.Mul(s1, z2z2)
+	s1.Mod(s1, p)
+	s2 := new(big.Int).Mul(p2.y, p1.z)
+	s2.Mul(s2, z1z1)
+	s2.Mod(s2, p)
+
+	if u1.Cmp(u2) == 0 {
+		if s1.Cmp(s2) == 0 {
+			return c.double(p1)
+		}
+		return jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}
+	}
+
+	h := new(big.Int).Sub(u2, u1)
+	i := new(big.Int).Lsh(h, 1)
+	i.Mul(i, i)
+	i.Mod(i, p)
+	j := new(big.Int).Mul(h, i)
+	j.Mod(j, p)
+	r := new(big.Int).Sub(s2, s1)
+	r.Lsh(r, 1)
+	v := new(big.Int).Mul(u1, i)
+	v.Mod(v, p)
+
+	x3 := new(big.Int).Mul(r, r)
+	x3.Sub(x3, j)
+	x3.Sub(x3, new(big.Int).Lsh(v, 1))
+	x3.Mod(x3, p)
+
+	y3 := new(big.Int).Sub(v, x3)
+	y3.Mul(y3, r)
+	y3.Sub(y3, new(big.Int).Lsh(new(big.Int).Mul(s1, j), 1))
+	y3.Mod(y3, p)
+
+	z3 := new(big.Int).Add(p1.z, p2.z)
+	z3.Mul(z3, z3)
+	z3.Sub(z3, z1z1)
+	z3.Sub(z3, z2z2)
+	z3.Mul(z3, h)
+	z3.Mod(z3, p)
+
+	return jacobianPoint{x3, y3, z3}
+}
+
+func (c *secp256k1Curve) Add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	return c.toAffine(c.add(c.toJacobian(x1, y1), c.toJacobian(x2, y2)))
+}
+
+func (c *secp256k1Curve) Double(x1, y1 *big.Int) (*big.Int, *big.Int) {
+	return c.toAffine(c.double(c.toJacobian(x1, y1)))
+}
+
+// Multiplicação escalar pelo método "double-and-add", percorrendo os bits de k (big-endian).
+func (c *secp256k1Curve) ScalarMult(x1, y1 *big.Int, k []byte) (*big.Int, *big.Int) {
+	base := c.toJacobian(x1, y1)
+	acc := jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}
+	for _, b := range k {
+		for bit := 7; bit >= 0; bit-- {
+			acc = c.double(acc)
+			if b>>bit&1 == 1 {
+				acc = c.add(acc, base)
+			}
+		}
+	}
+	return c.toAffine(acc)
+}
+
+func (c *secp256k1Curve) ScalarBaseMult(k []byte) (*big.Int, *big.Int) {
+	return c.ScalarMult(c.params.Gx, c.params.Gy, k)
+}
@@ -0,0 +1,170 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Maleabilidade: se (r, s) é uma assinatura ECDSA válida, (r, n - s) também é, onde n é a ordem
+// da curva. Sem uma forma canônica, qualquer um poderia trocar a assinatura de uma transação e,
+// com isso, o hash dela. Por isso só aceitamos assinaturas "low-S", com s <= n/2.
+//
+// Formatos de serialização:
+//   - Compacto: r || s, 32 bytes cada (64 bytes).
+//   - Recuperável: r || s || v (65 bytes), onde v é o ID de recuperação da chave pública.
+//   - DER: SEQUENCE { INTEGER r, INTEGER s } (ASN.1), o formato do openssl e do x509.
+// Na leitura, r e s precisam estar em [1, n-1] e s na forma canônica.
+// As regras valem para os esquemas ECDSA (ver Scheme), cada um com a ordem n da sua curva;
+// as funções de leitura produzem assinaturas P-256.
+
+const (
+	CompactSignatureSize     = 64
+	RecoverableSignatureSize = 65
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Ordem da curva do esquema da assinatura, ou nil se o esquema não for ECDSA.
+func (sig Signature) order() *big.Int {
+	if s := ecdsaSchemeByID(sig.Scheme); s != nil {
+		return s.curve.Params().N
+	}
+	return nil
+}
+
+// Verifica se a assinatura ECDSA está na forma canônica (s <= n/2).
+func (sig Signature) IsLowS() bool {
+	n := sig.order()
+	if n == nil || sig.S == nil || sig.S.Sign() <= 0 {
+		return false
+	}
+	return sig.S.Cmp(new(big.Int).Rsh(n, 1)) <= 0
+}
+
+// Retorna a assinatura equivalente na forma canônica. Trocar s por n - s equivale a usar o
+// ponto -R, então a paridade do ID de recuperação também muda.
+func (sig Signature) Normalize() *Signature {
+	if n := sig.order(); n != nil && sig.S != nil && sig.S.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		return &Signature{R: sig.R, S: new(big.Int).Sub(n, sig.S), V: sig.V ^ 1, Scheme: sig.Scheme}
+	}
+	return &Signature{R: sig.R, S: sig.S, V: sig.V, Scheme: sig.Scheme}
+}
+
+// Verifica se a assinatura é ECDSA, r e s estão em [1, n-1] e s está na forma canônica.
+func (sig Signature) validate() error {
+	n := sig.order()
+	if n == nil {
+		return fmt.Errorf("%w: %s is not an ECDSA scheme", ErrInvalidSignature, sig.Scheme)
+	}
+	if sig.R == nil || sig.R.Sign() <= 0 || sig.R.Cmp(n) >= 0 {
+		return fmt.Errorf("%w: r out of range", ErrInvalidSignature)
+	}
+	if sig.S == nil || sig.S.Sign() <= 0 || sig.S.Cmp(n) >= 0 {
+		return fmt.Errorf("%w: s out of range", ErrInvalidSignature)
+	}
+	if !sig.IsLowS() {
+		return fmt.Errorf("%w: s is not canonical (low-S)", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// Serializa a assinatura no formato compacto (r || s, 64 bytes). Uma assinatura vazia resulta em nil.
+// Retorna erro se r ou s forem negativos ou não couberem em 32 bytes: assinaturas recebidas de
+// peers podem ter qualquer valor, e a serialização não pode falhar com um panic.
+func (sig Signature) ToBytes() ([]byte, error) {
+	if sig.R == nil || sig.S == nil {
+		return nil, nil
+	}
+	if sig.R.Sign() < 0 || sig.R.BitLen() > 256 {
+		return nil, fmt.Errorf("%w: r out of range", ErrInvalidSignature)
+	}
+	if sig.S.Sign() < 0 || sig.S.BitLen() > 256 {
+		return nil, fmt.Errorf("%w: s out of range", ErrInvalidSignature)
+	}
+
+	b := make([]byte, CompactSignatureSize)
+	sig.R.FillBytes(b[:32])
+	sig.S.FillBytes(b[32:])
+	return b, nil
+}
+
+// Lê uma assinatura no formato compacto.
+func SignatureFromBytes(b []byte) (*Signature, error) {
+	if len(b) != CompactSignatureSize {
+		return nil, fmt.Errorf("%w: compact signature must have %d bytes, got %d", ErrInvalidSignature, CompactSignatureSize, len(b))
+	}
+
+	sig := &Signature{
+		R: new(big.Int).SetBytes(b[:32]),
+		S: new(big.Int).SetBytes(b[32:]),
+	}
+	if err := sig.validate(); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// Serializa a assinatura no formato recuperável (r || s || v, 65 bytes). Falha nos mesmos casos de ToBytes.
+func (sig Signature) ToRecoverableBytes() ([]byte, error) {
+	b, err := sig.ToBytes()
+	if b == nil {
+		return nil, err
+	}
+	return append(b, sig.V), nil
+}
+
+// Lê uma assinatura no formato recuperável. O ID de recuperação precisa estar em [0, 3].
+func SignatureFromRecoverableBytes(b []byte) (*Signature, error) {
+	if len(b) != RecoverableSignatureSize {
+		return nil, fmt.Errorf("%w: recoverable signature must have %d bytes, got %d", ErrInvalidSignature, RecoverableSignatureSize, len(b))
+	}
+	if b[64] > 3 {
+		return nil, fmt.Errorf("%w: recovery id %d out of range", ErrInvalidSignature, b[64])
+	}
+
+	sig, err := SignatureFromBytes(b[:64])
+	if err != nil {
+		return nil, err
+	}
+	sig.V = b[64]
+	return sig, nil
+}
+
+type derSignature struct {
+	R, S *big.Int
+}
+
+// Serializa a assinatura em ASN.1 DER.
+func (sig Signature) ToDER() ([]byte, error) {
+	if sig.R == nil || sig.S == nil {
+		return nil, fmt.Errorf("%w: empty signature", ErrInvalidSignature)
+	}
+	return asn1.Marshal(derSignature{R: sig.R, S: sig.S})
+}
+
+// Lê uma assinatura em ASN.1 DER. Só a codificação canônica é aceita: bytes extras,
+// inteiros com zeros à esquerda ou negativos são recusados.
+func SignatureFromDER(b []byte) (*Signature, error) {
+	var der derSignature
+	rest, err := asn1.Unmarshal(b, &der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: trailing data after DER signature", ErrInvalidSignature)
+	}
+
+	sig := &Signature{R: der.R, S: der.S}
+	if err := sig.validate(); err != nil {
+		return nil, err
+	}
+
+	// Reencodar precisa gerar os mesmos bytes; caso contrário a codificação não é a canônica.
+	if canonical, err := sig.ToDER(); err != nil || !bytes.Equal(canonical, b) {
+		return nil, fmt.Errorf("%w: non-canonical DER encoding", ErrInvalidSignature)
+	}
+	return sig, nil
+}
@@ -0,0 +1,153 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var curveOrder = elliptic.P256().Params().N
+
+func TestSignProducesLowS(t *testing.T) {
+	privKey := GeneratePrivateKey()
+	for i := 0; i < 32; i++ {
+		sig, err := privKey.Sign([]byte{byte(i)})
+		assert.Nil(t, err)
+		assert.True(t, sig.IsLowS())
+	}
+}
+
+func TestVerifyRejectsHighS(t *testing.T) {
+	privKey := GeneratePrivateKey()
+	msg := []byte("Hello, World!")
+
+	sig, err := privKey.Sign(msg)
+	assert.Nil(t, err)
+
+	// (r, n - s) também é matematicamente válida, mas não está na forma canônica.
+	high := &Signature{R: sig.R, S: new(big.Int).Sub(curveOrder, sig.S)}
+	assert.False(t, high.IsLowS())
+	assert.False(t, high.Verify(privKey.PublicKey(), msg))
+
+	assert.Equal(t, sig.S, high.Normalize().S)
+	assert.True(t, high.Normalize().Verify(privKey.PublicKey(), msg))
+}
+
+func TestSignatureBytes(t *testing.T) {
+	sig := &Signature{R: big.NewInt(1), S: big.NewInt(2)}
+	b := compactBytes(t, sig)
+	assert.Len(t, b, 64)
+	assert.Equal(t, byte(1), b[31])
+	assert.Equal(t, byte(2), b[63])
+	assert.Nil(t, compactBytes(t, &Signature{}))
+}
+
+func TestSignatureBytesOutOfRange(t *testing.T) {
+	huge := new(big.Int).Lsh(big.NewInt(1), 300)
+	for name, sig := range map[string]Signature{
+		"huge r":     {R: huge, S: big.NewInt(1)},
+		"huge s":     {R: big.NewInt(1), S: huge},
+		"negative r": {R: big.NewInt(-1), S: big.NewInt(1)},
+		"negative s": {R: big.NewInt(1), S: big.NewInt(-1)},
+	} {
+		_, err := sig.ToBytes()
+		assert.ErrorIs(t, err, ErrInvalidSignature, name)
+		_, err = sig.ToRecoverableBytes()
+		assert.ErrorIs(t, err, ErrInvalidSignature, name)
+	}
+}
+
+func compactBytes(t *testing.T, sig *Signature) []byte {
+	b, err := sig.ToBytes()
+	assert.Nil(t, err)
+	return b
+}
+
+func recoverableBytes(t *testing.T, sig *Signature) []byte {
+	b, err := sig.ToRecoverableBytes()
+	assert.Nil(t, err)
+	return b
+}
+
+func TestSignatureCompactRoundTrip(t *testing.T) {
+	sig, err := GeneratePrivateKey().Sign([]byte("foo"))
+	assert.Nil(t, err)
+
+	parsed, err := SignatureFromBytes(compactBytes(t, sig))
+	assert.Nil(t, err)
+	assert.Equal(t, sig.R, parsed.R)
+	assert.Equal(t, sig.S, parsed.S)
+
+	_, err = SignatureFromBytes(compactBytes(t, sig)[:63])
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestSignatureRecoverableRoundTrip(t *testing.T) {
+	sig, err := GeneratePrivateKey().Sign([]byte("foo"))
+	assert.Nil(t, err)
+	sig.V = 1
+
+	b := recoverableBytes(t, sig)
+	assert.Len(t, b, RecoverableSignatureSize)
+	parsed, err := SignatureFromRecoverableBytes(b)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(1), parsed.V)
+	assert.Equal(t, sig.S, parsed.S)
+
+	b[64] = 4
+	_, err = SignatureFromRecoverableBytes(b)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestSignatureDERRoundTrip(t *testing.T) {
+	privKey := GeneratePrivateKey()
+	sig, err := privKey.Sign([]byte("foo"))
+	assert.Nil(t, err)
+
+	der, err := sig.ToDER()
+	assert.Nil(t, err)
+	assert.Equal(t, byte(0x30), der[0])
+
+	parsed, err := SignatureFromDER(der)
+	assert.Nil(t, err)
+	assert.True(t, parsed.Verify(privKey.PublicKey(), []byte("foo")))
+
+	_, err = SignatureFromDER(append(der, 0))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = SignatureFromDER(der[:len(der)-1])
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestSignatureParseRejectsInvalidValues(t *testing.T) {
+	sig, err := GeneratePrivateKey().Sign([]byte("foo"))
+	assert.Nil(t, err)
+	high := Signature{R: sig.R, S: new(big.Int).Sub(curveOrder, sig.S)}
+	highDER, err := high.ToDER()
+	assert.Nil(t, err)
+
+	for name, invalid := range map[string]Signature{
+		"zero r":      {R: big.NewInt(0), S: sig.S},
+		"zero s":      {R: sig.R, S: big.NewInt(0)},
+		"r >= n":      {R: new(big.Int).Set(curveOrder), S: sig.S},
+		"high s":      high,
+		"r overflows": {R: new(big.Int).Add(curveOrder, big.NewInt(1)), S: sig.S},
+	} {
+		_, err := SignatureFromBytes(compactBytes(t, &invalid))
+		assert.ErrorIs(t, err, ErrInvalidSignature, name)
+	}
+
+	_, err = SignatureFromDER(highDER)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestVerifyRejectsOutOfRange(t *testing.T) {
+	privKey := GeneratePrivateKey()
+	sig, err := privKey.Sign([]byte("foo"))
+	assert.Nil(t, err)
+
+	assert.False(t, Signature{R: big.NewInt(0), S: sig.S}.Verify(privKey.PublicKey(), []byte("foo")))
+	assert.False(t, Signature{R: new(big.Int).Add(sig.R, curveOrder), S: sig.S}.Verify(privKey.PublicKey(), []byte("foo")))
+	assert.False(t, Signature{R: sig.R}.Verify(privKey.PublicKey(), []byte("foo")))
+}
@@ -0,0 +1,284 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/FelipePn10/fadden/core"
+	"github.com/FelipePn10/fadden/crypto"
+	"github.com/FelipePn10/fadden/types"
+)
+
+// Método do RequestManager usado para pedir as transações que faltaram na reconstrução de um bloco.
+const getBlockTxnMethod = "getblocktxn"
+
+// ShortID: Identificador curto (6 bytes) de uma transação dentro de um bloco compacto.
+type ShortID [6]byte
+
+// Calcula o ShortID de uma transação. O ID depende do hash do bloco e de um nonce aleatório,
+// assim um atacante não consegue fabricar transações que colidam com as de blocos futuros.
+func shortTxID(blockHash types.Hash, nonce uint64, txHash types.Hash) ShortID {
+	h := sha256.New()
+	h.Write(blockHash.ToSlice())
+	binary.Write(h, binary.BigEndian, nonce)
+	h.Write(txHash.ToSlice())
+
+	var id ShortID
+	copy(id[:], h.Sum(nil))
+	return id
+}
+
+// PrefilledTx: Transação enviada por completo dentro do bloco compacto (ex: uma que o peer
+// provavelmente ainda não tem). Index é a posição da transação no bloco.
+type PrefilledTx struct {
+	Index uint32
+	Tx    *core.Transaction
+}
+
+// CompactBlock: Bloco em que as transações são substituídas por ShortIDs.
+// Quem recebe reconstrói o bloco com as transações do próprio mempool e só pede as que faltam.
+type CompactBlock struct {
+	Header    *core.Header
+	Validator crypto.PublicKey
+	Signature *crypto.Signature
+	Nonce     uint64
+	ShortIDs  []ShortID     // Um ShortID por transação não pré-preenchida, na ordem do bloco
+	Prefilled []PrefilledTx // Ordenadas por Index
+}
+
+// Cria o bloco compacto de um bloco. As transações nas posições prefill são enviadas por completo.
+func NewCompactBlock(b *core.Block, prefill ...int) *CompactBlock {
+	var nonce [8]byte
+	rand.Read(nonce[:])
+
+	cb := &CompactBlock{
+		Header:    b.Header,
+		Validator: b.Validator,
+		Signature: b.Signature,
+		Nonce:     binary.BigEndian.Uint64(nonce[:]),
+	}
+
+	filled := make(map[int]bool, len(prefill))
+	for _, i := range prefill {
+		filled[i] = true
+	}
+
+	blockHash := cb.Hash()
+	for i := range b.Transactions {
+		tx := &b.Transactions[i]
+		if filled[i] {
+			cb.Prefilled = append(cb.Prefilled, PrefilledTx{Index: uint32(i), Tx: tx})
+			continue
+		}
+		cb.ShortIDs = append(cb.ShortIDs, shortTxID(blockHash, cb.Nonce, tx.Hash(core.TxHasher{})))
+	}
+
+	return cb
+}
+
+// Retorna o hash do bloco representado.
+func (cb *CompactBlock) Hash() types.Hash {
+	return core.BlockHasher{}.Hash(cb.Header)
+}
+
+// Retorna o número total de transações do bloco.
+func (cb *CompactBlock) TxCount() int {
+	return len(cb.ShortIDs) + len(cb.Prefilled)
+}
+
+// Reconstrói o bloco com as transações do mempool.
+// Transações ausentes, ou cujo ShortID é ambíguo no mempool, ficam como faltantes no PartialBlock.
+func (cb *CompactBlock) Reconstruct(pool *TxPool) (*PartialBlock, error) {
+	count := cb.TxCount()
+	pb := &PartialBlock{
+		cb:  cb,
+		txs: make([]*core.Transaction, count),
+	}
+
+	for _, p := range cb.Prefilled {
+		if int(p.Index) >= count || pb.txs[p.Index] != nil || p.Tx == nil {
+			return nil, fmt.Errorf("invalid prefilled transaction at index %d", p.Index)
+		}
+		if err := p.Tx.Verify(); err != nil {
+			return nil, fmt.Errorf("invalid prefilled transaction at index %d: %w", p.Index, err)
+		}
+		pb.txs[p.Index] = p.Tx
+	}
+
+	blockHash := cb.Hash()
+	candidates := make(map[ShortID]*core.Transaction)
+	ambiguous := make(map[ShortID]bool)
+	for _, tx := range pool.Transactions() {
+		id := shortTxID(blockHash, cb.Nonce, tx.Hash(core.TxHasher{}))
+		if _, ok := candidates[id]; ok {
+			ambiguous[id] = true
+		}
+		candidates[id] = tx
+	}
+
+	next := 0
+	for i := range pb.txs {
+		if pb.txs[i] != nil {
+			continue
+		}
+
+		id := cb.ShortIDs[next]
+		next++
+
+		if tx, ok := candidates[id]; ok && !ambiguous[id] {
+			pb.txs[i] = tx
+			continue
+		}
+		pb.missing = append(pb.missing, uint32(i))
+	}
+
+	return pb, nil
+}
+
+// PartialBlock: Bloco compacto em reconstrução.
+type PartialBlock struct {
+	cb      *CompactBlock
+	txs     []*core.Transaction
+	missing []uint32
+}
+
+// Retorna as posições das transações que ainda faltam.
+func (pb *PartialBlock) Missing() []uint32 {
+	return pb.missing
+}
+
+// Preenche as transações que faltavam, na ordem de Missing, e retorna o bloco completo.
+// Cada transação recebida precisa ser válida e ter o ShortID esperado para a sua posição.
+func (pb *PartialBlock) Fill(txx []*core.Transaction) (*core.Block, error) {
+	if len(txx) != len(pb.missing) {
+		return nil, fmt.Errorf("expected %d missing transactions, got %d", len(pb.missing), len(txx))
+	}
+
+	blockHash := pb.cb.Hash()
+	expected := pb.expectedShortIDs()
+	for i, idx := range pb.missing {
+		if txx[i] == nil {
+			return nil, fmt.Errorf("missing transaction at index %d", idx)
+		}
+		// A transação vem do peer: só é usada (e só tem o hash calculado) depois de verificada.
+		if err := txx[i].Verify(); err != nil {
+			return nil, fmt.Errorf("invalid transaction at index %d: %w", idx, err)
+		}
+		id := shortTxID(blockHash, pb.cb.Nonce, txx[i].Hash(core.TxHasher{}))
+		if id != expected[idx] {
+			return nil, fmt.Errorf("transaction at index %d does not match the compact block", idx)
+		}
+		pb.txs[idx] = txx[i]
+	}
+	pb.missing = nil
+
+	return pb.Block()
+}
+
+// Retorna o bloco completo. Falha enquanto houver transações faltando ou se as transações
+// reconstruídas não corresponderem ao Datahash do cabeçalho.
+func (pb *PartialBlock) Block() (*core.Block, error) {
+	if len(pb.missing) > 0 {
+		return nil, fmt.Errorf("block (%s) is missing %d transactions", pb.cb.Hash(), len(pb.missing))
+	}
+
+	txx := make([]core.Transaction, len(pb.txs))
+	for i, tx := range pb.txs {
+		txx[i] = *tx
+	}
+
+	b := core.NewBlock(pb.cb.Header, txx)
+	b.Validator = pb.cb.Validator
+	b.Signature = pb.cb.Signature
+
+	// Um ShortID pode colidir com outra transação do mempool: o Datahash do cabeçalho garante
+	// que o bloco foi reconstruído com as transações certas.
+	if err := b.VerifyDataHash(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Mapeia cada posição não pré-preenchida para o ShortID esperado.
+func (pb *PartialBlock) expectedShortIDs() map[uint32]ShortID {
+	prefilled := make(map[uint32]bool, len(pb.cb.Prefilled))
+	for _, p := range pb.cb.Prefilled {
+		prefilled[p.Index] = true
+	}
+
+	ids := make(map[uint32]ShortID, len(pb.cb.ShortIDs))
+	next := 0
+	for i := 0; i < pb.cb.TxCount(); i++ {
+		if prefilled[uint32(i)] {
+			continue
+		}
+		ids[uint32(i)] = pb.cb.ShortIDs[next]
+		next++
+	}
+	return ids
+}
+
+// GetBlockTxn: Pedido das transações de um bloco nas posições Indexes.
+type GetBlockTxn struct {
+	BlockHash types.Hash
+	Indexes   []uint32
+}
+
+// BlockTxn: Resposta a um GetBlockTxn, com as transações na ordem pedida.
+type BlockTxn struct {
+	BlockHash    types.Hash
+	Transactions []*core.Transaction
+}
+
+// blockCache: Cache limitado dos blocos recentes, usado para atender pedidos GetBlockTxn.
+// Quando cheio, o bloco mais antigo é descartado.
+type blockCache struct {
+	lock   sync.RWMutex
+	size   int
+	order  []types.Hash
+	blocks map[types.Hash]*core.Block
+}
+
+func newBlockCache(size int) *blockCache {
+	return &blockCache{
+		size:   size,
+		blocks: make(map[types.Hash]*core.Block),
+	}
+}
+
+// Adiciona um bloco ao cache. Retorna false se o bloco já estava no cache.
+func (c *blockCache) Add(b *core.Block) bool {
+	hash := b.Hash(core.BlockHasher{})
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.blocks[hash]; ok {
+		return false
+	}
+	if len(c.order) == c.size {
+		delete(c.blocks, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.order = append(c.order, hash)
+	c.blocks[hash] = b
+
+	return true
+}
+
+func (c *blockCache) Get(hash types.Hash) (*core.Block, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	b, ok := c.blocks[hash]
+	return b, ok
+}
+
+func (c *blockCache) Has(hash types.Hash) bool {
+	_, ok := c.Get(hash)
+	return ok
+}
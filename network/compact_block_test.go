@@ -0,0 +1,170 @@
+package network
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/FelipePn10/fadden/core"
+	"github.com/FelipePn10/fadden/crypto"
+	"github.com/FelipePn10/fadden/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactBlockReconstructFromPool(t *testing.T) {
+	b := randomBlockWithTxs(t, 5)
+	pool := NewTxPool()
+	for i := range b.Transactions {
+		assert.Nil(t, pool.Add(&b.Transactions[i]))
+	}
+	pool.Add(randomTx(t, "unrelated"))
+
+	cb := NewCompactBlock(b)
+	assert.Len(t, cb.ShortIDs, 5)
+
+	pb, err := cb.Reconstruct(pool)
+	assert.Nil(t, err)
+	assert.Empty(t, pb.Missing())
+
+	rb, err := pb.Block()
+	assert.Nil(t, err)
+	assert.Nil(t, rb.Verify())
+	assert.Equal(t, b.Hash(core.BlockHasher{}), rb.Hash(core.BlockHasher{}))
+	assert.Equal(t, b.Transactions, rb.Transactions)
+}
+
+func TestCompactBlockFillMissing(t *testing.T) {
+	b := randomBlockWithTxs(t, 5)
+	pool := NewTxPool()
+	for _, i := range []int{0, 1, 3} {
+		assert.Nil(t, pool.Add(&b.Transactions[i]))
+	}
+
+	cb := NewCompactBlock(b, 4)
+	assert.Len(t, cb.ShortIDs, 4)
+	assert.Len(t, cb.Prefilled, 1)
+
+	pb, err := cb.Reconstruct(pool)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{2}, pb.Missing())
+
+	_, err = pb.Block()
+	assert.NotNil(t, err)
+
+	_, err = pb.Fill([]*core.Transaction{randomTx(t, "wrong")})
+	assert.NotNil(t, err)
+
+	rb, err := pb.Fill([]*core.Transaction{&b.Transactions[2]})
+	assert.Nil(t, err)
+	assert.Equal(t, b.Transactions, rb.Transactions)
+}
+
+func TestCompactBlockInvalidPrefilled(t *testing.T) {
+	cb := NewCompactBlock(randomBlockWithTxs(t, 2), 0)
+	cb.Prefilled[0].Index = 7
+
+	_, err := cb.Reconstruct(NewTxPool())
+	assert.NotNil(t, err)
+}
+
+func TestServerCompactBlockRelay(t *testing.T) {
+	tra, trb := connectedTransports(t, "A", "B")
+	sa := startServer(t, ServerOpts{Transports: []Trasport{tra}})
+	sb := startServer(t, ServerOpts{Transports: []Trasport{trb}})
+	assert.Nil(t, sa.AddPeer(trb.Addr(), tra))
+	assert.Eventually(t, func() bool {
+		return sa.PeerInfo(trb.Addr()).Supports(CapCompactBlocks)
+	}, time.Second, 5*time.Millisecond)
+
+	b := randomBlockWithTxs(t, 4)
+	// B conhece apenas metade das transações e precisa pedir o resto para A.
+	sb.memPool.Add(&b.Transactions[0])
+	sb.memPool.Add(&b.Transactions[1])
+
+	assert.Nil(t, sa.RelayBlock(b))
+
+	assert.Eventually(t, func() bool {
+		return sb.blocks.Has(b.Hash(core.BlockHasher{}))
+	}, time.Second, 5*time.Millisecond)
+
+	rb, _ := sb.blocks.Get(b.Hash(core.BlockHasher{}))
+	assert.Len(t, rb.Transactions, 4)
+	assert.Nil(t, rb.Verify())
+}
+
+func TestServerBoundsCompactBlockFetches(t *testing.T) {
+	tra, trb := connectedTransports(t, "A", "B")
+	// B nunca responde: os pedidos de transações ficam pendentes até expirar.
+	s := NewServer(ServerOpts{Transports: []Trasport{tra}, RequestOpts: RequestOpts{Timeout: 100 * time.Millisecond}})
+
+	first := NewCompactBlock(randomBlockWithTxs(t, 1))
+	assert.Nil(t, s.handleCompactBlock(trb.Addr(), first))
+	for i := 1; i < maxPeerBlockFetches; i++ {
+		assert.Nil(t, s.handleCompactBlock(trb.Addr(), NewCompactBlock(randomBlockWithTxs(t, 1))))
+	}
+	assert.NotNil(t, s.handleCompactBlock(trb.Addr(), NewCompactBlock(randomBlockWithTxs(t, 1))))
+
+	// O mesmo bloco recebido de novo não abre outro pedido.
+	assert.Nil(t, s.handleCompactBlock(trb.Addr(), first))
+
+	// Quando os pedidos expiram, as vagas são liberadas.
+	assert.Eventually(t, func() bool {
+		s.fetchLock.Lock()
+		defer s.fetchLock.Unlock()
+		return len(s.fetching) == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestCompactBlockFillRejectsMalformedSignature(t *testing.T) {
+	b := randomBlockWithTxs(t, 2)
+	pb, err := NewCompactBlock(b).Reconstruct(NewTxPool())
+	assert.Nil(t, err)
+	assert.Len(t, pb.Missing(), 2)
+
+	// Uma resposta BlockTxn com r de 300 bits não pode derrubar o nó.
+	malformed := *randomTx(t, "malformed")
+	malformed.Signature = &crypto.Signature{R: new(big.Int).Lsh(big.NewInt(1), 300), S: big.NewInt(1)}
+	_, err = pb.Fill([]*core.Transaction{&b.Transactions[0], &malformed})
+	assert.ErrorIs(t, err, crypto.ErrInvalidSignature)
+}
+
+func TestCompactBlockRejectsWrongTransactions(t *testing.T) {
+	b := randomBlockWithTxs(t, 3)
+	pool := NewTxPool()
+	for i := range b.Transactions {
+		assert.Nil(t, pool.Add(&b.Transactions[i]))
+	}
+
+	pb, err := NewCompactBlock(b).Reconstruct(pool)
+	assert.Nil(t, err)
+	assert.Empty(t, pb.Missing())
+
+	// Simula uma colisão de ShortID: outra transação do mempool ocupa a posição 1.
+	pb.txs[1] = randomTx(t, "collision")
+	_, err = pb.Block()
+	assert.NotNil(t, err)
+}
+
+func randomTx(t *testing.T, data string) *core.Transaction {
+	tx := core.NewTransaction([]byte(data))
+	assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+	return tx
+}
+
+func randomBlockWithTxs(t *testing.T, n int) *core.Block {
+	txx := make([]core.Transaction, n)
+	for i := range txx {
+		txx[i] = *randomTx(t, fmt.Sprintf("tx-%d-%s", i, types.RandomHash()))
+	}
+
+	b := core.NewBlock(&core.Header{
+		Version:       1,
+		PrevBlockHash: types.RandomHash(),
+		Height:        1,
+		Timestamp:     uint64(time.Now().UnixNano()),
+	}, txx)
+	assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+
+	return b
+}
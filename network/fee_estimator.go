@@ -0,0 +1,97 @@
+package network
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/FelipePn10/fadden/core"
+)
+
+var (
+	defaultFeeWindow   = 20
+	defaultTxsPerBlock = 1000
+)
+
+// FeeEstimatorOpts define as opções do estimador de taxas.
+// Window é o número de blocos recentes considerados (padrão 20) e TxsPerBlock é quantas
+// transações se espera que caibam no próximo bloco (padrão 1000).
+type FeeEstimatorOpts struct {
+	Window      int
+	TxsPerBlock int
+}
+
+// FeeEstimator: Sugere a taxa por byte para uma transação entrar no próximo bloco,
+// combinando a menor taxa aceita nos blocos recentes com a concorrência atual do pool.
+type FeeEstimator struct {
+	opts FeeEstimatorOpts
+	pool *TxPool
+
+	lock   sync.RWMutex
+	blocks []float64 // Menor taxa por byte incluída em cada bloco recente, do mais antigo ao mais novo
+}
+
+func NewFeeEstimator(pool *TxPool, opts FeeEstimatorOpts) *FeeEstimator {
+	if opts.Window <= 0 {
+		opts.Window = defaultFeeWindow
+	}
+	if opts.TxsPerBlock <= 0 {
+		opts.TxsPerBlock = defaultTxsPerBlock
+	}
+
+	return &FeeEstimator{
+		opts: opts,
+		pool: pool,
+	}
+}
+
+// Registra as taxas de um bloco incluído na cadeia. Blocos vazios não dizem nada sobre
+// a taxa mínima aceita e são ignorados.
+func (f *FeeEstimator) AddBlock(b *core.Block) {
+	if len(b.Transactions) == 0 {
+		return
+	}
+
+	lowest := math.Inf(1)
+	for i := range b.Transactions {
+		lowest = math.Min(lowest, FeeRate(&b.Transactions[i]))
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.blocks = append(f.blocks, lowest)
+	if len(f.blocks) > f.opts.Window {
+		f.blocks = f.blocks[len(f.blocks)-f.opts.Window:]
+	}
+}
+
+// Retorna a taxa por byte sugerida: a maior entre a mediana das menores taxas dos blocos
+// recentes e a taxa da última transação do pool que ainda caberia no próximo bloco.
+func (f *FeeEstimator) EstimateFeeRate() float64 {
+	f.lock.RLock()
+	rates := append([]float64(nil), f.blocks...)
+	f.lock.RUnlock()
+
+	var estimate float64
+	if len(rates) > 0 {
+		sort.Float64s(rates)
+		if mid := len(rates) / 2; len(rates)%2 == 1 {
+			estimate = rates[mid]
+		} else {
+			estimate = (rates[mid-1] + rates[mid]) / 2
+		}
+	}
+
+	// Com o pool mais cheio que um bloco, é preciso pagar mais que a transação que ficaria no limite.
+	if txx := f.pool.Pending(); len(txx) >= f.opts.TxsPerBlock {
+		estimate = math.Max(estimate, FeeRate(txx[f.opts.TxsPerBlock-1]))
+	}
+
+	return estimate
+}
+
+// Retorna a taxa sugerida para a transação, arredondada para cima.
+func (f *FeeEstimator) EstimateFee(tx *core.Transaction) uint64 {
+	return uint64(math.Ceil(f.EstimateFeeRate() * float64(txSize(tx))))
+}
@@ -0,0 +1,48 @@
+package network
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FelipePn10/fadden/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeEstimatorEmpty(t *testing.T) {
+	f := NewFeeEstimator(NewTxPool(), FeeEstimatorOpts{})
+	assert.Equal(t, float64(0), f.EstimateFeeRate())
+}
+
+func TestFeeEstimatorRecentBlocks(t *testing.T) {
+	f := NewFeeEstimator(NewTxPool(), FeeEstimatorOpts{Window: 3})
+
+	rates := make([]float64, 0, 4)
+	for _, fee := range []uint64{1_000_000, 100, 300, 200} {
+		b := randomBlockWithTxs(t, 2)
+		b.Transactions[0].Fee = fee
+		b.Transactions[1].Fee = fee * 10
+		f.AddBlock(b)
+		rates = append(rates, FeeRate(&b.Transactions[0]))
+	}
+
+	// O primeiro bloco saiu da janela; a estimativa é a mediana dos três últimos.
+	assert.Equal(t, rates[3], f.EstimateFeeRate())
+}
+
+func TestFeeEstimatorPoolCongestion(t *testing.T) {
+	pool := NewTxPool()
+	f := NewFeeEstimator(pool, FeeEstimatorOpts{TxsPerBlock: 2})
+
+	var cutoff *core.Transaction
+	for i, fee := range []uint64{5000, 100, 3000} {
+		tx := randomTx(t, fmt.Sprintf("tx-%d", i))
+		tx.Fee = fee
+		assert.Nil(t, pool.Add(tx))
+		if fee == 3000 {
+			cutoff = tx
+		}
+	}
+
+	assert.Equal(t, FeeRate(cutoff), f.EstimateFeeRate())
+	assert.GreaterOrEqual(t, f.EstimateFee(cutoff), cutoff.Fee)
+}
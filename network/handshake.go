@@ -0,0 +1,92 @@
+package network
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Versões do protocolo de rede. Um nó fala um intervalo de versões e, na conexão,
+// os dois lados escolhem a maior versão em comum.
+const (
+	ProtocolVersion1 uint32 = 1 // Mensagens básicas (transações, blocos, requisições e gossip)
+	ProtocolVersion2 uint32 = 2 // Capacidades negociadas (blocos compactos, compressão)
+
+	MinProtocolVersion = ProtocolVersion1
+	MaxProtocolVersion = ProtocolVersion2
+)
+
+// Capability: Conjunto de funcionalidades opcionais que um peer anuncia (bitmask).
+type Capability uint32
+
+const (
+	CapCompactBlocks Capability = 1 << iota // Relay de blocos compactos (ver CompactBlock)
+	_                                       // Reservado para a sincronização headers-first, ainda não implementada
+	CapCompression                          // Compressão das mensagens
+
+	SupportedCapabilities = CapCompactBlocks | CapCompression
+)
+
+// Capacidades que podem ser usadas em cada versão do protocolo.
+var versionCapabilities = map[uint32]Capability{
+	ProtocolVersion1: 0,
+	ProtocolVersion2: CapCompactBlocks | CapCompression,
+}
+
+// Verifica se todas as capacidades de o estão presentes em c.
+func (c Capability) Has(o Capability) bool {
+	return c&o == o
+}
+
+func (c Capability) String() string {
+	var names []string
+	for _, cap := range []struct {
+		c    Capability
+		name string
+	}{
+		{CapCompactBlocks, "compact-blocks"},
+		{CapCompression, "compression"},
+	} {
+		if c.Has(cap.c) {
+			names = append(names, cap.name)
+		}
+	}
+	return "[" + strings.Join(names, ",") + "]"
+}
+
+// Handshake: Mensagem trocada na conexão com as versões e capacidades suportadas.
+// Reply indica que o handshake é a resposta a um handshake recebido e não deve ser respondido.
+type Handshake struct {
+	MinVersion   uint32
+	MaxVersion   uint32
+	Capabilities Capability
+	Reply        bool
+}
+
+// PeerInfo: Resultado da negociação com um peer.
+// Peers que nunca enviaram um handshake são tratados como nós antigos: versão mínima e nenhuma capacidade.
+type PeerInfo struct {
+	Version      uint32
+	Capabilities Capability
+	Negotiated   bool
+}
+
+// Verifica se a capacidade pode ser usada com o peer.
+func (p PeerInfo) Supports(c Capability) bool {
+	return p.Capabilities.Has(c)
+}
+
+// Escolhe a maior versão em comum entre os dois lados e as capacidades que ambos anunciaram
+// e que essa versão permite. Retorna erro se os intervalos de versão não se cruzam.
+func negotiate(local, remote *Handshake) (PeerInfo, error) {
+	version := min(local.MaxVersion, remote.MaxVersion)
+	if version < max(local.MinVersion, remote.MinVersion) {
+		return PeerInfo{}, fmt.Errorf("no common protocol version: local [%d, %d], remote [%d, %d]",
+			local.MinVersion, local.MaxVersion, remote.MinVersion, remote.MaxVersion)
+	}
+
+	return PeerInfo{
+		Version:      version,
+		Capabilities: local.Capabilities & remote.Capabilities & versionCapabilities[version],
+		Negotiated:   true,
+	}, nil
+}
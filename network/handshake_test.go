@@ -0,0 +1,118 @@
+package network
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/FelipePn10/fadden/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	local := &Handshake{MinVersion: 1, MaxVersion: 2, Capabilities: SupportedCapabilities}
+
+	info, err := negotiate(local, &Handshake{MinVersion: 1, MaxVersion: 5, Capabilities: CapCompactBlocks})
+	assert.Nil(t, err)
+	assert.Equal(t, ProtocolVersion2, info.Version)
+	assert.True(t, info.Supports(CapCompactBlocks))
+	assert.False(t, info.Supports(CapCompression))
+
+	// Na versão 1 nenhuma capacidade pode ser usada, mesmo que os dois lados a anunciem.
+	info, err = negotiate(local, &Handshake{MinVersion: 1, MaxVersion: 1, Capabilities: SupportedCapabilities})
+	assert.Nil(t, err)
+	assert.Equal(t, ProtocolVersion1, info.Version)
+	assert.Equal(t, Capability(0), info.Capabilities)
+
+	_, err = negotiate(local, &Handshake{MinVersion: 3, MaxVersion: 4})
+	assert.NotNil(t, err)
+}
+
+func TestServerHandshake(t *testing.T) {
+	tra, trb := connectedTransports(t, "A", "B")
+	sa := startServer(t, ServerOpts{Transports: []Trasport{tra}})
+	sb := startServer(t, ServerOpts{Transports: []Trasport{trb}, DisabledCapabilities: CapCompression})
+
+	// Antes do handshake, B é tratado como um nó antigo.
+	assert.Equal(t, PeerInfo{Version: ProtocolVersion1}, sa.PeerInfo(trb.Addr()))
+
+	assert.Nil(t, sa.AddPeer(trb.Addr(), tra))
+
+	want := PeerInfo{Version: ProtocolVersion2, Capabilities: CapCompactBlocks, Negotiated: true}
+	assert.Eventually(t, func() bool {
+		return sa.PeerInfo(trb.Addr()) == want && sb.PeerInfo(tra.Addr()) == want
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestServerRelaysFullBlockToLegacyPeer(t *testing.T) {
+	tra, trb := connectedTransports(t, "A", "B")
+	sa := startServer(t, ServerOpts{Transports: []Trasport{tra}})
+	sb := startServer(t, ServerOpts{Transports: []Trasport{trb}, MaxProtocolVersion: ProtocolVersion1})
+
+	assert.Nil(t, sa.AddPeer(trb.Addr(), tra))
+	assert.Eventually(t, func() bool {
+		return sa.PeerInfo(trb.Addr()).Negotiated
+	}, time.Second, 5*time.Millisecond)
+	assert.False(t, sa.PeerInfo(trb.Addr()).Supports(CapCompactBlocks))
+
+	// B não tem nenhuma transação no mempool: só consegue o bloco porque ele é enviado completo.
+	b := randomBlockWithTxs(t, 3)
+	assert.Nil(t, sa.RelayBlock(b))
+
+	assert.Eventually(t, func() bool {
+		return sb.blocks.Has(b.Hash(core.BlockHasher{}))
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestServerIgnoresIncompatiblePeer(t *testing.T) {
+	tra, trb := connectedTransports(t, "A", "B")
+	sa := startServer(t, ServerOpts{Transports: []Trasport{tra}, MinProtocolVersion: 2, MaxProtocolVersion: 2})
+	startServer(t, ServerOpts{Transports: []Trasport{trb}, MaxProtocolVersion: ProtocolVersion1})
+
+	assert.Nil(t, sa.AddPeer(trb.Addr(), tra))
+	assert.Eventually(t, func() bool {
+		return sa.PeerInfo(trb.Addr()).Negotiated
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, uint32(0), sa.PeerInfo(trb.Addr()).Version)
+
+	payload, err := encodeGob(randomBlockWithTxs(t, 1))
+	assert.Nil(t, err)
+	assert.NotNil(t, sa.processRPC(RPC{From: trb.Addr(), Payload: NewMessage(MessageTypeBlock, payload).Bytes()}))
+}
+
+func TestServerIgnoresRequestsFromIncompatiblePeer(t *testing.T) {
+	tra, trb := connectedTransports(t, "A", "B")
+	sa := startServer(t, ServerOpts{Transports: []Trasport{tra}, MinProtocolVersion: 2, MaxProtocolVersion: 2})
+	sb := startServer(t, ServerOpts{
+		Transports:         []Trasport{trb},
+		MaxProtocolVersion: ProtocolVersion1,
+		RequestOpts:        RequestOpts{Timeout: 50 * time.Millisecond},
+	})
+
+	served := make(chan struct{}, 1)
+	sa.HandleRequest("ping", func(from NetAddr, body []byte) ([]byte, error) {
+		served <- struct{}{}
+		return body, nil
+	})
+
+	assert.Nil(t, sa.AddPeer(trb.Addr(), tra))
+	assert.Eventually(t, func() bool {
+		return sa.PeerInfo(trb.Addr()).Negotiated
+	}, time.Second, 5*time.Millisecond)
+
+	_, err := sb.Request(context.Background(), []NetAddr{tra.Addr()}, "ping", []byte("hi"))
+	assert.NotNil(t, err)
+	assert.Empty(t, served)
+}
+
+func TestServerIgnoresPeerBeforeHandshake(t *testing.T) {
+	s := NewServer(ServerOpts{MinProtocolVersion: ProtocolVersion2})
+
+	// Sem handshake o peer é tratado como ProtocolVersion1, abaixo da versão mínima deste nó.
+	b := randomBlockWithTxs(t, 1)
+	payload, err := encodeGob(b)
+	assert.Nil(t, err)
+	assert.NotNil(t, s.processRPC(RPC{From: "B", Payload: NewMessage(MessageTypeBlock, payload).Bytes()}))
+	assert.False(t, s.blocks.Has(b.Hash(core.BlockHasher{})))
+}
@@ -1,25 +1,86 @@
 package network
 
 import (
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 )
 
+var defaultQueueSize = 1024
+
+var (
+	ErrQueueFull   = errors.New("peer queue is full")
+	ErrRateLimited = errors.New("message rate limited")
+)
+
+// LocalTransportOpts define os limites de envio de um LocalTransport.
+// QueueSize é a capacidade da fila de saída de cada peer (padrão 1024).
+// DropPolicy decide qual mensagem é descartada quando a fila de um peer está cheia.
+// PeerRate limita as mensagens enviadas a cada peer e TypeRates limita, por peer, cada tipo de mensagem.
+type LocalTransportOpts struct {
+	QueueSize  int
+	DropPolicy DropPolicy
+	PeerRate   Rate
+	TypeRates  map[MessageType]Rate
+}
+
 type LocalTransport struct {
-	addr      NetAddr                     // Endereço deste nó (ex: "LOCAL", "REMOTE")
-	consumuch chan RPC                    // Canal de recebimento de mensagens (buffer de 1024)
-	lock      sync.RWMutex                // Mutex para sincronização concorrente
-	peers     map[NetAddr]*LocalTransport // Mapa de peers conectados
+	opts      LocalTransportOpts
+	addr      NetAddr                // Endereço deste nó (ex: "LOCAL", "REMOTE")
+	consumuch chan RPC               // Canal de recebimento de mensagens (buffer de 1024)
+	lock      sync.RWMutex           // Mutex para sincronização concorrente
+	peers     map[NetAddr]*localPeer // Mapa de peers conectados
+	quitCh    chan struct{}
+	closeOnce sync.Once
+}
+
+// localPeer: Um peer conectado, com sua própria fila de saída e limites de taxa.
+// Uma goroutine por peer retira as mensagens da fila e as entrega no canal do destino,
+// assim um consumidor lento nunca bloqueia quem envia.
+type localPeer struct {
+	tr           *LocalTransport
+	queue        *messageQueue
+	limiter      *rateLimiter
+	typeLimiters map[MessageType]*rateLimiter
+	quitCh       chan struct{}
+
+	droppedQueueFull   atomic.Uint64
+	droppedRateLimited atomic.Uint64
+}
+
+// PeerStats: Contadores de um peer conectado.
+type PeerStats struct {
+	Queued             int    // Mensagens aguardando entrega
+	DroppedQueueFull   uint64 // Mensagens descartadas pela DropPolicy
+	DroppedRateLimited uint64 // Mensagens recusadas pelos limites de taxa
+}
+
+// TransportStats: Contadores agregados do transport e de cada peer.
+type TransportStats struct {
+	Dropped uint64
+	Peers   map[NetAddr]PeerStats
 }
 
-// Cria um novo LocalTransport com um endereço específico.
+// Cria um novo LocalTransport com um endereço específico e as opções padrão.
+func NewLocalTransport(addr NetAddr) *LocalTransport {
+	return NewLocalTransportWithOpts(addr, LocalTransportOpts{})
+}
+
+// Cria um novo LocalTransport com um endereço e opções específicos.
 // Inicializa o canal consumuch com buffer de 1024 mensagens.
 // Inicializa o mapa de peers.
-func NewLocalTransport(addr NetAddr) Trasport {
+func NewLocalTransportWithOpts(addr NetAddr, opts LocalTransportOpts) *LocalTransport {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+
 	return &LocalTransport{
+		opts:      opts,
 		addr:      addr,
-		consumuch: make(chan RPC, 1024),              // Canal bufferizado para 1024 mensagens
-		peers:     make(map[NetAddr]*LocalTransport), // Mapa de peers conectados
+		consumuch: make(chan RPC, 1024),         // Canal bufferizado para 1024 mensagens
+		peers:     make(map[NetAddr]*localPeer), // Mapa de peers conectados
+		quitCh:    make(chan struct{}),
 	}
 }
 
@@ -29,34 +90,65 @@ func (t *LocalTransport) Consume() <-chan RPC {
 
 // Conecta dois transports para permitir comunicação.
 // Usa sync.RWMutex para garantir acesso seguro ao mapa peers.
-// Adiciona o peer ao mapa usando seu endereço como chave.
+// Adiciona o peer ao mapa usando seu endereço como chave e inicia a goroutine de entrega da sua fila.
 // tr.(*LocalTransport) força que todos os peers sejam do mesmo tipo (LocalTransport).
 func (t *LocalTransport) Connect(tr Trasport) error {
+	peer := &localPeer{
+		tr:           tr.(*LocalTransport),
+		queue:        newMessageQueue(t.opts.QueueSize, t.opts.DropPolicy),
+		limiter:      newRateLimiter(t.opts.PeerRate),
+		typeLimiters: make(map[MessageType]*rateLimiter),
+		quitCh:       make(chan struct{}),
+	}
+	for msgType, rate := range t.opts.TypeRates {
+		if l := newRateLimiter(rate); l != nil {
+			peer.typeLimiters[msgType] = l
+		}
+	}
+
 	t.lock.Lock() // Bloqueia o mutex para evitar concorrência
 	defer t.lock.Unlock()
 
-	t.peers[tr.Addr()] = tr.(*LocalTransport) // Adiciona o peer ao mapa de peers usando o endereço como chave. (tr.(*LocalTransport) força que todos os peers sejam do mesmo tipo (LocalTransport))
+	if old, ok := t.peers[tr.Addr()]; ok {
+		close(old.quitCh)
+	}
+	t.peers[tr.Addr()] = peer
+
+	go peer.deliver(t.quitCh)
 
 	return nil
 }
 
-// Envia uma mensagem para um peer específico.
-// Verifica se o peer existe no mapa.
-// Se existir, envia um RPC para o canal consumuch do peer.
+// Envia uma mensagem para um peer específico sem bloquear.
+// A mensagem é recusada (ErrRateLimited) se exceder os limites de taxa do peer ou do seu tipo,
+// e é enfileirada na fila do peer caso contrário. Com a fila cheia, DropNewest retorna ErrQueueFull
+// e DropOldest descarta a mensagem mais antiga para aceitar a nova.
 // A mensagem inclui o endereço do remetente (From) e o conteúdo (Payload).
 func (t *LocalTransport) SendMessage(to NetAddr, payload []byte) error {
 	t.lock.RLock()
-	defer t.lock.RUnlock()
-
 	peer, ok := t.peers[to]
+	t.lock.RUnlock()
+
 	if !ok {
 		return fmt.Errorf("%s: could not send message to %s", t.addr, to)
 	}
 
-	peer.consumuch <- RPC{
+	if !peer.allow(payload) {
+		peer.droppedRateLimited.Add(1)
+		return ErrRateLimited
+	}
+
+	dropped := peer.queue.Push(RPC{
 		From:    t.addr,
 		Payload: payload,
+	})
+	if dropped {
+		peer.droppedQueueFull.Add(1)
+		if t.opts.DropPolicy == DropNewest {
+			return ErrQueueFull
+		}
 	}
+
 	return nil
 }
 
@@ -65,3 +157,75 @@ func (t *LocalTransport) SendMessage(to NetAddr, payload []byte) error {
 func (t *LocalTransport) Addr() NetAddr {
 	return t.addr
 }
+
+// Retorna os contadores de mensagens enfileiradas e descartadas de cada peer.
+func (t *LocalTransport) Stats() TransportStats {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	stats := TransportStats{Peers: make(map[NetAddr]PeerStats, len(t.peers))}
+	for addr, peer := range t.peers {
+		ps := PeerStats{
+			Queued:             peer.queue.Len(),
+			DroppedQueueFull:   peer.droppedQueueFull.Load(),
+			DroppedRateLimited: peer.droppedRateLimited.Load(),
+		}
+		stats.Dropped += ps.DroppedQueueFull + ps.DroppedRateLimited
+		stats.Peers[addr] = ps
+	}
+
+	return stats
+}
+
+// Encerra as goroutines de entrega de todos os peers. Mensagens ainda enfileiradas são descartadas.
+func (t *LocalTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.quitCh)
+	})
+	return nil
+}
+
+// Verifica os limites de taxa do peer e do tipo da mensagem.
+func (p *localPeer) allow(payload []byte) bool {
+	var typeLimiter *rateLimiter
+	if msgType, ok := messageTypeOf(payload); ok {
+		typeLimiter = p.typeLimiters[msgType]
+	}
+	if !typeLimiter.Allow() {
+		return false
+	}
+	// Uma mensagem recusada pelo limite do peer não gasta o limite do tipo.
+	if !p.limiter.Allow() {
+		typeLimiter.Refund()
+		return false
+	}
+	return true
+}
+
+// Entrega as mensagens da fila no canal do peer até que o transport ou o peer sejam encerrados.
+func (p *localPeer) deliver(transportQuit chan struct{}) {
+	for {
+		select {
+		case <-p.queue.notify:
+		case <-p.quitCh:
+			return
+		case <-transportQuit:
+			return
+		}
+
+		for {
+			rpc, ok := p.queue.Pop()
+			if !ok {
+				break
+			}
+
+			select {
+			case p.tr.consumuch <- rpc:
+			case <-p.quitCh:
+				return
+			case <-transportQuit:
+				return
+			}
+		}
+	}
+}
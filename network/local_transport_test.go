@@ -32,3 +32,104 @@ func TestSendMessage(t *testing.T) {
 	assert.Equal(t, rpc.Payload, msg)
 	assert.Equal(t, rpc.From, tra.Addr())
 }
+
+func TestSendMessageDoesNotBlockSlowConsumer(t *testing.T) {
+	tra := NewLocalTransportWithOpts("A", LocalTransportOpts{QueueSize: 4})
+	trb := NewLocalTransport("B")
+	defer tra.Close()
+
+	tra.Connect(trb)
+
+	// Ninguém lê de B: o canal de B (1024) e a fila de A (4) enchem e as demais mensagens são descartadas.
+	var dropped int
+	for i := 0; i < 2000; i++ {
+		if err := tra.SendMessage(trb.Addr(), []byte{byte(MessageTypeTx)}); err != nil {
+			assert.ErrorIs(t, err, ErrQueueFull)
+			dropped++
+		}
+	}
+
+	assert.Greater(t, dropped, 0)
+	assert.Equal(t, uint64(dropped), tra.Stats().Peers[trb.Addr()].DroppedQueueFull)
+}
+
+func TestSendMessageDropOldest(t *testing.T) {
+	q := newMessageQueue(2, DropOldest)
+
+	assert.False(t, q.Push(RPC{Payload: []byte("1")}))
+	assert.False(t, q.Push(RPC{Payload: []byte("2")}))
+	assert.True(t, q.Push(RPC{Payload: []byte("3")}))
+
+	rpc, ok := q.Pop()
+	assert.True(t, ok)
+	assert.Equal(t, []byte("2"), rpc.Payload)
+	rpc, _ = q.Pop()
+	assert.Equal(t, []byte("3"), rpc.Payload)
+	_, ok = q.Pop()
+	assert.False(t, ok)
+}
+
+func TestSendMessageRateLimits(t *testing.T) {
+	tra := NewLocalTransportWithOpts("A", LocalTransportOpts{
+		PeerRate:  Rate{PerSecond: 0.001, Burst: 5},
+		TypeRates: map[MessageType]Rate{MessageTypeBlock: {PerSecond: 0.001, Burst: 1}},
+	})
+	trb := NewLocalTransport("B")
+	defer tra.Close()
+
+	tra.Connect(trb)
+
+	block := NewMessage(MessageTypeBlock, nil).Bytes()
+	tx := NewMessage(MessageTypeTx, nil).Bytes()
+
+	assert.Nil(t, tra.SendMessage(trb.Addr(), block))
+	assert.ErrorIs(t, tra.SendMessage(trb.Addr(), block), ErrRateLimited)
+
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, tra.SendMessage(trb.Addr(), tx))
+	}
+	assert.ErrorIs(t, tra.SendMessage(trb.Addr(), tx), ErrRateLimited)
+
+	stats := tra.Stats()
+	assert.Equal(t, uint64(2), stats.Peers[trb.Addr()].DroppedRateLimited)
+	assert.Equal(t, uint64(2), stats.Dropped)
+}
+
+func TestSendMessagePeerLimitKeepsTypeBudget(t *testing.T) {
+	tra := NewLocalTransportWithOpts("A", LocalTransportOpts{
+		PeerRate:  Rate{PerSecond: 0.001, Burst: 1},
+		TypeRates: map[MessageType]Rate{MessageTypeBlock: {PerSecond: 0.001, Burst: 1}},
+	})
+	trb := NewLocalTransport("B")
+	defer tra.Close()
+
+	tra.Connect(trb)
+
+	// O limite do peer recusa o bloco: o token do tipo volta para o bucket.
+	assert.Nil(t, tra.SendMessage(trb.Addr(), NewMessage(MessageTypeTx, nil).Bytes()))
+	assert.ErrorIs(t, tra.SendMessage(trb.Addr(), NewMessage(MessageTypeBlock, nil).Bytes()), ErrRateLimited)
+
+	tra.lock.RLock()
+	peer := tra.peers[trb.Addr()]
+	tra.lock.RUnlock()
+	assert.Equal(t, float64(1), peer.typeLimiters[MessageTypeBlock].tokens)
+}
+
+func TestSendMessageBetweenPeersDoesNotDeadlock(t *testing.T) {
+	tra := NewLocalTransportWithOpts("A", LocalTransportOpts{QueueSize: 8, DropPolicy: DropOldest})
+	trb := NewLocalTransportWithOpts("B", LocalTransportOpts{QueueSize: 8, DropPolicy: DropOldest})
+	defer tra.Close()
+	defer trb.Close()
+
+	tra.Connect(trb)
+	trb.Connect(tra)
+
+	// Os dois nós enviam muito mais do que cabe nos buffers sem que ninguém consuma.
+	for i := 0; i < 5000; i++ {
+		assert.Nil(t, tra.SendMessage(trb.Addr(), []byte("ping")))
+		assert.Nil(t, trb.SendMessage(tra.Addr(), []byte("pong")))
+	}
+
+	rpc := <-trb.Consume()
+	assert.Equal(t, tra.Addr(), rpc.From)
+}
@@ -0,0 +1,131 @@
+package network
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// MessageType identifica o tipo de conteúdo carregado em uma mensagem.
+// É o primeiro byte de todo payload enviado pela rede, o que permite aos transports
+// classificar (ex: para limites de taxa) uma mensagem sem decodificá-la por completo.
+type MessageType byte
+
+const (
+	MessageTypeTx           MessageType = 0x1 // Transação
+	MessageTypeBlock        MessageType = 0x2 // Bloco
+	MessageTypeRequest      MessageType = 0x3 // Requisição com ID de correlação (ver RequestManager)
+	MessageTypeResponse     MessageType = 0x4 // Resposta a uma requisição
+	MessageTypeGossip       MessageType = 0x5 // Quadro do pub/sub (ver PubSub)
+	MessageTypeCompactBlock MessageType = 0x6 // Bloco compacto (ver CompactBlock)
+	MessageTypeHandshake    MessageType = 0x7 // Negociação de versão e capacidades (ver Handshake)
+)
+
+// Bit mais alto do byte de tipo: indica que os dados da mensagem estão comprimidos com DEFLATE.
+const compressedFlag byte = 0x80
+
+var (
+	// Mensagens com dados menores que isso não são comprimidas: o ganho não compensa o custo.
+	DefaultCompressionThreshold = 512
+	// Tamanho máximo dos dados de uma mensagem depois de descomprimidos. Protege contra
+	// "bombas de descompressão", mensagens pequenas que se expandem para gigabytes.
+	MaxDecompressedSize = 32 << 20
+)
+
+// Message: Envelope que trafega entre os nós.
+// Header indica o tipo da mensagem e Data contém o conteúdo já serializado.
+type Message struct {
+	Header MessageType
+	Data   []byte
+}
+
+// Cria uma nova mensagem com o tipo e os dados informados.
+func NewMessage(t MessageType, data []byte) *Message {
+	return &Message{
+		Header: t,
+		Data:   data,
+	}
+}
+
+// Serializa a mensagem no formato [tipo][dados].
+func (m *Message) Bytes() []byte {
+	b := make([]byte, 1+len(m.Data))
+	b[0] = byte(m.Header)
+	copy(b[1:], m.Data)
+	return b
+}
+
+// Decodifica uma mensagem serializada por Message.Bytes ou comprimida por compressPayload.
+func DecodeMessage(b []byte) (*Message, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty message")
+	}
+
+	msg := &Message{
+		Header: MessageType(b[0] &^ compressedFlag),
+		Data:   b[1:],
+	}
+	if b[0]&compressedFlag == 0 {
+		return msg, nil
+	}
+
+	data, err := decompress(b[1:])
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress message of type (%d): %w", msg.Header, err)
+	}
+	msg.Data = data
+
+	return msg, nil
+}
+
+// Retorna o tipo de um payload sem decodificá-lo. O segundo valor é false para payloads vazios.
+func messageTypeOf(payload []byte) (MessageType, bool) {
+	if len(payload) == 0 {
+		return 0, false
+	}
+	return MessageType(payload[0] &^ compressedFlag), true
+}
+
+// Comprime os dados de um payload serializado por Message.Bytes.
+// Payloads com dados menores que threshold, já comprimidos, ou que não diminuiriam, são retornados sem alteração.
+func compressPayload(payload []byte, threshold int) []byte {
+	if len(payload) == 0 || payload[0]&compressedFlag != 0 || len(payload)-1 < threshold {
+		return payload
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte(payload[0] | compressedFlag)
+
+	w, err := flate.NewWriter(buf, flate.DefaultCompression)
+	if err != nil {
+		return payload
+	}
+	if _, err := w.Write(payload[1:]); err != nil {
+		return payload
+	}
+	if err := w.Close(); err != nil {
+		return payload
+	}
+
+	if buf.Len() >= len(payload) {
+		return payload
+	}
+	return buf.Bytes()
+}
+
+// Descomprime dados DEFLATE, falhando se o resultado ultrapassar MaxDecompressedSize.
+func decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+
+	out, err := io.ReadAll(io.LimitReader(r, int64(MaxDecompressedSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > MaxDecompressedSize {
+		return nil, fmt.Errorf("decompressed message exceeds %d bytes", MaxDecompressedSize)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,88 @@
+package network
+
+import (
+	"bytes"
+	"compress/flate"
+	"testing"
+
+	"github.com/FelipePn10/fadden/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageEncodeDecode(t *testing.T) {
+	msg := NewMessage(MessageTypeTx, []byte("foo"))
+
+	decoded, err := DecodeMessage(msg.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, msg, decoded)
+
+	_, err = DecodeMessage(nil)
+	assert.NotNil(t, err)
+}
+
+func TestCompressPayload(t *testing.T) {
+	data := bytes.Repeat([]byte("transaction data "), 100)
+	payload := NewMessage(MessageTypeBlock, data).Bytes()
+
+	compressed := compressPayload(payload, 512)
+	assert.Less(t, len(compressed), len(payload))
+
+	msgType, ok := messageTypeOf(compressed)
+	assert.True(t, ok)
+	assert.Equal(t, MessageTypeBlock, msgType)
+
+	msg, err := DecodeMessage(compressed)
+	assert.Nil(t, err)
+	assert.Equal(t, MessageTypeBlock, msg.Header)
+	assert.Equal(t, data, msg.Data)
+
+	// Comprimir duas vezes não altera o payload.
+	assert.Equal(t, compressed, compressPayload(compressed, 512))
+}
+
+func TestCompressPayloadSkipsSmallMessages(t *testing.T) {
+	payload := NewMessage(MessageTypeTx, bytes.Repeat([]byte("a"), 100)).Bytes()
+	assert.Equal(t, payload, compressPayload(payload, 512))
+
+	// Dados aleatórios não diminuem ao serem comprimidos e seguem sem compressão.
+	random := NewMessage(MessageTypeTx, types.RandomBytes(2048)).Bytes()
+	assert.Equal(t, random, compressPayload(random, 512))
+}
+
+func TestDecompressionBomb(t *testing.T) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(byte(MessageTypeBlock) | compressedFlag)
+	w, _ := flate.NewWriter(buf, flate.BestCompression)
+	w.Write(make([]byte, MaxDecompressedSize+1))
+	w.Close()
+
+	// Poucos KB que se expandem para mais do que o limite.
+	assert.Less(t, buf.Len(), 64<<10)
+
+	_, err := DecodeMessage(buf.Bytes())
+	assert.NotNil(t, err)
+
+	_, err = DecodeMessage([]byte{byte(MessageTypeTx) | compressedFlag, 0xff, 0xff})
+	assert.NotNil(t, err)
+}
+
+func TestServerCompressesForNegotiatedPeers(t *testing.T) {
+	tra, trb := connectedTransports(t, "A", "B")
+	s := NewServer(ServerOpts{Transports: []Trasport{tra}})
+
+	payload := NewMessage(MessageTypeBlock, bytes.Repeat([]byte("tx "), 1000)).Bytes()
+
+	assert.Nil(t, s.SendMessage(trb.Addr(), payload))
+	rpc := <-trb.Consume()
+	assert.Equal(t, payload, rpc.Payload)
+
+	s.peerInfo[trb.Addr()] = PeerInfo{Version: ProtocolVersion2, Capabilities: CapCompression, Negotiated: true}
+
+	assert.Nil(t, s.SendMessage(trb.Addr(), payload))
+	rpc = <-trb.Consume()
+	assert.Less(t, len(rpc.Payload), len(payload))
+
+	msg, err := DecodeMessage(rpc.Payload)
+	assert.Nil(t, err)
+	assert.Equal(t, payload, msg.Bytes())
+}
@@ -0,0 +1,540 @@
+package network
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/FelipePn10/fadden/types"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	defaultMeshDegree = 6
+	defaultSeenTTL    = 2 * time.Minute
+	defaultMaxSeen    = 1 << 16
+	subscriptionBuf   = 128
+)
+
+// gossipKind identifica o tipo de um quadro do protocolo de gossip.
+type gossipKind byte
+
+const (
+	gossipSubscribe   gossipKind = iota + 1 // O peer passou a assinar os tópicos
+	gossipUnsubscribe                       // O peer deixou de assinar os tópicos
+	gossipGraft                             // O peer nos adicionou à sua malha do tópico
+	gossipPrune                             // O peer nos removeu da sua malha do tópico
+	gossipPublish                           // Mensagem publicada em um tópico
+)
+
+// gossipFrame: Conteúdo de uma mensagem MessageTypeGossip.
+type gossipFrame struct {
+	Kind    gossipKind
+	Topics  []string
+	Message *PubSubMessage
+}
+
+// PubSubMessage: Mensagem publicada em um tópico.
+// Origin é o nó que publicou e Seq um contador local dele, o que torna o ID único mesmo com dados repetidos.
+// From é o peer que nos entregou a mensagem e é sempre preenchido na recepção.
+type PubSubMessage struct {
+	Topic  string
+	Origin NetAddr
+	Seq    uint64
+	Data   []byte
+	From   NetAddr
+}
+
+// Calcula o ID da mensagem, usado para descartar duplicatas.
+// O ID é sempre recalculado a partir do conteúdo, nunca recebido de um peer.
+func (m *PubSubMessage) ID() types.Hash {
+	h := sha256.New()
+	h.Write([]byte(m.Origin))
+	h.Write([]byte{0})
+	binary.Write(h, binary.BigEndian, m.Seq)
+	h.Write([]byte(m.Topic))
+	h.Write([]byte{0})
+	h.Write(m.Data)
+	return types.HashFromBytes(h.Sum(nil))
+}
+
+// TopicValidator decide se uma mensagem recebida é aceita. Mensagens rejeitadas não são entregues
+// aos assinantes locais nem repassadas aos outros peers.
+type TopicValidator func(from NetAddr, msg *PubSubMessage) error
+
+// PubSubOpts define os parâmetros da malha de gossip.
+// D é o grau alvo da malha de cada tópico; a malha é refeita quando sai do intervalo [DLow, DHigh].
+// SeenTTL é por quanto tempo um ID de mensagem é lembrado para descartar duplicatas, e MaxSeen
+// quantos IDs são lembrados no máximo (padrão 65536); com o cache cheio, os mais antigos são esquecidos.
+type PubSubOpts struct {
+	D       int
+	DLow    int
+	DHigh   int
+	SeenTTL time.Duration
+	MaxSeen int
+}
+
+// Subscription: Assinatura local de um tópico.
+type Subscription struct {
+	topic string
+	ch    chan *PubSubMessage
+	ps    *PubSub
+	once  sync.Once
+}
+
+// Retorna o canal com as mensagens recebidas no tópico.
+func (s *Subscription) Messages() <-chan *PubSubMessage {
+	return s.ch
+}
+
+// Cancela a assinatura. Quando a última assinatura de um tópico é cancelada, os peers são avisados.
+func (s *Subscription) Cancel() {
+	s.once.Do(func() {
+		s.ps.unsubscribe(s)
+	})
+}
+
+type outgoing struct {
+	to    NetAddr
+	frame *gossipFrame
+}
+
+// PubSub: Camada de publish/subscribe com gossip sobre um Sender.
+// Cada nó anuncia aos peers os tópicos que assina e mantém, por tópico, uma malha de até D peers
+// que também o assinam. Mensagens publicadas são repassadas apenas pela malha, e um cache de IDs já
+// vistos impede que a mesma mensagem seja processada (e repassada) duas vezes.
+// As mensagens MessageTypeGossip recebidas devem ser entregues em ProcessMessage e Heartbeat deve
+// ser chamado periodicamente para manter a malha.
+type PubSub struct {
+	self   NetAddr
+	sender Sender
+	opts   PubSubOpts
+
+	lock       sync.Mutex
+	seq        uint64
+	peers      map[NetAddr]map[string]struct{} // Tópicos assinados por cada peer
+	mesh       map[string]map[NetAddr]struct{} // Malha de cada tópico assinado localmente
+	subs       map[string][]*Subscription
+	validators map[string]TopicValidator
+	seen       map[types.Hash]time.Time
+	seenOrder  []types.Hash // IDs de seen em ordem de inserção, para expirar e limitar o cache
+}
+
+// Cria uma nova camada de pub/sub para o nó self, que envia as mensagens pelo Sender informado.
+func NewPubSub(self NetAddr, sender Sender, opts PubSubOpts) *PubSub {
+	if opts.D <= 0 {
+		opts.D = defaultMeshDegree
+	}
+	if opts.DLow <= 0 || opts.DLow > opts.D {
+		opts.DLow = max(1, opts.D*2/3)
+	}
+	if opts.DHigh < opts.D {
+		opts.DHigh = opts.D * 2
+	}
+	if opts.SeenTTL <= 0 {
+		opts.SeenTTL = defaultSeenTTL
+	}
+	if opts.MaxSeen <= 0 {
+		opts.MaxSeen = defaultMaxSeen
+	}
+
+	return &PubSub{
+		self:       self,
+		sender:     sender,
+		opts:       opts,
+		peers:      make(map[NetAddr]map[string]struct{}),
+		mesh:       make(map[string]map[NetAddr]struct{}),
+		subs:       make(map[string][]*Subscription),
+		validators: make(map[string]TopicValidator),
+		seen:       make(map[types.Hash]time.Time),
+	}
+}
+
+// Registra um peer conectado e anuncia a ele os tópicos assinados localmente.
+func (ps *PubSub) AddPeer(addr NetAddr) {
+	ps.lock.Lock()
+	if _, ok := ps.peers[addr]; ok {
+		ps.lock.Unlock()
+		return
+	}
+	ps.peers[addr] = make(map[string]struct{})
+
+	var out []outgoing
+	if topics := ps.localTopics(); len(topics) > 0 {
+		out = append(out, outgoing{addr, &gossipFrame{Kind: gossipSubscribe, Topics: topics}})
+	}
+	ps.lock.Unlock()
+
+	ps.send(out)
+}
+
+// Remove um peer desconectado de todas as malhas.
+func (ps *PubSub) RemovePeer(addr NetAddr) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	delete(ps.peers, addr)
+	for _, peers := range ps.mesh {
+		delete(peers, addr)
+	}
+}
+
+// Registra o validador das mensagens de um tópico. Um validador nil remove o anterior.
+func (ps *PubSub) RegisterValidator(topic string, v TopicValidator) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if v == nil {
+		delete(ps.validators, topic)
+		return
+	}
+	ps.validators[topic] = v
+}
+
+// Assina um tópico. Na primeira assinatura do tópico, os peers são avisados e a malha é montada
+// com até D peers que já o assinam.
+func (ps *PubSub) Subscribe(topic string) (*Subscription, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("empty topic")
+	}
+
+	sub := &Subscription{
+		topic: topic,
+		ch:    make(chan *PubSubMessage, subscriptionBuf),
+		ps:    ps,
+	}
+
+	ps.lock.Lock()
+	first := len(ps.subs[topic]) == 0
+	ps.subs[topic] = append(ps.subs[topic], sub)
+
+	var out []outgoing
+	if first {
+		for addr := range ps.peers {
+			out = append(out, outgoing{addr, &gossipFrame{Kind: gossipSubscribe, Topics: []string{topic}}})
+		}
+		ps.mesh[topic] = make(map[NetAddr]struct{})
+		out = append(out, ps.graft(topic, ps.opts.D)...)
+	}
+	ps.lock.Unlock()
+
+	ps.send(out)
+	return sub, nil
+}
+
+func (ps *PubSub) unsubscribe(sub *Subscription) {
+	ps.lock.Lock()
+
+	subs := ps.subs[sub.topic]
+	for i, s := range subs {
+		if s == sub {
+			subs = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+
+	var out []outgoing
+	if len(subs) > 0 {
+		ps.subs[sub.topic] = subs
+	} else {
+		delete(ps.subs, sub.topic)
+		for addr := range ps.mesh[sub.topic] {
+			out = append(out, outgoing{addr, &gossipFrame{Kind: gossipPrune, Topics: []string{sub.topic}}})
+		}
+		delete(ps.mesh, sub.topic)
+		for addr := range ps.peers {
+			out = append(out, outgoing{addr, &gossipFrame{Kind: gossipUnsubscribe, Topics: []string{sub.topic}}})
+		}
+	}
+	ps.lock.Unlock()
+
+	close(sub.ch)
+	ps.send(out)
+}
+
+// Publica dados em um tópico. Se o tópico é assinado localmente a mensagem vai para a malha e
+// também para os assinantes locais; caso contrário vai para até D peers que assinam o tópico.
+func (ps *PubSub) Publish(topic string, data []byte) error {
+	ps.lock.Lock()
+	ps.seq++
+	msg := &PubSubMessage{
+		Topic:  topic,
+		Origin: ps.self,
+		Seq:    ps.seq,
+		Data:   data,
+		From:   ps.self,
+	}
+	ps.markSeen(msg.ID())
+
+	targets := ps.mesh[topic]
+	if _, subscribed := ps.mesh[topic]; !subscribed {
+		targets = make(map[NetAddr]struct{})
+		for _, addr := range ps.topicPeers(topic, nil, ps.opts.D) {
+			targets[addr] = struct{}{}
+		}
+	}
+
+	out := make([]outgoing, 0, len(targets))
+	for addr := range targets {
+		out = append(out, outgoing{addr, &gossipFrame{Kind: gossipPublish, Message: msg}})
+	}
+	ps.deliver(msg)
+	local := len(ps.subs[topic])
+	ps.lock.Unlock()
+
+	if len(out) == 0 && local == 0 {
+		return fmt.Errorf("no peers subscribed to topic %q", topic)
+	}
+
+	ps.send(out)
+	return nil
+}
+
+// Processa uma mensagem MessageTypeGossip recebida de um peer.
+func (ps *PubSub) ProcessMessage(from NetAddr, msg *Message) error {
+	if msg.Header != MessageTypeGossip {
+		return fmt.Errorf("invalid message type (%d) for pubsub", msg.Header)
+	}
+
+	frame := new(gossipFrame)
+	if err := decodeGob(msg.Data, frame); err != nil {
+		return err
+	}
+
+	ps.lock.Lock()
+	if _, ok := ps.peers[from]; !ok {
+		ps.peers[from] = make(map[string]struct{})
+	}
+
+	var (
+		out []outgoing
+		err error
+	)
+	switch frame.Kind {
+	case gossipSubscribe:
+		for _, topic := range frame.Topics {
+			ps.peers[from][topic] = struct{}{}
+		}
+	case gossipUnsubscribe:
+		for _, topic := range frame.Topics {
+			delete(ps.peers[from], topic)
+			delete(ps.mesh[topic], from)
+		}
+	case gossipGraft:
+		for _, topic := range frame.Topics {
+			// Um GRAFT implica que o peer assina o tópico.
+			ps.peers[from][topic] = struct{}{}
+			if mesh, ok := ps.mesh[topic]; ok {
+				mesh[from] = struct{}{}
+			} else {
+				out = append(out, outgoing{from, &gossipFrame{Kind: gossipPrune, Topics: []string{topic}}})
+			}
+		}
+	case gossipPrune:
+		for _, topic := range frame.Topics {
+			delete(ps.mesh[topic], from)
+		}
+	case gossipPublish:
+		// Tratado depois de liberar ps.lock (ver handlePublish).
+	default:
+		err = fmt.Errorf("unknown gossip frame kind (%d)", frame.Kind)
+	}
+	ps.lock.Unlock()
+
+	ps.send(out)
+	if frame.Kind == gossipPublish {
+		return ps.handlePublish(from, frame.Message)
+	}
+	return err
+}
+
+// Trata uma mensagem publicada: descarta duplicatas, valida, entrega localmente e repassa pela malha.
+// Deve ser chamado sem ps.lock: o validador do tópico roda fora da trava, pois pode ser lento
+// ou voltar a chamar o PubSub (ex: Publish, MeshPeers).
+func (ps *PubSub) handlePublish(from NetAddr, msg *PubSubMessage) error {
+	if msg == nil {
+		return fmt.Errorf("publish frame without message")
+	}
+	msg.From = from
+
+	id := msg.ID()
+	ps.lock.Lock()
+	_, seen := ps.seen[id]
+	v := ps.validators[msg.Topic]
+	ps.lock.Unlock()
+	if seen {
+		return nil
+	}
+
+	// A mensagem só é marcada como vista depois de validada: o ID não inclui quem a repassou,
+	// então uma cópia rejeitada (ex: alterada por um peer ruim) não impede que a mesma mensagem
+	// chegue depois por um peer honesto.
+	if v != nil {
+		if err := v(from, msg); err != nil {
+			return fmt.Errorf("message (%s) rejected on topic %q: %w", id, msg.Topic, err)
+		}
+	}
+
+	ps.lock.Lock()
+	if _, ok := ps.seen[id]; ok { // Outra cópia foi validada enquanto esta era
+		ps.lock.Unlock()
+		return nil
+	}
+	ps.markSeen(id)
+	ps.deliver(msg)
+
+	var out []outgoing
+	for addr := range ps.mesh[msg.Topic] {
+		if addr == from || addr == msg.Origin {
+			continue
+		}
+		out = append(out, outgoing{addr, &gossipFrame{Kind: gossipPublish, Message: msg}})
+	}
+	ps.lock.Unlock()
+
+	ps.send(out)
+	return nil
+}
+
+// Mantém as malhas: remove peers que deixaram de assinar o tópico, completa malhas abaixo de DLow
+// e reduz malhas acima de DHigh, sempre de volta ao grau D. Também expira o cache de IDs vistos.
+func (ps *PubSub) Heartbeat() {
+	ps.lock.Lock()
+
+	var out []outgoing
+	for topic, mesh := range ps.mesh {
+		for addr := range mesh {
+			if _, ok := ps.peers[addr][topic]; !ok {
+				delete(mesh, addr)
+			}
+		}
+
+		if len(mesh) < ps.opts.DLow {
+			out = append(out, ps.graft(topic, ps.opts.D-len(mesh))...)
+		}
+
+		if len(mesh) > ps.opts.DHigh {
+			peers := make([]NetAddr, 0, len(mesh))
+			for addr := range mesh {
+				peers = append(peers, addr)
+			}
+			rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+
+			for _, addr := range peers[ps.opts.D:] {
+				delete(mesh, addr)
+				out = append(out, outgoing{addr, &gossipFrame{Kind: gossipPrune, Topics: []string{topic}}})
+			}
+		}
+	}
+
+	now := time.Now()
+	for len(ps.seenOrder) > 0 && now.Sub(ps.seen[ps.seenOrder[0]]) > ps.opts.SeenTTL {
+		ps.forgetOldestSeen()
+	}
+	ps.lock.Unlock()
+
+	ps.send(out)
+}
+
+// Retorna os peers da malha de um tópico.
+func (ps *PubSub) MeshPeers(topic string) []NetAddr {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	peers := make([]NetAddr, 0, len(ps.mesh[topic]))
+	for addr := range ps.mesh[topic] {
+		peers = append(peers, addr)
+	}
+	return peers
+}
+
+// Adiciona até n peers que assinam o tópico à sua malha. Deve ser chamado com ps.lock travado.
+func (ps *PubSub) graft(topic string, n int) []outgoing {
+	mesh := ps.mesh[topic]
+
+	var out []outgoing
+	for _, addr := range ps.topicPeers(topic, mesh, n) {
+		mesh[addr] = struct{}{}
+		out = append(out, outgoing{addr, &gossipFrame{Kind: gossipGraft, Topics: []string{topic}}})
+	}
+	return out
+}
+
+// Escolhe aleatoriamente até n peers que assinam o tópico, ignorando os que estão em exclude.
+func (ps *PubSub) topicPeers(topic string, exclude map[NetAddr]struct{}, n int) []NetAddr {
+	var peers []NetAddr
+	for addr, topics := range ps.peers {
+		if _, ok := exclude[addr]; ok {
+			continue
+		}
+		if _, ok := topics[topic]; ok {
+			peers = append(peers, addr)
+		}
+	}
+
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if len(peers) > n {
+		peers = peers[:max(n, 0)]
+	}
+	return peers
+}
+
+// Retorna os tópicos assinados localmente. Deve ser chamado com ps.lock travado.
+func (ps *PubSub) localTopics() []string {
+	topics := make([]string, 0, len(ps.subs))
+	for topic := range ps.subs {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// Entrega a mensagem aos assinantes locais. Um assinante lento perde a mensagem em vez de
+// bloquear o gossip. Deve ser chamado com ps.lock travado.
+func (ps *PubSub) deliver(msg *PubSubMessage) {
+	for _, sub := range ps.subs[msg.Topic] {
+		select {
+		case sub.ch <- msg:
+		default:
+			logrus.WithFields(logrus.Fields{
+				"topic": msg.Topic,
+			}).Warn("subscription is full, dropping message")
+		}
+	}
+}
+
+// Marca um ID como visto, esquecendo o mais antigo se o cache estiver cheio. Deve ser chamado com ps.lock travado.
+func (ps *PubSub) markSeen(id types.Hash) {
+	if _, ok := ps.seen[id]; ok {
+		return
+	}
+	for len(ps.seenOrder) >= ps.opts.MaxSeen {
+		ps.forgetOldestSeen()
+	}
+	ps.seen[id] = time.Now()
+	ps.seenOrder = append(ps.seenOrder, id)
+}
+
+// Deve ser chamado com ps.lock travado.
+func (ps *PubSub) forgetOldestSeen() {
+	delete(ps.seen, ps.seenOrder[0])
+	ps.seenOrder[0] = types.Hash{}
+	ps.seenOrder = ps.seenOrder[1:]
+}
+
+// Envia os quadros pendentes. É chamado sem ps.lock, pois o Sender pode voltar a chamar o PubSub.
+func (ps *PubSub) send(out []outgoing) {
+	for _, o := range out {
+		data, err := encodeGob(o.frame)
+		if err == nil {
+			err = ps.sender.SendMessage(o.to, NewMessage(MessageTypeGossip, data).Bytes())
+		}
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"peer": o.to,
+			}).Debug("could not send gossip: ", err)
+		}
+	}
+}
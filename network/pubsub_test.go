@@ -0,0 +1,262 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPubSubPublishReachesSubscribers(t *testing.T) {
+	nodes := newPubSubNetwork(t, 5, PubSubOpts{D: 2}, fullMesh)
+	subs := subscribeAll(t, nodes, "blocks")
+
+	assert.Nil(t, nodes[0].Publish("blocks", []byte("block 1")))
+
+	for i, sub := range subs {
+		msg := receive(t, sub)
+		assert.Equal(t, []byte("block 1"), msg.Data, "node %d", i)
+		assert.Equal(t, nodes[0].self, msg.Origin)
+	}
+
+	// Cada nó entrega a mensagem uma única vez, mesmo recebendo-a por vários caminhos.
+	time.Sleep(50 * time.Millisecond)
+	for _, sub := range subs {
+		assert.Len(t, sub.Messages(), 0)
+	}
+}
+
+func TestPubSubTopicsAreIsolated(t *testing.T) {
+	nodes := newPubSubNetwork(t, 3, PubSubOpts{}, fullMesh)
+	txs := subscribeAll(t, nodes, "txs")
+	votes, err := nodes[2].Subscribe("votes")
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		nodes[0].lock.Lock()
+		defer nodes[0].lock.Unlock()
+		return len(nodes[0].topicPeers("votes", nil, 10)) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Nil(t, nodes[0].Publish("votes", []byte("vote")))
+	assert.Equal(t, []byte("vote"), receive(t, votes).Data)
+
+	time.Sleep(50 * time.Millisecond)
+	for _, sub := range txs {
+		assert.Len(t, sub.Messages(), 0)
+	}
+}
+
+func TestPubSubValidatorRejectsBeforeForwarding(t *testing.T) {
+	// 0 - 1 - 2: tudo o que chega em 2 passa por 1.
+	nodes := newPubSubNetwork(t, 3, PubSubOpts{}, line)
+	subs := subscribeAll(t, nodes, "txs")
+
+	nodes[1].RegisterValidator("txs", func(from NetAddr, msg *PubSubMessage) error {
+		if string(msg.Data) == "spam" {
+			return errors.New("spam")
+		}
+		return nil
+	})
+
+	assert.Nil(t, nodes[0].Publish("txs", []byte("spam")))
+	assert.Nil(t, nodes[0].Publish("txs", []byte("tx")))
+
+	assert.Equal(t, []byte("tx"), receive(t, subs[1]).Data)
+	assert.Equal(t, []byte("tx"), receive(t, subs[2]).Data)
+}
+
+func TestPubSubValidatorCanCallPubSub(t *testing.T) {
+	nodes := newPubSubNetwork(t, 2, PubSubOpts{}, fullMesh)
+	subs := subscribeAll(t, nodes, "txs")
+
+	// O validador roda fora da trava do PubSub, então pode consultá-lo e publicar.
+	nodes[1].RegisterValidator("txs", func(from NetAddr, msg *PubSubMessage) error {
+		if len(nodes[1].MeshPeers("txs")) == 0 {
+			return errors.New("empty mesh")
+		}
+		if string(msg.Data) == "ping" {
+			return nodes[1].Publish("txs", []byte("pong"))
+		}
+		return nil
+	})
+
+	assert.Nil(t, nodes[0].Publish("txs", []byte("ping")))
+
+	assert.Equal(t, []byte("pong"), receive(t, subs[1]).Data)
+	assert.Equal(t, []byte("ping"), receive(t, subs[1]).Data)
+	assert.Equal(t, []byte("ping"), receive(t, subs[0]).Data)
+	assert.Equal(t, []byte("pong"), receive(t, subs[0]).Data)
+}
+
+func TestPubSubDedupe(t *testing.T) {
+	ps := NewPubSub("A", NewLocalTransport("A"), PubSubOpts{})
+	sub, err := ps.Subscribe("txs")
+	assert.Nil(t, err)
+
+	frame, err := encodeGob(&gossipFrame{
+		Kind:    gossipPublish,
+		Message: &PubSubMessage{Topic: "txs", Origin: "B", Seq: 1, Data: []byte("tx")},
+	})
+	assert.Nil(t, err)
+
+	msg := NewMessage(MessageTypeGossip, frame)
+	assert.Nil(t, ps.ProcessMessage("B", msg))
+	assert.Nil(t, ps.ProcessMessage("C", msg))
+
+	assert.Len(t, sub.Messages(), 1)
+}
+
+func TestPubSubRejectedCopyDoesNotBlockMessage(t *testing.T) {
+	ps := NewPubSub("A", NewLocalTransport("A"), PubSubOpts{})
+	sub, err := ps.Subscribe("txs")
+	assert.Nil(t, err)
+	ps.RegisterValidator("txs", func(from NetAddr, msg *PubSubMessage) error {
+		if from == "bad" {
+			return errors.New("bad relayer")
+		}
+		return nil
+	})
+
+	msg := gossipPublishMessage(t, &PubSubMessage{Topic: "txs", Origin: "B", Seq: 1, Data: []byte("tx")})
+	assert.NotNil(t, ps.ProcessMessage("bad", msg))
+	assert.Len(t, sub.Messages(), 0)
+
+	assert.Nil(t, ps.ProcessMessage("B", msg))
+	assert.Len(t, sub.Messages(), 1)
+}
+
+func TestPubSubSeenIsBounded(t *testing.T) {
+	ps := NewPubSub("A", NewLocalTransport("A"), PubSubOpts{MaxSeen: 2})
+	for seq := uint64(1); seq <= 5; seq++ {
+		msg := gossipPublishMessage(t, &PubSubMessage{Topic: "txs", Origin: "B", Seq: seq})
+		assert.Nil(t, ps.ProcessMessage("B", msg))
+	}
+
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	assert.Len(t, ps.seen, 2)
+	assert.Len(t, ps.seenOrder, 2)
+}
+
+func gossipPublishMessage(t *testing.T, m *PubSubMessage) *Message {
+	frame, err := encodeGob(&gossipFrame{Kind: gossipPublish, Message: m})
+	assert.Nil(t, err)
+	return NewMessage(MessageTypeGossip, frame)
+}
+
+func TestPubSubMeshDegree(t *testing.T) {
+	opts := PubSubOpts{D: 3, DLow: 2, DHigh: 4}
+	nodes := newPubSubNetwork(t, 10, opts, fullMesh)
+	subscribeAll(t, nodes, "blocks")
+
+	for _, n := range nodes {
+		n.Heartbeat()
+	}
+
+	assert.Eventually(t, func() bool {
+		for _, n := range nodes {
+			n.Heartbeat()
+		}
+		for _, n := range nodes {
+			size := len(n.MeshPeers("blocks"))
+			if size < opts.DLow || size > opts.DHigh {
+				return false
+			}
+		}
+		return true
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestPubSubUnsubscribe(t *testing.T) {
+	nodes := newPubSubNetwork(t, 2, PubSubOpts{}, fullMesh)
+	subs := subscribeAll(t, nodes, "txs")
+
+	subs[1].Cancel()
+	assert.Eventually(t, func() bool {
+		return len(nodes[0].MeshPeers("txs")) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	_, ok := <-subs[1].Messages()
+	assert.False(t, ok)
+}
+
+type topology func(i, j int) bool
+
+func fullMesh(i, j int) bool { return i != j }
+func line(i, j int) bool     { return j == i+1 || i == j+1 }
+
+// Cria n nós de pub/sub ligados por LocalTransports segundo a topologia informada.
+func newPubSubNetwork(t *testing.T, n int, opts PubSubOpts, connected topology) []*PubSub {
+	trs := make([]*LocalTransport, n)
+	nodes := make([]*PubSub, n)
+	for i := range trs {
+		trs[i] = NewLocalTransport(NetAddr(fmt.Sprintf("node-%d", i)))
+		nodes[i] = NewPubSub(trs[i].Addr(), trs[i], opts)
+		t.Cleanup(func() { trs[i].Close() })
+	}
+
+	for i := range trs {
+		for j := range trs {
+			if connected(i, j) {
+				trs[i].Connect(trs[j])
+				nodes[i].AddPeer(trs[j].Addr())
+			}
+		}
+
+		go func(tr *LocalTransport, ps *PubSub) {
+			for rpc := range tr.Consume() {
+				msg, err := DecodeMessage(rpc.Payload)
+				if err != nil {
+					continue
+				}
+				ps.ProcessMessage(rpc.From, msg)
+			}
+		}(trs[i], nodes[i])
+	}
+
+	return nodes
+}
+
+// Assina o tópico em todos os nós e espera até que todos saibam quem assina o quê.
+func subscribeAll(t *testing.T, nodes []*PubSub, topic string) []*Subscription {
+	subs := make([]*Subscription, len(nodes))
+	for i, n := range nodes {
+		sub, err := n.Subscribe(topic)
+		assert.Nil(t, err)
+		subs[i] = sub
+	}
+
+	assert.Eventually(t, func() bool {
+		for _, n := range nodes {
+			n.lock.Lock()
+			for _, topics := range n.peers {
+				if _, ok := topics[topic]; !ok {
+					n.lock.Unlock()
+					return false
+				}
+			}
+			n.lock.Unlock()
+		}
+		return true
+	}, time.Second, 5*time.Millisecond)
+
+	for _, n := range nodes {
+		n.Heartbeat()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	return subs
+}
+
+func receive(t *testing.T, sub *Subscription) *PubSubMessage {
+	select {
+	case msg := <-sub.Messages():
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+		return nil
+	}
+}
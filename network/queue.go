@@ -0,0 +1,84 @@
+package network
+
+import "sync"
+
+// DropPolicy define o que acontece quando uma fila limitada está cheia.
+type DropPolicy byte
+
+const (
+	DropNewest DropPolicy = iota // Descarta a mensagem que está chegando
+	DropOldest                   // Remove a mensagem mais antiga da fila para abrir espaço
+)
+
+// messageQueue: Fila FIFO limitada de RPCs (buffer circular).
+// Push nunca bloqueia; quando a fila está cheia a DropPolicy decide qual mensagem é descartada.
+// O canal notify recebe um sinal sempre que uma mensagem é enfileirada.
+type messageQueue struct {
+	lock   sync.Mutex
+	items  []RPC
+	head   int
+	size   int
+	policy DropPolicy
+	notify chan struct{}
+}
+
+func newMessageQueue(capacity int, policy DropPolicy) *messageQueue {
+	return &messageQueue{
+		items:  make([]RPC, capacity),
+		policy: policy,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// Enfileira uma mensagem. Retorna true se alguma mensagem (a nova ou a mais antiga) foi descartada.
+func (q *messageQueue) Push(rpc RPC) bool {
+	q.lock.Lock()
+	dropped := false
+
+	if q.size == len(q.items) {
+		dropped = true
+		if q.policy == DropNewest {
+			q.lock.Unlock()
+			return true
+		}
+		// DropOldest: avança a cabeça, descartando a mensagem mais antiga.
+		q.items[q.head] = RPC{}
+		q.head = (q.head + 1) % len(q.items)
+		q.size--
+	}
+
+	q.items[(q.head+q.size)%len(q.items)] = rpc
+	q.size++
+	q.lock.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+
+	return dropped
+}
+
+// Remove e retorna a mensagem mais antiga. O segundo valor é false se a fila estiver vazia.
+func (q *messageQueue) Pop() (RPC, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.size == 0 {
+		return RPC{}, false
+	}
+
+	rpc := q.items[q.head]
+	q.items[q.head] = RPC{}
+	q.head = (q.head + 1) % len(q.items)
+	q.size--
+
+	return rpc, true
+}
+
+// Retorna o número de mensagens na fila.
+func (q *messageQueue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.size
+}
@@ -0,0 +1,76 @@
+package network
+
+import (
+	"sync"
+	"time"
+)
+
+// Rate: Limite de taxa no formato token bucket.
+// PerSecond é a quantidade de mensagens repostas por segundo e Burst o máximo acumulado.
+// Um Rate com PerSecond <= 0 significa "sem limite".
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// rateLimiter implementa um token bucket simples e seguro para uso concorrente.
+type rateLimiter struct {
+	lock   sync.Mutex
+	rate   Rate
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// Cria um limitador para o Rate informado. Retorna nil quando o Rate não impõe limite,
+// e um limitador nil sempre permite a mensagem.
+func newRateLimiter(r Rate) *rateLimiter {
+	if r.PerSecond <= 0 {
+		return nil
+	}
+	if r.Burst <= 0 {
+		r.Burst = 1
+	}
+
+	return &rateLimiter{
+		rate:   r,
+		tokens: float64(r.Burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// Consome um token se houver disponível. Retorna false quando o limite foi atingido.
+func (l *rateLimiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate.PerSecond
+	if l.tokens > float64(l.rate.Burst) {
+		l.tokens = float64(l.rate.Burst)
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Devolve um token consumido por Allow, sem passar do Burst.
+func (l *rateLimiter) Refund() {
+	if l == nil {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.tokens = min(l.tokens+1, float64(l.rate.Burst))
+}
@@ -0,0 +1,278 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	defaultRequestTimeout    = 5 * time.Second
+	defaultMaxServedRequests = 64
+)
+
+var (
+	ErrRequestTimeout = errors.New("request timed out")
+	ErrNoPeers        = errors.New("no peers to send the request to")
+	ErrRequestBusy    = errors.New("too many requests in progress")
+)
+
+// Sender: Qualquer coisa capaz de enviar um payload para um endereço.
+// Trasport e Server implementam essa interface.
+type Sender interface {
+	SendMessage(NetAddr, []byte) error
+}
+
+// Request: Requisição enviada a um peer. ID correlaciona a requisição com a sua resposta
+// e Method escolhe o RequestHandler que vai atendê-la do outro lado.
+type Request struct {
+	ID     uint64
+	Method string
+	Body   []byte
+}
+
+// Response: Resposta a uma Request. Error é preenchido quando o handler remoto falhou.
+type Response struct {
+	ID    uint64
+	Body  []byte
+	Error string
+}
+
+// RemoteError: Erro retornado pelo handler do peer que atendeu a requisição.
+type RemoteError struct {
+	Peer    NetAddr
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Peer, e.Message)
+}
+
+// RequestHandler atende uma requisição recebida de um peer e retorna o corpo da resposta.
+type RequestHandler func(from NetAddr, body []byte) ([]byte, error)
+
+// RequestOpts define o comportamento das requisições feitas por um RequestManager.
+// Timeout é o prazo de cada tentativa (padrão 5s) e Retries o número de tentativas extras,
+// cada uma enviada ao próximo peer da lista informada em Request.
+// MaxServed é o número máximo de requisições recebidas atendidas ao mesmo tempo (padrão 64);
+// além dele, as requisições são respondidas com ErrRequestBusy sem chamar o handler.
+type RequestOpts struct {
+	Timeout   time.Duration
+	Retries   int
+	MaxServed int
+}
+
+type pendingRequest struct {
+	peer NetAddr
+	resp chan *Response
+}
+
+// RequestManager implementa requisição/resposta sobre mensagens fire-and-forget.
+// As requisições saem pelo Sender e as mensagens MessageTypeRequest/MessageTypeResponse
+// recebidas devem ser entregues em ProcessMessage por quem lê o Consume do transport.
+type RequestManager struct {
+	sender Sender
+	opts   RequestOpts
+
+	lock     sync.Mutex
+	nextID   uint64
+	pending  map[uint64]*pendingRequest
+	handlers map[string]RequestHandler
+	serving  chan struct{} // Semáforo das requisições recebidas em atendimento
+}
+
+// Cria um novo RequestManager que envia as mensagens pelo Sender informado.
+func NewRequestManager(sender Sender, opts RequestOpts) *RequestManager {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultRequestTimeout
+	}
+	if opts.MaxServed <= 0 {
+		opts.MaxServed = defaultMaxServedRequests
+	}
+
+	return &RequestManager{
+		sender:   sender,
+		opts:     opts,
+		pending:  make(map[uint64]*pendingRequest),
+		handlers: make(map[string]RequestHandler),
+		serving:  make(chan struct{}, opts.MaxServed),
+	}
+}
+
+// Registra o handler responsável pelas requisições de um método.
+func (m *RequestManager) Handle(method string, h RequestHandler) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.handlers[method] = h
+}
+
+// Envia uma requisição e aguarda a resposta.
+// A primeira tentativa vai para peers[0]; se ela falhar ou expirar, as tentativas seguintes
+// (até opts.Retries) vão para os próximos peers da lista. O contexto cancela a requisição a qualquer momento.
+func (m *RequestManager) Request(ctx context.Context, peers []NetAddr, method string, body []byte) ([]byte, error) {
+	if len(peers) == 0 {
+		return nil, ErrNoPeers
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= m.opts.Retries; attempt++ {
+		peer := peers[attempt%len(peers)]
+
+		resp, err := m.requestPeer(ctx, peer, method, body)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"peer":    peer,
+			"method":  method,
+			"attempt": attempt,
+		}).Debug("request failed: ", err)
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// Faz uma única tentativa contra um peer.
+func (m *RequestManager) requestPeer(ctx context.Context, peer NetAddr, method string, body []byte) ([]byte, error) {
+	m.lock.Lock()
+	m.nextID++
+	req := &Request{ID: m.nextID, Method: method, Body: body}
+	p := &pendingRequest{peer: peer, resp: make(chan *Response, 1)}
+	m.pending[req.ID] = p
+	m.lock.Unlock()
+
+	defer func() {
+		m.lock.Lock()
+		delete(m.pending, req.ID)
+		m.lock.Unlock()
+	}()
+
+	data, err := encodeGob(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.sender.SendMessage(peer, NewMessage(MessageTypeRequest, data).Bytes()); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(m.opts.Timeout)
+	defer timer.Stop()
+
+	select {
+	case resp := <-p.resp:
+		if resp.Error != "" {
+			return nil, &RemoteError{Peer: peer, Message: resp.Error}
+		}
+		return resp.Body, nil
+	case <-timer.C:
+		return nil, ErrRequestTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Processa uma mensagem de requisição ou de resposta recebida de um peer.
+// Requisições são atendidas em uma goroutine própria, para não bloquear quem lê o transport,
+// com no máximo opts.MaxServed em andamento; as excedentes recebem ErrRequestBusy.
+// Respostas só são aceitas se vierem do peer para o qual a requisição foi enviada.
+func (m *RequestManager) ProcessMessage(from NetAddr, msg *Message) error {
+	switch msg.Header {
+	case MessageTypeRequest:
+		req := new(Request)
+		if err := decodeGob(msg.Data, req); err != nil {
+			return err
+		}
+
+		select {
+		case m.serving <- struct{}{}:
+		default:
+			m.respond(from, req.Method, &Response{ID: req.ID, Error: ErrRequestBusy.Error()})
+			return nil
+		}
+
+		m.lock.Lock()
+		h, ok := m.handlers[req.Method]
+		m.lock.Unlock()
+
+		go func() {
+			defer func() { <-m.serving }()
+			m.serve(from, req, h, ok)
+		}()
+		return nil
+
+	case MessageTypeResponse:
+		resp := new(Response)
+		if err := decodeGob(msg.Data, resp); err != nil {
+			return err
+		}
+
+		m.lock.Lock()
+		p, ok := m.pending[resp.ID]
+		m.lock.Unlock()
+
+		if !ok || p.peer != from {
+			return fmt.Errorf("unexpected response (%d) from %s", resp.ID, from)
+		}
+
+		select {
+		case p.resp <- resp:
+		default:
+		}
+		return nil
+	}
+
+	return fmt.Errorf("invalid message type (%d) for request manager", msg.Header)
+}
+
+func (m *RequestManager) serve(from NetAddr, req *Request, h RequestHandler, ok bool) {
+	resp := &Response{ID: req.ID}
+
+	if !ok {
+		resp.Error = fmt.Sprintf("unknown method %q", req.Method)
+	} else if body, err := h(from, req.Body); err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Body = body
+	}
+
+	m.respond(from, req.Method, resp)
+}
+
+func (m *RequestManager) respond(to NetAddr, method string, resp *Response) {
+	data, err := encodeGob(resp)
+	if err == nil {
+		err = m.sender.SendMessage(to, NewMessage(MessageTypeResponse, data).Bytes())
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"peer":   to,
+			"method": method,
+		}).Error("could not send response: ", err)
+	}
+}
+
+// Serializa um valor usando gob.
+func encodeGob(v any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Desserializa um valor codificado por encodeGob.
+func decodeGob(b []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
@@ -0,0 +1,152 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestResponse(t *testing.T) {
+	tra, trb := connectedTransports(t, "A", "B")
+	ma := serveRequests(tra, RequestOpts{})
+	mb := serveRequests(trb, RequestOpts{})
+
+	mb.Handle("echo", func(from NetAddr, body []byte) ([]byte, error) {
+		return append([]byte(from+":"), body...), nil
+	})
+
+	resp, err := ma.Request(context.Background(), []NetAddr{trb.Addr()}, "echo", []byte("hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("A:hello"), resp)
+}
+
+func TestRequestRemoteError(t *testing.T) {
+	tra, trb := connectedTransports(t, "A", "B")
+	ma := serveRequests(tra, RequestOpts{})
+	mb := serveRequests(trb, RequestOpts{})
+
+	mb.Handle("fail", func(NetAddr, []byte) ([]byte, error) {
+		return nil, errors.New("not found")
+	})
+
+	_, err := ma.Request(context.Background(), []NetAddr{trb.Addr()}, "fail", nil)
+	var remoteErr *RemoteError
+	assert.ErrorAs(t, err, &remoteErr)
+	assert.Equal(t, "not found", remoteErr.Message)
+
+	_, err = ma.Request(context.Background(), []NetAddr{trb.Addr()}, "missing", nil)
+	assert.ErrorAs(t, err, &remoteErr)
+}
+
+func TestRequestRetriesAlternatePeer(t *testing.T) {
+	tra, trb := connectedTransports(t, "A", "B")
+	trc := NewLocalTransport("C")
+	defer trc.Close()
+	tra.Connect(trc)
+	trc.Connect(tra)
+
+	ma := serveRequests(tra, RequestOpts{Timeout: 50 * time.Millisecond, Retries: 1})
+	serveRequests(trb, RequestOpts{}) // B não atende o método: a primeira tentativa falha
+	mc := serveRequests(trc, RequestOpts{})
+
+	mc.Handle("height", func(NetAddr, []byte) ([]byte, error) {
+		return []byte{42}, nil
+	})
+
+	resp, err := ma.Request(context.Background(), []NetAddr{trb.Addr(), trc.Addr()}, "height", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{42}, resp)
+}
+
+func TestRequestTimeoutAndCancel(t *testing.T) {
+	tra, trb := connectedTransports(t, "A", "B")
+	ma := serveRequests(tra, RequestOpts{Timeout: 20 * time.Millisecond})
+	// Ninguém processa as mensagens que chegam em B, então as requisições nunca são respondidas.
+
+	_, err := ma.Request(context.Background(), []NetAddr{trb.Addr()}, "slow", nil)
+	assert.ErrorIs(t, err, ErrRequestTimeout)
+
+	ma = NewRequestManager(tra, RequestOpts{Timeout: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	_, err = ma.Request(ctx, []NetAddr{trb.Addr()}, "slow", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = ma.Request(context.Background(), nil, "slow", nil)
+	assert.ErrorIs(t, err, ErrNoPeers)
+}
+
+func TestRequestLimitsServedRequests(t *testing.T) {
+	tra, trb := connectedTransports(t, "A", "B")
+	ma := serveRequests(tra, RequestOpts{})
+	mb := serveRequests(trb, RequestOpts{MaxServed: 1})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	mb.Handle("slow", func(NetAddr, []byte) ([]byte, error) {
+		close(started)
+		<-release
+		return []byte("done"), nil
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ma.Request(context.Background(), []NetAddr{trb.Addr()}, "slow", nil)
+		done <- err
+	}()
+	<-started
+
+	// Com o único atendimento ocupado, a próxima requisição é recusada sem chamar o handler.
+	_, err := ma.Request(context.Background(), []NetAddr{trb.Addr()}, "slow", nil)
+	var remoteErr *RemoteError
+	assert.ErrorAs(t, err, &remoteErr)
+	assert.Equal(t, ErrRequestBusy.Error(), remoteErr.Message)
+
+	close(release)
+	assert.Nil(t, <-done)
+}
+
+func TestRequestIgnoresResponseFromOtherPeer(t *testing.T) {
+	m := NewRequestManager(NewLocalTransport("A"), RequestOpts{})
+	m.pending[1] = &pendingRequest{peer: "B", resp: make(chan *Response, 1)}
+
+	data, err := encodeGob(&Response{ID: 1, Body: []byte("spoofed")})
+	assert.Nil(t, err)
+
+	assert.NotNil(t, m.ProcessMessage("C", NewMessage(MessageTypeResponse, data)))
+	assert.Nil(t, m.ProcessMessage("B", NewMessage(MessageTypeResponse, data)))
+}
+
+func connectedTransports(t *testing.T, a, b NetAddr) (*LocalTransport, *LocalTransport) {
+	tra := NewLocalTransport(a)
+	trb := NewLocalTransport(b)
+	t.Cleanup(func() {
+		tra.Close()
+		trb.Close()
+	})
+
+	tra.Connect(trb)
+	trb.Connect(tra)
+
+	return tra, trb
+}
+
+// Cria um RequestManager que lê as mensagens do Consume do transport.
+func serveRequests(tr *LocalTransport, opts RequestOpts) *RequestManager {
+	m := NewRequestManager(tr, opts)
+	go func() {
+		for rpc := range tr.Consume() {
+			msg, err := DecodeMessage(rpc.Payload)
+			if err != nil {
+				continue
+			}
+			m.ProcessMessage(rpc.From, msg)
+		}
+	}()
+	return m
+}
@@ -1,23 +1,60 @@
 package network
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/FelipePn10/fadden/core"
 	"github.com/FelipePn10/fadden/crypto"
+	"github.com/FelipePn10/fadden/types"
 	"github.com/sirupsen/logrus"
 )
 
 // ServerOpts define as opções do servidor.
 // Transports é uma lista de transportes que o servidor pode usar.
 // Ex: LocalTransport, RemoteTransport
-var defaulBlockTime = 5 * time.Second
+var (
+	defaulBlockTime      = 5 * time.Second
+	defaultRPCQueueSize  = 1024
+	heartbeatInterval    = time.Second
+	recentBlocksSize     = 64
+	defaultJournalRotate = time.Minute
+	maxBlockFetches      = 16 // Blocos compactos sendo completados ao mesmo tempo
+	maxPeerBlockFetches  = 2  // Blocos compactos de um mesmo peer sendo completados ao mesmo tempo
+)
 
 type ServerOpts struct {
-	Transports []Trasport
-	BlockTime  time.Duration
-	PrivateKey *crypto.PrivateKey
+	Transports   []Trasport
+	BlockTime    time.Duration
+	PrivateKey   *crypto.PrivateKey
+	RPCQueueSize int // Capacidade do canal central de mensagens (padrão 1024). Cheio, as novas mensagens são descartadas
+	RequestOpts  RequestOpts
+	PubSubOpts   PubSubOpts
+	TxPoolOpts   TxPoolOpts
+	FeeOpts      FeeEstimatorOpts
+
+	// Intervalo de versões do protocolo aceitas (padrão [MinProtocolVersion, MaxProtocolVersion])
+	// e capacidades que este nó não deve anunciar.
+	MinProtocolVersion   uint32
+	MaxProtocolVersion   uint32
+	DisabledCapabilities Capability
+
+	// Mensagens com dados a partir desse tamanho são comprimidas para os peers que negociaram
+	// CapCompression (padrão DefaultCompressionThreshold).
+	CompressionThreshold int
+
+	// Arquivo do journal do mempool (vazio = sem journal). As transações do journal são
+	// revalidadas na inicialização e o arquivo é compactado a cada JournalRotate (padrão 1 minuto).
+	JournalPath   string
+	JournalRotate time.Duration
+
+	// Cadeia do nó (opcional). Os blocos recebidos são adicionados a ela e o mempool acompanha as
+	// mudanças da cadeia canônica (ver TxPool.HandleChainEvent), inclusive as reorganizações.
+	// Sem um NonceSource em TxPoolOpts, o mempool usa os nonces da cadeia.
+	Blockchain *core.Blockchain
 }
 
 // Server é a estrutura que representa um servidor.
@@ -25,9 +62,23 @@ type Server struct {
 	ServerOpts  // Opções do servidor
 	blockTime   time.Duration
 	memPool     *TxPool
+	fees        *FeeEstimator
+	journal     *TxJournal // Nil sem JournalPath
 	isValidator bool
 	rpcChan     chan RPC      // Canal central para receber mensagens de todos os transports
 	quitCh      chan struct{} // Canal para sinalizar parada do servidor
+	droppedRPCs atomic.Uint64 // Mensagens descartadas com o canal central cheio
+	requests    *RequestManager
+	pubsub      *PubSub
+	blocks      *blockCache // Blocos recentes, usados para atender pedidos de transações de blocos compactos
+
+	fetched   chan fetchedBlock // Blocos compactos completados, tratados pelo loop principal
+	fetchLock sync.Mutex
+	fetching  map[types.Hash]NetAddr // Blocos compactos cujas transações estão sendo pedidas e o peer que os enviou
+
+	peerLock sync.RWMutex
+	peers    map[NetAddr]Trasport // Transport pelo qual cada peer foi visto pela última vez
+	peerInfo map[NetAddr]PeerInfo // Versão e capacidades negociadas com cada peer
 }
 
 // NewServer cria um novo servidor com as opções especificadas.
@@ -36,36 +87,148 @@ func NewServer(opts ServerOpts) *Server {
 	if opts.BlockTime == time.Duration(0) {
 		opts.BlockTime = defaulBlockTime
 	}
-	return &Server{ // Retorna um ponteiro para a estrutura Server
+	if opts.RPCQueueSize <= 0 {
+		opts.RPCQueueSize = defaultRPCQueueSize
+	}
+	if opts.MinProtocolVersion == 0 {
+		opts.MinProtocolVersion = MinProtocolVersion
+	}
+	if opts.MaxProtocolVersion == 0 {
+		opts.MaxProtocolVersion = MaxProtocolVersion
+	}
+	if opts.CompressionThreshold <= 0 {
+		opts.CompressionThreshold = DefaultCompressionThreshold
+	}
+	if opts.JournalRotate <= 0 {
+		opts.JournalRotate = defaultJournalRotate
+	}
+	if opts.Blockchain != nil && opts.TxPoolOpts.Nonces == nil {
+		opts.TxPoolOpts.Nonces = opts.Blockchain
+	}
+	s := &Server{ // Cria a estrutura Server
 		ServerOpts:  opts, // Inicializa as opções do servidor
-		memPool:     NewTxPool(),
+		memPool:     NewTxPoolWithOpts(opts.TxPoolOpts),
 		blockTime:   opts.BlockTime,
 		isValidator: opts.PrivateKey != nil,
-		rpcChan:     make(chan RPC, 1024),   // Canal bufferizado para 1024 mensagens
-		quitCh:      make(chan struct{}, 1), // Canal bufferizado para 1 mensagem
+		rpcChan:     make(chan RPC, opts.RPCQueueSize), // Canal bufferizado (padrão de 1024 mensagens)
+		quitCh:      make(chan struct{}, 1),            // Canal bufferizado para 1 mensagem
+		peers:       make(map[NetAddr]Trasport),
+		peerInfo:    make(map[NetAddr]PeerInfo),
+		blocks:      newBlockCache(recentBlocksSize),
+		fetched:     make(chan fetchedBlock, maxBlockFetches),
+		fetching:    make(map[types.Hash]NetAddr),
 	}
+	s.fees = NewFeeEstimator(s.memPool, opts.FeeOpts)
+	if opts.JournalPath != "" {
+		s.journal = NewTxJournal(opts.JournalPath)
+	}
+	s.requests = NewRequestManager(s, opts.RequestOpts)
+	s.requests.Handle(getBlockTxnMethod, s.serveBlockTxn)
+	s.pubsub = NewPubSub(s.addr(), s, opts.PubSubOpts)
+	if opts.Blockchain != nil {
+		opts.Blockchain.Subscribe(s.memPool.HandleChainEvent)
+	}
+
+	return s // Retorna um ponteiro para a estrutura Server
 }
 
 func (s *Server) Start() {
 	s.initTransports()                    // Inicializa os transportes
-	ticker := time.NewTicker(s.blockTime) // Cria um ticker que dispara a cada segundo
+	ticker := time.NewTicker(s.blockTime) // Cria um ticker que dispara a cada blockTime
+	heartbeat := time.NewTicker(heartbeatInterval)
+
+	var rotate <-chan time.Time // Continua nil (nunca dispara) sem journal
+	if s.journal != nil {
+		s.loadJournal()
+		journalTicker := time.NewTicker(s.JournalRotate)
+		defer journalTicker.Stop()
+		rotate = journalTicker.C
+	}
 
 free: // Rótulo para o loop
 	for {
 		select { // Seleciona o primeiro canal que estiver pronto
 		case rpc := <-s.rpcChan:
-			fmt.Printf("%+v\n", rpc)
+			if err := s.processRPC(rpc); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"from": rpc.From,
+				}).Error(err)
+			}
+		case f := <-s.fetched: // Bloco compacto completado por fetchMissingTxs
+			if err := s.handleBlock(f.block); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"from": f.from,
+				}).Error(err)
+			}
+			s.endFetch(f.hash)
 		case <-s.quitCh: // Recebe uma mensagem do canal quitCh
 			break free
 		case <-ticker.C: // Tarefas periódicas (ex: logs)
 			if s.isValidator {
 				s.createNewBlock()
 			}
+		case <-heartbeat.C: // Manutenção das malhas do pub/sub
+			s.pubsub.Heartbeat()
+		case <-rotate: // Compacta o journal, removendo as transações que já saíram do mempool
+			s.rotateJournal()
 		}
 	}
+	ticker.Stop()
+	heartbeat.Stop()
+	if s.journal != nil {
+		s.rotateJournal()
+		s.journal.Close()
+	}
 	fmt.Println("Server shutdown")
 }
 
+// Decodifica a mensagem recebida e a encaminha para quem sabe tratá-la.
+func (s *Server) processRPC(rpc RPC) error {
+	msg, err := DecodeMessage(rpc.Payload)
+	if err != nil {
+		return err
+	}
+
+	if msg.Header != MessageTypeHandshake {
+		if err := s.checkCompatible(rpc.From); err != nil {
+			return err
+		}
+	}
+
+	switch msg.Header {
+	case MessageTypeHandshake:
+		hs := new(Handshake)
+		if err := decodeGob(msg.Data, hs); err != nil {
+			return err
+		}
+		return s.handleHandshake(rpc.From, hs)
+	case MessageTypeTx:
+		tx := new(core.Transaction)
+		if err := decodeGob(msg.Data, tx); err != nil {
+			return err
+		}
+		return s.handleTransaction(tx)
+	case MessageTypeBlock:
+		b := new(core.Block)
+		if err := decodeGob(msg.Data, b); err != nil {
+			return err
+		}
+		return s.handleBlock(b)
+	case MessageTypeGossip:
+		return s.pubsub.ProcessMessage(rpc.From, msg)
+	case MessageTypeCompactBlock:
+		cb := new(CompactBlock)
+		if err := decodeGob(msg.Data, cb); err != nil {
+			return err
+		}
+		return s.handleCompactBlock(rpc.From, cb)
+	default:
+		fmt.Printf("%+v\n", rpc)
+	}
+
+	return nil
+}
+
 func (s *Server) handleTransaction(tx *core.Transaction) error {
 	if err := tx.Verify(); err != nil {
 		return err
@@ -82,8 +245,245 @@ func (s *Server) handleTransaction(tx *core.Transaction) error {
 
 	logrus.WithFields(logrus.Fields{
 		"hash": hash,
-	}).Info("adding new tx (%s) to the mempool")
-	return s.memPool.Add(tx)
+	}).Info("adding new tx to the mempool")
+	if err := s.memPool.Add(tx); err != nil {
+		return err
+	}
+
+	if s.journal != nil {
+		if err := s.journal.Insert(tx); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"hash": hash,
+			}).Error("could not write tx to the journal: ", err)
+		}
+	}
+	return nil
+}
+
+// Recarrega no mempool as transações do journal, revalidando cada uma, e compacta o arquivo.
+func (s *Server) loadJournal() {
+	loaded, dropped, err := s.journal.Load(func(tx *core.Transaction) error {
+		if err := tx.Verify(); err != nil {
+			return err
+		}
+		return s.memPool.Add(tx)
+	})
+	if err != nil {
+		logrus.WithField("path", s.JournalPath).Error("could not load the tx journal: ", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"loaded":  loaded,
+		"dropped": dropped,
+	}).Info("loaded transactions from the journal")
+
+	s.rotateJournal()
+}
+
+func (s *Server) rotateJournal() {
+	if err := s.journal.Rotate(s.memPool.Transactions()); err != nil {
+		logrus.WithField("path", s.JournalPath).Error("could not rotate the tx journal: ", err)
+	}
+}
+
+// Envia um bloco a todos os peers conhecidos: no formato compacto para os peers que negociaram
+// CapCompactBlocks e completo para os demais.
+// O bloco fica no cache de blocos recentes para atender os pedidos das transações que faltarem.
+func (s *Server) RelayBlock(b *core.Block) error {
+	s.blocks.Add(b)
+
+	compact, err := encodeGob(NewCompactBlock(b))
+	if err != nil {
+		return err
+	}
+	full, err := encodeGob(b)
+	if err != nil {
+		return err
+	}
+
+	s.peerLock.RLock()
+	peers := make([]NetAddr, 0, len(s.peers))
+	for addr := range s.peers {
+		peers = append(peers, addr)
+	}
+	s.peerLock.RUnlock()
+
+	for _, addr := range peers {
+		msg := NewMessage(MessageTypeBlock, full)
+		if s.PeerInfo(addr).Supports(CapCompactBlocks) {
+			msg = NewMessage(MessageTypeCompactBlock, compact)
+		}
+
+		if err := s.SendMessage(addr, msg.Bytes()); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"peer": addr,
+			}).Error("could not relay block: ", err)
+		}
+	}
+
+	return nil
+}
+
+// Bloco compacto completado com as transações pedidas ao peer que o enviou.
+type fetchedBlock struct {
+	from  NetAddr
+	hash  types.Hash
+	block *core.Block
+}
+
+// Reconstrói um bloco compacto com as transações do mempool.
+// Se faltarem transações, elas são pedidas ao peer que enviou o bloco em uma goroutine,
+// para não travar o loop principal enquanto a resposta não chega. O bloco completo volta
+// para o loop principal pelo canal fetched. No máximo maxBlockFetches blocos (maxPeerBlockFetches
+// por peer) ficam pendentes ao mesmo tempo; os excedentes são descartados.
+func (s *Server) handleCompactBlock(from NetAddr, cb *CompactBlock) error {
+	hash := cb.Hash()
+	if s.blocks.Has(hash) {
+		return nil
+	}
+
+	pb, err := cb.Reconstruct(s.memPool)
+	if err != nil {
+		return err
+	}
+
+	if len(pb.Missing()) == 0 {
+		b, err := pb.Block()
+		if err != nil {
+			return err
+		}
+		return s.handleBlock(b)
+	}
+
+	ok, err := s.startFetch(hash, from)
+	if !ok {
+		return err
+	}
+
+	go func() {
+		b, err := s.fetchMissingTxs(from, cb, pb)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"peer": from,
+				"hash": hash,
+			}).Error("could not reconstruct compact block: ", err)
+			s.endFetch(hash)
+			return
+		}
+		// Não bloqueia: o canal comporta todos os blocos pendentes e a vaga só é liberada
+		// depois que o loop principal trata o bloco.
+		s.fetched <- fetchedBlock{from: from, hash: hash, block: b}
+	}()
+
+	return nil
+}
+
+// Reserva uma vaga para completar o bloco. Retorna false sem erro se o bloco já está sendo completado.
+func (s *Server) startFetch(hash types.Hash, from NetAddr) (bool, error) {
+	s.fetchLock.Lock()
+	defer s.fetchLock.Unlock()
+
+	if _, ok := s.fetching[hash]; ok {
+		return false, nil
+	}
+	if len(s.fetching) >= maxBlockFetches {
+		return false, fmt.Errorf("too many compact blocks pending, dropping block (%s)", hash)
+	}
+
+	var fromPeer int
+	for _, peer := range s.fetching {
+		if peer == from {
+			fromPeer++
+		}
+	}
+	if fromPeer >= maxPeerBlockFetches {
+		return false, fmt.Errorf("too many compact blocks pending from %s, dropping block (%s)", from, hash)
+	}
+
+	s.fetching[hash] = from
+	return true, nil
+}
+
+// Libera a vaga reservada por startFetch.
+func (s *Server) endFetch(hash types.Hash) {
+	s.fetchLock.Lock()
+	defer s.fetchLock.Unlock()
+
+	delete(s.fetching, hash)
+}
+
+// Pede ao peer as transações que faltam e retorna o bloco completo.
+func (s *Server) fetchMissingTxs(from NetAddr, cb *CompactBlock, pb *PartialBlock) (*core.Block, error) {
+	body, err := encodeGob(&GetBlockTxn{BlockHash: cb.Hash(), Indexes: pb.Missing()})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Request(context.Background(), []NetAddr{from}, getBlockTxnMethod, body)
+	if err != nil {
+		return nil, err
+	}
+
+	blockTxn := new(BlockTxn)
+	if err := decodeGob(resp, blockTxn); err != nil {
+		return nil, err
+	}
+
+	return pb.Fill(blockTxn.Transactions)
+}
+
+// Atende um GetBlockTxn com as transações de um bloco recente.
+func (s *Server) serveBlockTxn(from NetAddr, body []byte) ([]byte, error) {
+	req := new(GetBlockTxn)
+	if err := decodeGob(body, req); err != nil {
+		return nil, err
+	}
+
+	b, ok := s.blocks.Get(req.BlockHash)
+	if !ok {
+		return nil, fmt.Errorf("unknown block (%s)", req.BlockHash)
+	}
+
+	resp := &BlockTxn{BlockHash: req.BlockHash}
+	for _, idx := range req.Indexes {
+		if int(idx) >= len(b.Transactions) {
+			return nil, fmt.Errorf("block (%s) has no transaction at index %d", req.BlockHash, idx)
+		}
+		resp.Transactions = append(resp.Transactions, &b.Transactions[idx])
+	}
+
+	return encodeGob(resp)
+}
+
+// Trata um bloco recebido de um peer. Chamado apenas pelo loop principal.
+// Com uma Blockchain, o bloco é adicionado à cadeia e o mempool é atualizado pelos eventos dela.
+func (s *Server) handleBlock(b *core.Block) error {
+	if err := b.Verify(); err != nil {
+		return err
+	}
+
+	if s.blocks.Has(b.Hash(core.BlockHasher{})) {
+		return nil
+	}
+	// O bloco só entra no cache depois de aceito pela cadeia: um bloco recusado (por exemplo, recebido
+	// antes do pai) pode ser aceito quando chegar de novo.
+	if s.Blockchain != nil {
+		if err := s.Blockchain.AddBlock(b); err != nil {
+			return err
+		}
+	} else {
+		s.memPool.RemoveIncluded(b)
+	}
+	s.blocks.Add(b)
+	s.fees.AddBlock(b)
+
+	logrus.WithFields(logrus.Fields{
+		"height": b.Height,
+		"hash":   b.Hash(core.BlockHasher{}),
+		"txs":    len(b.Transactions),
+	}).Info("received new block")
+
+	return nil
 }
 
 func (s *Server) createNewBlock() error {
@@ -91,12 +491,204 @@ func (s *Server) createNewBlock() error {
 	return nil
 }
 
+// Retorna a taxa por byte sugerida para uma transação entrar no próximo bloco.
+func (s *Server) EstimateFeeRate() float64 {
+	return s.fees.EstimateFeeRate()
+}
+
+// Retorna o número de mensagens descartadas porque o canal central estava cheio.
+func (s *Server) DroppedRPCs() uint64 {
+	return s.droppedRPCs.Load()
+}
+
+// Envia uma mensagem para um peer pelo transport em que ele foi visto pela última vez.
+// Se o peer ainda não é conhecido, tenta cada um dos transports até um deles aceitar a mensagem.
+// Para peers que negociaram CapCompression, mensagens grandes são comprimidas.
+func (s *Server) SendMessage(to NetAddr, payload []byte) error {
+	if s.PeerInfo(to).Supports(CapCompression) {
+		payload = compressPayload(payload, s.CompressionThreshold)
+	}
+
+	s.peerLock.RLock()
+	tr, ok := s.peers[to]
+	s.peerLock.RUnlock()
+
+	if ok {
+		return tr.SendMessage(to, payload)
+	}
+
+	err := fmt.Errorf("unknown peer %s", to)
+	for _, tr := range s.Transports {
+		if err = tr.SendMessage(to, payload); err == nil {
+			s.trackPeer(to, tr)
+			return nil
+		}
+	}
+	return err
+}
+
+// Envia uma requisição aos peers informados e aguarda a resposta (ver RequestManager.Request).
+func (s *Server) Request(ctx context.Context, peers []NetAddr, method string, body []byte) ([]byte, error) {
+	return s.requests.Request(ctx, peers, method, body)
+}
+
+// Registra o handler das requisições recebidas para um método.
+func (s *Server) HandleRequest(method string, h RequestHandler) {
+	s.requests.Handle(method, h)
+}
+
+// Retorna a camada de pub/sub do servidor, usada para assinar e publicar em tópicos.
+func (s *Server) PubSub() *PubSub {
+	return s.pubsub
+}
+
+// Registra um peer alcançável pelo transport informado, o anuncia às camadas que dependem
+// de conhecer os peers (ex: o pub/sub, que envia a ele os tópicos assinados) e inicia a
+// negociação de versão e capacidades enviando o nosso handshake.
+func (s *Server) AddPeer(addr NetAddr, tr Trasport) error {
+	s.trackPeer(addr, tr)
+	return s.sendHandshake(addr, false)
+}
+
+// Retorna a versão e as capacidades negociadas com um peer.
+// Enquanto não houver handshake, o peer é tratado como um nó antigo (ProtocolVersion1, sem capacidades).
+func (s *Server) PeerInfo(addr NetAddr) PeerInfo {
+	s.peerLock.RLock()
+	defer s.peerLock.RUnlock()
+
+	if info, ok := s.peerInfo[addr]; ok {
+		return info
+	}
+	return PeerInfo{Version: ProtocolVersion1}
+}
+
+// Handshake com as versões e capacidades deste nó.
+func (s *Server) localHandshake() *Handshake {
+	return &Handshake{
+		MinVersion:   s.MinProtocolVersion,
+		MaxVersion:   s.MaxProtocolVersion,
+		Capabilities: SupportedCapabilities &^ s.DisabledCapabilities,
+	}
+}
+
+func (s *Server) sendHandshake(to NetAddr, reply bool) error {
+	hs := s.localHandshake()
+	hs.Reply = reply
+
+	data, err := encodeGob(hs)
+	if err != nil {
+		return err
+	}
+	return s.SendMessage(to, NewMessage(MessageTypeHandshake, data).Bytes())
+}
+
+// Negocia a versão com o peer e responde com o nosso handshake, se o dele não for uma resposta.
+// Sem versão em comum, o peer é marcado como incompatível e suas mensagens passam a ser ignoradas.
+func (s *Server) handleHandshake(from NetAddr, hs *Handshake) error {
+	info, err := negotiate(s.localHandshake(), hs)
+
+	s.peerLock.Lock()
+	if err != nil {
+		s.peerInfo[from] = PeerInfo{Negotiated: true}
+	} else {
+		s.peerInfo[from] = info
+	}
+	s.peerLock.Unlock()
+
+	if !hs.Reply {
+		if err := s.sendHandshake(from, true); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("handshake with %s failed: %w", from, err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"peer":         from,
+		"version":      info.Version,
+		"capabilities": info.Capabilities,
+	}).Info("handshake completed")
+
+	return nil
+}
+
+// Endereço usado para identificar este nó (o do primeiro transport).
+func (s *Server) addr() NetAddr {
+	if len(s.Transports) == 0 {
+		return ""
+	}
+	return s.Transports[0].Addr()
+}
+
 func (s *Server) initTransports() {
 	for _, tr := range s.Transports { // Para cada transporte na lista de transportes
 		go func(tr Trasport) {
 			for rpc := range tr.Consume() { // Para cada mensagem recebida do canal de mensagens do transporte
-				s.rpcChan <- rpc // Envia a mensagem para o canal rpcChan do servidor
+				s.trackPeer(rpc.From, tr)
+
+				// Requisições e respostas são tratadas aqui mesmo, para que uma requisição feita
+				// pelo loop principal não fique esperando por uma resposta presa no rpcChan.
+				if msgType, _ := messageTypeOf(rpc.Payload); msgType == MessageTypeRequest || msgType == MessageTypeResponse {
+					s.processRequestRPC(rpc)
+					continue
+				}
+
+				s.enqueueRPC(rpc)
 			}
 		}(tr)
 	}
 }
+
+// Registra por qual transport um peer pode ser alcançado.
+func (s *Server) trackPeer(addr NetAddr, tr Trasport) {
+	s.peerLock.Lock()
+	_, known := s.peers[addr]
+	s.peers[addr] = tr
+	s.peerLock.Unlock()
+
+	if !known {
+		s.pubsub.AddPeer(addr)
+	}
+}
+
+func (s *Server) processRequestRPC(rpc RPC) {
+	msg, err := DecodeMessage(rpc.Payload)
+	if err == nil {
+		err = s.checkCompatible(rpc.From)
+	}
+	if err == nil {
+		err = s.requests.ProcessMessage(rpc.From, msg)
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"from": rpc.From,
+		}).Error(err)
+	}
+}
+
+// Retorna erro se a negociação com o peer falhou ou se o peer ainda não negociou e este nó não aceita
+// ProtocolVersion1: as mensagens dele, exceto novos handshakes, são ignoradas.
+func (s *Server) checkCompatible(from NetAddr) error {
+	info := s.PeerInfo(from)
+	if info.Negotiated && info.Version == 0 {
+		return fmt.Errorf("ignoring message from incompatible peer %s", from)
+	}
+	if !info.Negotiated && s.MinProtocolVersion > info.Version {
+		return fmt.Errorf("ignoring message from peer %s before handshake", from)
+	}
+	return nil
+}
+
+// Envia a mensagem para o canal rpcChan do servidor sem bloquear.
+// Se o canal estiver cheio a mensagem é descartada e contabilizada.
+func (s *Server) enqueueRPC(rpc RPC) {
+	select {
+	case s.rpcChan <- rpc:
+	default:
+		s.droppedRPCs.Add(1)
+		logrus.WithFields(logrus.Fields{
+			"from": rpc.From,
+		}).Debug("rpc queue is full, dropping message")
+	}
+}
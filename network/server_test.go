@@ -0,0 +1,75 @@
+package network
+
+import (
+	"context"
+	"testing"
+
+	"github.com/FelipePn10/fadden/core"
+	"github.com/FelipePn10/fadden/crypto"
+	"github.com/FelipePn10/fadden/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerRequest(t *testing.T) {
+	tra, trb := connectedTransports(t, "A", "B")
+
+	sa := startServer(t, ServerOpts{Transports: []Trasport{tra}})
+	sb := startServer(t, ServerOpts{Transports: []Trasport{trb}})
+
+	sb.HandleRequest("ping", func(NetAddr, []byte) ([]byte, error) {
+		return []byte("pong"), nil
+	})
+
+	resp, err := sa.Request(context.Background(), []NetAddr{trb.Addr()}, "ping", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("pong"), resp)
+}
+
+func TestServerReinjectsOrphanedTransactions(t *testing.T) {
+	genesis := chainBlock(t, 0, types.Hash{})
+	bc, err := core.NewBlockchain(genesis)
+	assert.Nil(t, err)
+	s := NewServer(ServerOpts{Blockchain: bc})
+
+	tx := signedTxWithNonce(t, crypto.GeneratePrivateKey(), 0, 10)
+	assert.Nil(t, s.handleTransaction(tx))
+
+	a1 := chainBlock(t, 1, genesis.Hash(core.BlockHasher{}), tx)
+	assert.Nil(t, s.handleBlock(a1))
+	assert.Equal(t, uint32(1), bc.Height())
+	assert.Equal(t, 0, s.memPool.Len())
+
+	// O ramo b é mais alto e não inclui tx: ela volta para o mempool.
+	b1 := chainBlock(t, 1, genesis.Hash(core.BlockHasher{}))
+	b2 := chainBlock(t, 2, b1.Hash(core.BlockHasher{}))
+	assert.Nil(t, s.handleBlock(b1))
+	assert.Nil(t, s.handleBlock(b2))
+	assert.Equal(t, uint32(2), bc.Height())
+	assert.True(t, s.memPool.Has(tx.Hash(core.TxHasher{})))
+}
+
+func TestServerRetriesRejectedBlock(t *testing.T) {
+	genesis := chainBlock(t, 0, types.Hash{})
+	bc, err := core.NewBlockchain(genesis)
+	assert.Nil(t, err)
+	s := NewServer(ServerOpts{Blockchain: bc})
+
+	// b2 chega antes do pai e é recusado; depois de b1 ele precisa ser aceito.
+	b1 := chainBlock(t, 1, genesis.Hash(core.BlockHasher{}))
+	b2 := chainBlock(t, 2, b1.Hash(core.BlockHasher{}))
+	assert.NotNil(t, s.handleBlock(b2))
+	assert.False(t, s.blocks.Has(b2.Hash(core.BlockHasher{})))
+
+	assert.Nil(t, s.handleBlock(b1))
+	assert.Nil(t, s.handleBlock(b2))
+	assert.Equal(t, uint32(2), bc.Height())
+}
+
+func startServer(t *testing.T, opts ServerOpts) *Server {
+	s := NewServer(opts)
+	go s.Start()
+	t.Cleanup(func() {
+		s.quitCh <- struct{}{}
+	})
+	return s
+}
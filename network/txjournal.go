@@ -0,0 +1,184 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/FelipePn10/fadden/core"
+)
+
+// Tamanho máximo de um registro do journal. Registros maiores indicam um arquivo corrompido.
+var maxJournalRecord = 1 << 20
+
+var ErrJournalClosed = errors.New("transaction journal is not open")
+
+// TxJournal: Arquivo em disco com as transações aceitas pelo mempool, para que elas sobrevivam
+// a um reinício do nó. Cada registro é [tamanho uint32][transação codificada em gob].
+//
+// O uso esperado é: Load na inicialização (revalidando cada transação), Rotate para compactar o
+// arquivo com o conteúdo atual do pool, Insert a cada transação aceita e Rotate periodicamente.
+type TxJournal struct {
+	path string
+
+	lock   sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+}
+
+func NewTxJournal(path string) *TxJournal {
+	return &TxJournal{path: path}
+}
+
+// Lê as transações do journal e as passa para add. Transações que add recusa (ex: já incluídas
+// em um bloco ou com assinatura inválida) são descartadas e contadas em dropped.
+// Um registro incompleto no fim do arquivo (ex: o nó parou no meio de uma escrita) é ignorado.
+func (j *TxJournal) Load(add func(*core.Transaction) error) (loaded, dropped int, err error) {
+	f, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		tx, err := readJournalRecord(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return loaded, dropped, nil
+		}
+		if err != nil {
+			return loaded, dropped, err
+		}
+
+		if err := add(tx); err != nil {
+			dropped++
+			continue
+		}
+		loaded++
+	}
+}
+
+// Acrescenta uma transação ao journal. O registro é gravado no arquivo antes de retornar.
+func (j *TxJournal) Insert(tx *core.Transaction) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.writer == nil {
+		return ErrJournalClosed
+	}
+	if err := writeJournalRecord(j.writer, tx); err != nil {
+		return err
+	}
+	return j.writer.Flush()
+}
+
+// Reescreve o journal apenas com as transações informadas e o deixa aberto para novos registros.
+// O arquivo novo é escrito ao lado do atual, gravado em disco (Sync) e renomeado por cima dele,
+// assim uma falha no meio da compactação não perde o journal anterior. Até o rename dar certo,
+// o arquivo anterior continua aberto e recebendo os novos registros.
+func (j *TxJournal) Rotate(txx []*core.Transaction) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	tmp := j.path + ".new"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if err := writeJournal(f, txx); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	// O descritor segue o arquivo no rename: ele já é o journal aberto para os próximos registros.
+	if err := os.Rename(tmp, j.path); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	j.close()
+	j.file = f
+	j.writer = bufio.NewWriter(f)
+
+	return nil
+}
+
+func writeJournal(f *os.File, txx []*core.Transaction) error {
+	w := bufio.NewWriter(f)
+	for _, tx := range txx {
+		if err := writeJournalRecord(w, tx); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Fecha o arquivo do journal.
+func (j *TxJournal) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return j.close()
+}
+
+// Deve ser chamado com j.lock travado.
+func (j *TxJournal) close() error {
+	if j.file == nil {
+		return nil
+	}
+
+	err := j.writer.Flush()
+	if cerr := j.file.Close(); err == nil {
+		err = cerr
+	}
+	j.file, j.writer = nil, nil
+
+	return err
+}
+
+func writeJournalRecord(w io.Writer, tx *core.Transaction) error {
+	buf := &bytes.Buffer{}
+	if err := tx.Encode(core.NewGobTxEncoder(buf)); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, binary.BigEndian, uint32(buf.Len())); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func readJournalRecord(r io.Reader) (*core.Transaction, error) {
+	var size uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if int(size) > maxJournalRecord {
+		return nil, fmt.Errorf("journal record of %d bytes exceeds the limit of %d", size, maxJournalRecord)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	tx := new(core.Transaction)
+	if err := tx.Decode(core.NewGobTxDecoder(bytes.NewReader(data))); err != nil {
+		return nil, fmt.Errorf("could not decode journal record: %w", err)
+	}
+	return tx, nil
+}
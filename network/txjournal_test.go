@@ -0,0 +1,130 @@
+package network
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FelipePn10/fadden/core"
+	"github.com/FelipePn10/fadden/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxJournalInsertAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.journal")
+	j := NewTxJournal(path)
+
+	assert.ErrorIs(t, j.Insert(randomTx(t, "closed")), ErrJournalClosed)
+
+	// Carregar um journal inexistente não é erro.
+	loaded, dropped, err := j.Load(func(*core.Transaction) error { return nil })
+	assert.Nil(t, err)
+	assert.Equal(t, 0, loaded+dropped)
+
+	txx := []*core.Transaction{randomTx(t, "a"), randomTx(t, "b"), randomTx(t, "c")}
+	assert.Nil(t, j.Rotate(txx[:1]))
+	assert.Nil(t, j.Insert(txx[1]))
+	assert.Nil(t, j.Insert(txx[2]))
+	assert.Nil(t, j.Close())
+
+	var got []*core.Transaction
+	loaded, dropped, err = NewTxJournal(path).Load(func(tx *core.Transaction) error {
+		if string(tx.Data) == "b" {
+			return errors.New("rejected")
+		}
+		got = append(got, tx)
+		return tx.Verify()
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, loaded)
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, txx[0].Hash(core.TxHasher{}), got[0].Hash(core.TxHasher{}))
+	assert.Equal(t, txx[2].Hash(core.TxHasher{}), got[1].Hash(core.TxHasher{}))
+}
+
+func TestTxJournalRotateCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.journal")
+	j := NewTxJournal(path)
+	defer j.Close()
+
+	assert.Nil(t, j.Rotate(nil))
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, j.Insert(randomTx(t, "tx")))
+	}
+	before, _ := os.Stat(path)
+
+	assert.Nil(t, j.Rotate([]*core.Transaction{randomTx(t, "kept")}))
+	after, _ := os.Stat(path)
+	assert.Less(t, after.Size(), before.Size())
+
+	loaded, _, err := NewTxJournal(path).Load(func(*core.Transaction) error { return nil })
+	assert.Nil(t, err)
+	assert.Equal(t, 1, loaded)
+}
+
+func TestTxJournalKeepsWriterWhenRotateFails(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "transactions.journal")
+	j := NewTxJournal(path)
+	defer j.Close()
+	assert.Nil(t, j.Rotate([]*core.Transaction{randomTx(t, "a")}))
+
+	// Um diretório no lugar do journal faz o rename falhar; o arquivo aberto continua em old.
+	old := filepath.Join(dir, "old.journal")
+	assert.Nil(t, os.Rename(path, old))
+	assert.Nil(t, os.MkdirAll(filepath.Join(path, "busy"), 0o755))
+
+	assert.NotNil(t, j.Rotate(nil))
+	assert.Nil(t, j.Insert(randomTx(t, "b")))
+	_, err := os.Stat(path + ".new")
+	assert.True(t, os.IsNotExist(err))
+
+	loaded, _, err := NewTxJournal(old).Load(func(*core.Transaction) error { return nil })
+	assert.Nil(t, err)
+	assert.Equal(t, 2, loaded)
+}
+
+func TestTxJournalIgnoresTruncatedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.journal")
+	j := NewTxJournal(path)
+	assert.Nil(t, j.Rotate([]*core.Transaction{randomTx(t, "a"), randomTx(t, "b")}))
+	assert.Nil(t, j.Close())
+
+	info, _ := os.Stat(path)
+	assert.Nil(t, os.Truncate(path, info.Size()-3))
+
+	loaded, _, err := NewTxJournal(path).Load(func(*core.Transaction) error { return nil })
+	assert.Nil(t, err)
+	assert.Equal(t, 1, loaded)
+}
+
+func TestServerJournalSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.journal")
+	tra, trb := connectedTransports(t, "A", "B")
+
+	s := NewServer(ServerOpts{Transports: []Trasport{tra}, JournalPath: path})
+	done := make(chan struct{})
+	go func() {
+		s.Start()
+		close(done)
+	}()
+
+	tx := core.NewTransaction([]byte("survives"))
+	assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+	payload, err := encodeGob(tx)
+	assert.Nil(t, err)
+	assert.Nil(t, trb.SendMessage(tra.Addr(), NewMessage(MessageTypeTx, payload).Bytes()))
+
+	assert.Eventually(t, func() bool {
+		return s.memPool.Has(tx.Hash(core.TxHasher{}))
+	}, time.Second, 5*time.Millisecond)
+	s.quitCh <- struct{}{}
+	<-done
+
+	restarted := startServer(t, ServerOpts{JournalPath: path})
+	assert.Eventually(t, func() bool {
+		return restarted.memPool.Has(tx.Hash(core.TxHasher{}))
+	}, time.Second, 5*time.Millisecond)
+}
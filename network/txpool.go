@@ -1,80 +1,687 @@
 package network
 
 import (
+	"container/heap"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
 	"sort"
+	"sync"
+	"time"
 
 	"github.com/FelipePn10/fadden/core"
 	"github.com/FelipePn10/fadden/types"
+	"github.com/sirupsen/logrus"
 )
 
-type TxMapSorter struct {
-	transactions []*core.Transaction
+var (
+	defaultMaxPoolCount   = 4096
+	defaultMaxPoolBytes   = 32 << 20
+	defaultMinReplaceBump = 10
+)
+
+var (
+	ErrTxPoolFull         = errors.New("transaction pool is full")
+	ErrSenderLimit        = errors.New("sender has too many transactions in the pool")
+	ErrTxTooLarge         = errors.New("transaction is larger than the pool")
+	ErrReplaceUnderpriced = errors.New("replacement transaction underpriced")
+	ErrNonceTooLow        = errors.New("nonce too low")
+	ErrTxExpired          = errors.New("transaction expired")
+	ErrInsufficientWork   = errors.New("insufficient proof of work")
+)
+
+// NonceSource informa o próximo nonce esperado para cada remetente (ex: core.Blockchain).
+type NonceSource interface {
+	Nonce(types.Address) uint64
 }
 
-func NewTxMapSorter(txMap map[types.Hash]*core.Transaction) *TxMapSorter {
-	txx := make([]*core.Transaction, len(txMap))
+// EvictionPolicy define qual transação sai do pool quando ele está cheio.
+type EvictionPolicy byte
 
-	i := 0
-	for _, val := range txMap {
-		txx[i] = val
-		i++
-	}
-	s := &TxMapSorter{txx}
+const (
+	EvictOldest         EvictionPolicy = iota // Remove a transação vista há mais tempo
+	EvictLowestPriority                       // Remove a transação de menor prioridade (empate: a mais antiga)
+)
 
-	sort.Sort(s)
-	return s
-}
+// TxPoolOpts define os limites do pool de transações.
+// MaxCount e MaxBytes limitam o número e o tamanho total das transações (padrão 4096 e 32MB).
+// MaxPerSender limita as transações de um mesmo remetente (0 = sem limite). Transações sem
+// assinatura não têm remetente e não entram nesse limite.
+// Priority calcula a prioridade usada por EvictLowestPriority (padrão: a taxa por byte, ver FeeRatePriority).
+// MinReplaceBump é o aumento mínimo da taxa, em porcentagem, para substituir uma transação
+// pendente com o mesmo remetente e nonce (padrão 10%).
+// Nonces informa o nonce atual de cada conta. Sem ele, o pool acompanha os nonces pelos blocos
+// passados a RemoveIncluded.
+// WorkBits é a prova de trabalho mínima exigida para entrar no pool (0 = sem exigência, ver
+// core.Transaction.Mine). Com o pool cheio exige-se até CongestionWorkBits bits a mais,
+// proporcionalmente à ocupação.
+type TxPoolOpts struct {
+	MaxCount       int
+	MaxBytes       int
+	MaxPerSender   int
+	Eviction       EvictionPolicy
+	Priority       func(*core.Transaction) int64
+	MinReplaceBump int
+	Nonces         NonceSource
 
-func (s *TxMapSorter) Len() int { return len(s.transactions) }
-func (s *TxMapSorter) Swap(i, j int) {
-	s.transactions[i], s.transactions[j] = s.transactions[j], s.transactions[i]
+	WorkBits           int
+	CongestionWorkBits int
 }
-func (s *TxMapSorter) Less(i, j int) bool {
-	return s.transactions[i].FirstSeen() < s.transactions[j].FirstSeen()
+
+// poolEntry: Transação no pool com os dados calculados na entrada.
+type poolEntry struct {
+	tx       *core.Transaction
+	size     int
+	sender   types.Address
+	priority int64
 }
 
 // Deefinimos um mapa onde armazena transações, onde a chave é um type.Hash e o valor é
-// é um ponteiro para uma transação.
+// é a transação com seus metadados. Todos os métodos são seguros para uso concorrente.
+//
+// As transações assinadas ficam em uma de duas filas por remetente: pendentes (executáveis), que formam
+// uma sequência sem lacunas a partir do nonce atual da conta, e enfileiradas, com nonce futuro.
+// Quando a lacuna é preenchida as enfileiradas são promovidas. Transações sem remetente são sempre pendentes.
 type TxPool struct {
-	transactions map[types.Hash]*core.Transaction
+	opts TxPoolOpts
+
+	lock         sync.RWMutex
+	transactions map[types.Hash]*poolEntry
+	senders      map[types.Address]int                   // Número de transações de cada remetente
+	nonces       map[types.Address]map[uint64]types.Hash // Transação de cada nonce de cada remetente
+	pending      map[types.Address]uint64                // Primeiro nonce depois das pendentes de cada remetente
+	accounts     map[types.Address]uint64                // Nonces vistos nos blocos, usados sem NonceSource
+	bytes        int                                     // Tamanho total das transações
+	height       uint32                                  // Altura do último bloco visto
+	timestamp    uint64                                  // Timestamp do último bloco visto
 }
 
-// Esta função inicializa o pool de transações e retorna um novo.
-// Ele cria um novo mapa vazio para transactions e retorna um ponteiro para a estrutura Txpool récem-criada.
+// Esta função inicializa o pool de transações com os limites padrão e retorna um novo.
 func NewTxPool() *TxPool {
+	return NewTxPoolWithOpts(TxPoolOpts{})
+}
+
+// Inicializa um pool de transações com os limites informados.
+// Ele cria um novo mapa vazio para transactions e retorna um ponteiro para a estrutura Txpool récem-criada.
+func NewTxPoolWithOpts(opts TxPoolOpts) *TxPool {
+	if opts.MaxCount <= 0 {
+		opts.MaxCount = defaultMaxPoolCount
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultMaxPoolBytes
+	}
+	if opts.Priority == nil {
+		opts.Priority = FeeRatePriority
+	}
+	if opts.MinReplaceBump <= 0 {
+		opts.MinReplaceBump = defaultMinReplaceBump
+	}
+
 	return &TxPool{
-		transactions: make(map[types.Hash]*core.Transaction),
+		opts:         opts,
+		transactions: make(map[types.Hash]*poolEntry),
+		senders:      make(map[types.Address]int),
+		nonces:       make(map[types.Address]map[uint64]types.Hash),
+		pending:      make(map[types.Address]uint64),
+		accounts:     make(map[types.Address]uint64),
 	}
 }
 
+// Retorna todas as transações do pool, pendentes e enfileiradas: maior taxa por byte primeiro,
+// sem nunca colocar uma transação antes de outra do mesmo remetente com nonce menor.
+// Empates são resolvidos pela primeira vez em que a transação foi vista.
 func (p *TxPool) Transactions() []*core.Transaction {
-	s := NewTxMapSorter(p.transactions)
-	return s.transactions
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.ordered(func(*poolEntry) bool { return true })
+}
+
+// Retorna as transações pendentes na ordem em que devem entrar em um bloco (ver Transactions).
+func (p *TxPool) Pending() []*core.Transaction {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.ordered(p.isPending)
+}
+
+// Retorna as transações enfileiradas, que esperam transações de nonce menor do mesmo remetente.
+func (p *TxPool) Queued() []*core.Transaction {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.ordered(func(e *poolEntry) bool { return !p.isPending(e) })
+}
+
+// Ordena as transações aceitas por filter. Deve ser chamado com p.lock travado.
+func (p *TxPool) ordered(filter func(*poolEntry) bool) []*core.Transaction {
+	bySender := make(map[types.Address][]*poolEntry)
+	for _, e := range p.transactions {
+		if filter(e) {
+			bySender[e.sender] = append(bySender[e.sender], e)
+		}
+	}
+
+	h := &senderHeap{}
+	for _, entries := range bySender {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].tx.Nonce != entries[j].tx.Nonce {
+				return entries[i].tx.Nonce < entries[j].tx.Nonce
+			}
+			return entries[i].tx.FirstSeen() < entries[j].tx.FirstSeen()
+		})
+		*h = append(*h, entries)
+	}
+	heap.Init(h)
+
+	txx := make([]*core.Transaction, 0, len(p.transactions))
+	for h.Len() > 0 {
+		entries := (*h)[0]
+		txx = append(txx, entries[0].tx)
+
+		if len(entries) == 1 {
+			heap.Pop(h)
+			continue
+		}
+		(*h)[0] = entries[1:]
+		heap.Fix(h, 0)
+	}
+
+	return txx
+}
+
+// senderHeap: Heap com as transações restantes de cada remetente, ordenado pela primeira delas.
+type senderHeap [][]*poolEntry
+
+func (h senderHeap) Len() int { return len(h) }
+func (h senderHeap) Less(i, j int) bool {
+	a, b := h[i][0], h[j][0]
+	if c := compareFeeRate(a, b); c != 0 {
+		return c > 0
+	}
+	return a.tx.FirstSeen() < b.tx.FirstSeen()
+}
+func (h senderHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *senderHeap) Push(x any)   { *h = append(*h, x.([]*poolEntry)) }
+func (h *senderHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// Compara a taxa por byte de duas transações sem perder precisão:
+// fee_a/size_a > fee_b/size_b  <=>  fee_a*size_b > fee_b*size_a (com multiplicação de 128 bits).
+func compareFeeRate(a, b *poolEntry) int {
+	ahi, alo := bits.Mul64(a.tx.Fee, uint64(b.size))
+	bhi, blo := bits.Mul64(b.tx.Fee, uint64(a.size))
+
+	switch {
+	case ahi > bhi || (ahi == bhi && alo > blo):
+		return 1
+	case ahi < bhi || (ahi == bhi && alo < blo):
+		return -1
+	}
+	return 0
 }
 
-// Adiciona uma transação ao pool de transações
+// Taxa por byte da transação, em unidades de taxa.
+func FeeRate(tx *core.Transaction) float64 {
+	return float64(tx.Fee) / float64(txSize(tx))
+}
+
+// Prioridade padrão do pool: a taxa por byte em milésimos de unidade.
+func FeeRatePriority(tx *core.Transaction) int64 {
+	return int64(math.Min(FeeRate(tx)*1000, math.MaxInt64))
+}
+
+// Adiciona uma transação ao pool de transações.
+// Se o pool estiver cheio, transações são removidas segundo a EvictionPolicy para abrir espaço;
+// se a transação nova for a que seria removida, ela é recusada com ErrTxPoolFull.
 func (p *TxPool) Add(tx *core.Transaction) error {
+	e := p.newEntry(tx)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.add(e)
+}
+
+// Calcula os dados da transação usados pelo pool, fora do lock.
+func (p *TxPool) newEntry(tx *core.Transaction) *poolEntry {
+	if tx.FirstSeen() == 0 {
+		tx.SetFirstSeen(time.Now().UnixNano())
+	}
+
+	return &poolEntry{
+		tx:       tx,
+		size:     txSize(tx),
+		sender:   txSender(tx),
+		priority: p.opts.Priority(tx),
+	}
+}
+
+// Deve ser chamado com p.lock travado.
+func (p *TxPool) add(e *poolEntry) error {
+	tx := e.tx
 	hash := tx.Hash(core.TxHasher{}) // Calcula o hash da transação. Isso gera um ID p/ a transação
-	if p.Has(hash) {                 // Verifica se a transação já existe, se existir retorna nada
+	if e.size > p.opts.MaxBytes {
+		return ErrTxTooLarge
+	}
+
+	if _, ok := p.transactions[hash]; ok { // Verifica se a transação já existe, se existir retorna nada
 		return nil
 	}
-	p.transactions[hash] = tx // Se a transação não estiver no pool, ela é adicionada ao mapa transactions usando hash como chave
+
+	if required := p.requiredWorkBits(); required > 0 && tx.WorkBits() < required {
+		return fmt.Errorf("%w: got %d bits, required %d", ErrInsufficientWork, tx.WorkBits(), required)
+	}
+
+	if tx.Expired(p.height+1, max(p.timestamp, uint64(time.Now().UnixNano()))) {
+		return fmt.Errorf("%w: valid until height %d and timestamp %d", ErrTxExpired, tx.ValidUntilHeight, tx.Deadline)
+	}
+
+	if e.sender != (types.Address{}) && tx.Nonce < p.accountNonce(e.sender) {
+		return fmt.Errorf("%w: got %d, account is at %d", ErrNonceTooLow, tx.Nonce, p.accountNonce(e.sender))
+	}
+
+	// Uma transação com o mesmo remetente e nonce de outra já no pool só entra substituindo-a,
+	// e apenas se pagar pelo menos MinReplaceBump% a mais de taxa.
+	var replaced *poolEntry
+	if hash, ok := p.nonces[e.sender][tx.Nonce]; ok && e.sender != (types.Address{}) {
+		replaced = p.transactions[hash]
+		if tx.Fee <= replaced.tx.Fee || tx.Fee < minReplacementFee(replaced.tx.Fee, p.opts.MinReplaceBump) {
+			return fmt.Errorf("%w: fee %d, pending transaction (%s) pays %d", ErrReplaceUnderpriced, tx.Fee, hash, replaced.tx.Fee)
+		}
+	} else if p.opts.MaxPerSender > 0 && e.sender != (types.Address{}) && p.senders[e.sender] >= p.opts.MaxPerSender {
+		return fmt.Errorf("%w: %s", ErrSenderLimit, e.sender)
+	}
+
+	// O pool só é alterado depois que há espaço garantido: se faltar espaço, a transação
+	// substituída continua no pool.
+	victims, err := p.victims(e, replaced)
+	if err != nil {
+		return err
+	}
+	if replaced != nil {
+		p.remove(replaced.tx.Hash(core.TxHasher{}))
+	}
+	for _, victim := range victims {
+		p.remove(victim)
+	}
+
+	// Se a transação não estiver no pool, ela é adicionada ao mapa transactions usando hash como chave
+	p.insert(e)
+
 	return nil
 }
 
+// Deve ser chamado com p.lock travado.
+func (p *TxPool) insert(e *poolEntry) {
+	hash := e.tx.Hash(core.TxHasher{})
+
+	p.transactions[hash] = e
+	p.senders[e.sender]++
+	p.bytes += e.size
+	if e.sender != (types.Address{}) {
+		if p.nonces[e.sender] == nil {
+			p.nonces[e.sender] = make(map[uint64]types.Hash)
+		}
+		p.nonces[e.sender][e.tx.Nonce] = hash
+		p.promote(e.sender)
+	}
+}
+
+// Retorna quantos bits de prova de trabalho uma transação precisa para entrar no pool agora.
+func (p *TxPool) RequiredWorkBits() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.requiredWorkBits()
+}
+
+// Deve ser chamado com p.lock travado.
+func (p *TxPool) requiredWorkBits() int {
+	if p.opts.WorkBits <= 0 && p.opts.CongestionWorkBits <= 0 {
+		return 0
+	}
+
+	fill := max(float64(len(p.transactions))/float64(p.opts.MaxCount), float64(p.bytes)/float64(p.opts.MaxBytes))
+	required := p.opts.WorkBits + int(math.Min(fill, 1)*float64(p.opts.CongestionWorkBits))
+	return min(required, core.MaxWorkBits)
+}
+
+// Nonce atual da conta. Deve ser chamado com p.lock travado.
+func (p *TxPool) accountNonce(sender types.Address) uint64 {
+	if p.opts.Nonces != nil {
+		return p.opts.Nonces.Nonce(sender)
+	}
+	return p.accounts[sender]
+}
+
+// Primeiro nonce depois das transações pendentes do remetente. Deve ser chamado com p.lock travado.
+func (p *TxPool) pendingNonce(sender types.Address) uint64 {
+	if n, ok := p.pending[sender]; ok {
+		return n
+	}
+	return p.accountNonce(sender)
+}
+
+// Verifica se a transação é executável. Como nenhuma transação do pool tem o nonce igual a
+// pendingNonce (é a lacuna), a comparação também vale para uma transação que ainda vai entrar.
+// Deve ser chamado com p.lock travado.
+func (p *TxPool) isPending(e *poolEntry) bool {
+	return e.sender == (types.Address{}) || e.tx.Nonce <= p.pendingNonce(e.sender)
+}
+
+// Promove as transações enfileiradas do remetente que deixaram de ter lacunas antes delas.
+// Deve ser chamado com p.lock travado.
+func (p *TxPool) promote(sender types.Address) {
+	n := p.pendingNonce(sender)
+	for {
+		if _, ok := p.nonces[sender][n]; !ok {
+			break
+		}
+		n++
+	}
+	p.pending[sender] = n
+}
+
+// Menor taxa aceita para substituir uma transação que paga fee.
+func minReplacementFee(fee uint64, bump int) uint64 {
+	hi, lo := bits.Mul64(fee, uint64(100+bump))
+	if hi > 0 {
+		return math.MaxUint64
+	}
+	return lo / 100
+}
+
+// Escolhe as transações que precisam sair para que e caiba no pool. A transação que e substitui
+// (replaced, se houver) já conta como removida. Deve ser chamado com p.lock travado.
+func (p *TxPool) victims(e, replaced *poolEntry) ([]types.Hash, error) {
+	count, size := len(p.transactions)+1, p.bytes+e.size
+	if replaced != nil {
+		count, size = count-1, size-replaced.size
+	}
+	if count <= p.opts.MaxCount && size <= p.opts.MaxBytes {
+		return nil, nil
+	}
+
+	entries := make([]*poolEntry, 0, len(p.transactions))
+	for _, other := range p.transactions {
+		if other != replaced {
+			entries = append(entries, other)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return p.evictBefore(entries[i], entries[j])
+	})
+
+	var victims []types.Hash
+	for _, victim := range entries {
+		if count <= p.opts.MaxCount && size <= p.opts.MaxBytes {
+			break
+		}
+		if !p.evictBefore(victim, e) {
+			return nil, ErrTxPoolFull
+		}
+
+		victims = append(victims, victim.tx.Hash(core.TxHasher{}))
+		count--
+		size -= victim.size
+	}
+
+	return victims, nil
+}
+
+// Verifica se a deve ser removida do pool antes de b. Transações enfileiradas saem antes das pendentes.
+func (p *TxPool) evictBefore(a, b *poolEntry) bool {
+	if pa, pb := p.isPending(a), p.isPending(b); pa != pb {
+		return !pa
+	}
+	if p.opts.Eviction == EvictLowestPriority && a.priority != b.priority {
+		return a.priority < b.priority
+	}
+	return a.tx.FirstSeen() < b.tx.FirstSeen()
+}
+
+// Remove uma transação do pool. Retorna false se ela não estava no pool.
+func (p *TxPool) Remove(hash types.Hash) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.remove(hash)
+}
+
+// Remove do pool as transações incluídas no bloco e retorna quantas foram removidas.
+// Em seguida descarta as transações que ficaram com nonce abaixo do nonce atual da conta
+// (ex: outras transações com o mesmo nonce das incluídas) e recalcula as pendentes.
+func (p *TxPool) RemoveIncluded(b *core.Block) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	removed := p.removeIncluded(b)
+	p.advanceTip(b)
+	p.pruneStale()
+	p.pruneExpired()
+
+	return removed
+}
+
+// Atualiza o pool depois de uma mudança na cadeia canônica (ver core.Blockchain.Subscribe).
+// As transações incluídas nos blocos novos saem do pool. As dos blocos abandonados voltam,
+// a menos que também estejam no novo ramo, e são revalidadas: uma que conflite com o novo
+// ramo (ex: outra transação do mesmo remetente e nonce foi incluída) é descartada.
+func (p *TxPool) HandleChainEvent(ev core.ChainEvent) {
+	included := make(map[types.Hash]bool)
+	for _, b := range ev.Added {
+		for i := range b.Transactions {
+			included[b.Transactions[i].Hash(core.TxHasher{})] = true
+		}
+	}
+
+	var orphaned []*poolEntry
+	for _, b := range ev.Removed {
+		for i := range b.Transactions {
+			tx := b.Transactions[i]
+			if included[tx.Hash(core.TxHasher{})] || tx.Verify() != nil {
+				continue
+			}
+			orphaned = append(orphaned, p.newEntry(&tx))
+		}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// Sem NonceSource, os nonces das contas voltam para antes das transações abandonadas.
+	for _, b := range ev.Removed {
+		for i := range b.Transactions {
+			tx := &b.Transactions[i]
+			if sender := txSender(tx); sender != (types.Address{}) && tx.Nonce < p.accounts[sender] {
+				p.accounts[sender] = tx.Nonce
+			}
+		}
+	}
+	for _, b := range ev.Added {
+		p.removeIncluded(b)
+	}
+	if len(ev.Added) > 0 {
+		tip := ev.Added[len(ev.Added)-1]
+		p.height, p.timestamp = tip.Height, tip.Timestamp
+	}
+	p.pruneStale()
+	p.pruneExpired()
+
+	for _, e := range orphaned {
+		if err := p.add(e); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"hash": e.tx.Hash(core.TxHasher{}),
+			}).Info("dropping transaction from orphaned block: ", err)
+		}
+	}
+}
+
+// Remove as transações do bloco e avança os nonces das contas. Deve ser chamado com p.lock travado.
+func (p *TxPool) removeIncluded(b *core.Block) int {
+	removed := 0
+	for i := range b.Transactions {
+		tx := &b.Transactions[i]
+		if p.remove(tx.Hash(core.TxHasher{})) {
+			removed++
+		}
+		if sender := txSender(tx); sender != (types.Address{}) && tx.Nonce >= p.accounts[sender] {
+			p.accounts[sender] = tx.Nonce + 1
+		}
+	}
+	return removed
+}
+
+// Registra o bloco como a ponta da cadeia, se ele for mais alto que o último visto.
+// Deve ser chamado com p.lock travado.
+func (p *TxPool) advanceTip(b *core.Block) {
+	if b.Height >= p.height {
+		p.height, p.timestamp = b.Height, b.Timestamp
+	}
+}
+
+// Descarta as transações que não podem mais entrar no próximo bloco por terem expirado.
+// Deve ser chamado com p.lock travado.
+func (p *TxPool) pruneExpired() {
+	for hash, e := range p.transactions {
+		if e.tx.Expired(p.height+1, p.timestamp) {
+			p.remove(hash)
+		}
+	}
+}
+
+// Descarta as transações com nonce abaixo do nonce atual da conta e recalcula as pendentes.
+// Deve ser chamado com p.lock travado.
+func (p *TxPool) pruneStale() {
+	for sender, nonces := range p.nonces {
+		base := p.accountNonce(sender)
+		for nonce, hash := range nonces {
+			if nonce < base {
+				p.remove(hash)
+			}
+		}
+		if _, ok := p.nonces[sender]; ok {
+			delete(p.pending, sender)
+			p.promote(sender)
+		}
+	}
+}
+
+// Deve ser chamado com p.lock travado.
+func (p *TxPool) remove(hash types.Hash) bool {
+	e, ok := p.transactions[hash]
+	if !ok {
+		return false
+	}
+
+	delete(p.transactions, hash)
+	p.bytes -= e.size
+	if p.senders[e.sender]--; p.senders[e.sender] == 0 {
+		delete(p.senders, e.sender)
+	}
+	if nonces := p.nonces[e.sender]; nonces[e.tx.Nonce] == hash {
+		// Remover uma pendente abre uma lacuna: as de nonce maior voltam a ser enfileiradas.
+		if e.tx.Nonce < p.pending[e.sender] {
+			p.pending[e.sender] = e.tx.Nonce
+		}
+		if delete(nonces, e.tx.Nonce); len(nonces) == 0 {
+			delete(p.nonces, e.sender)
+			delete(p.pending, e.sender)
+		}
+	}
+
+	return true
+}
+
 // Verifica se uma transação com um determinado hash já existe no pool. ELe faz isso verificando se o hash está presente no mapa transactions, retornando true se estiver ou false.
 func (p *TxPool) Has(hash types.Hash) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
 	_, ok := p.transactions[hash]
 	return ok
 }
 
+// Retorna a transação com o hash informado, se ela estiver no pool.
+func (p *TxPool) Get(hash types.Hash) (*core.Transaction, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	e, ok := p.transactions[hash]
+	if !ok {
+		return nil, false
+	}
+	return e.tx, true
+}
+
 // Retorna o número de transações atualmente no pool. (tamanho do mapa transactions)
 func (p *TxPool) Len() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
 	return len(p.transactions)
 }
 
+// Retorna o tamanho total, em bytes, das transações no pool.
+func (p *TxPool) Bytes() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.bytes
+}
+
 // FLush limpa o pool de transações, removendo todas as transações.
 // Ele faz isso criando um novo mapa vazio e atribuindo-o a transactions, efetivamente descartando todas as transações anteriores.
 func (p *TxPool) Flush() {
-	p.transactions = make(map[types.Hash]*core.Transaction)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.transactions = make(map[types.Hash]*poolEntry)
+	p.senders = make(map[types.Address]int)
+	p.nonces = make(map[types.Address]map[uint64]types.Hash)
+	p.pending = make(map[types.Address]uint64)
+	p.bytes = 0
+}
+
+// Tamanho da transação serializada, usado para o limite de bytes do pool e para a taxa por byte.
+// O gob escreve a descrição dos tipos na primeira mensagem de cada encoder; ela é descartada
+// codificando antes uma transação vazia, para que o tamanho conte apenas a transação.
+func txSize(tx *core.Transaction) int {
+	w := &countingWriter{}
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(new(core.Transaction)); err != nil {
+		return len(tx.Data)
+	}
+
+	w.n = 0
+	if err := enc.Encode(tx); err != nil {
+		return len(tx.Data)
+	}
+	return w.n
+}
+
+// Endereço do remetente da transação (ver Transaction.SenderAddress). Transações não assinadas usam o endereço zero.
+func txSender(tx *core.Transaction) types.Address {
+	addr, err := tx.SenderAddress()
+	if err != nil {
+		return types.Address{}
+	}
+	return addr
+}
+
+// countingWriter descarta os bytes escritos, contando apenas quantos foram.
+type countingWriter struct {
+	n int
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.n += len(b)
+	return len(b), nil
 }
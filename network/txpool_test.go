@@ -1,11 +1,18 @@
 package network
 
 import (
+	"context"
+	"fmt"
 	"math/rand"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/FelipePn10/fadden/core"
+	"github.com/FelipePn10/fadden/crypto"
+	"github.com/FelipePn10/fadden/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -39,8 +46,503 @@ func TestSortTrasactions(t *testing.T) {
 	assert.Equal(t, txLen, p.Len())
 
 	txx := p.Transactions()
-	for i := 0; i < len(txx); i++ {
+	for i := 0; i < len(txx)-1; i++ {
 		assert.True(t, txx[i].FirstSeen() <= txx[i+1].FirstSeen())
 	}
 
 }
+
+func TestTxPoolConcurrentAccess(t *testing.T) {
+	p := NewTxPool()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				tx := core.NewTransaction([]byte(fmt.Sprintf("%d-%d", i, j)))
+				assert.Nil(t, p.Add(tx))
+				p.Has(tx.Hash(core.TxHasher{}))
+				p.Transactions()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 800, p.Len())
+}
+
+func TestTxPoolEvictOldest(t *testing.T) {
+	p := NewTxPoolWithOpts(TxPoolOpts{MaxCount: 3})
+
+	txx := make([]*core.Transaction, 4)
+	for i := range txx {
+		txx[i] = core.NewTransaction([]byte(strconv.Itoa(i)))
+		txx[i].SetFirstSeen(int64(i + 1))
+		assert.Nil(t, p.Add(txx[i]))
+	}
+
+	assert.Equal(t, 3, p.Len())
+	assert.False(t, p.Has(txx[0].Hash(core.TxHasher{})))
+	assert.True(t, p.Has(txx[3].Hash(core.TxHasher{})))
+}
+
+func TestTxPoolEvictLowestPriority(t *testing.T) {
+	p := NewTxPoolWithOpts(TxPoolOpts{
+		MaxCount: 2,
+		Eviction: EvictLowestPriority,
+		Priority: func(tx *core.Transaction) int64 { return int64(len(tx.Data)) },
+	})
+
+	low := core.NewTransaction([]byte("a"))
+	high := core.NewTransaction([]byte("aaaa"))
+	mid := core.NewTransaction([]byte("aaa"))
+	assert.Nil(t, p.Add(low))
+	assert.Nil(t, p.Add(high))
+	assert.Nil(t, p.Add(mid))
+
+	assert.False(t, p.Has(low.Hash(core.TxHasher{})))
+	assert.True(t, p.Has(mid.Hash(core.TxHasher{})))
+
+	// Uma transação de prioridade menor que todas as do pool é recusada.
+	assert.ErrorIs(t, p.Add(core.NewTransaction([]byte("b"))), ErrTxPoolFull)
+	assert.Equal(t, 2, p.Len())
+}
+
+func TestTxSizeExcludesGobTypes(t *testing.T) {
+	small := core.NewTransaction([]byte("a"))
+	large := core.NewTransaction(make([]byte, 100))
+
+	// Um encoder novo escreve a mesma descrição dos tipos para as duas: ela não entra no tamanho.
+	smallGob, err := encodeGob(small)
+	assert.Nil(t, err)
+	largeGob, err := encodeGob(large)
+	assert.Nil(t, err)
+
+	assert.Less(t, txSize(small), len(smallGob))
+	assert.Equal(t, len(smallGob)-txSize(small), len(largeGob)-txSize(large))
+}
+
+func TestTxPoolMaxBytes(t *testing.T) {
+	tx := core.NewTransaction(make([]byte, 100))
+	size := txSize(tx)
+
+	p := NewTxPoolWithOpts(TxPoolOpts{MaxBytes: size * 2})
+	assert.Nil(t, p.Add(tx))
+	assert.Equal(t, size, p.Bytes())
+
+	assert.ErrorIs(t, p.Add(core.NewTransaction(make([]byte, size*2))), ErrTxTooLarge)
+
+	for i := 1; i < 3; i++ {
+		tx := core.NewTransaction(append(make([]byte, 99), byte(i)))
+		assert.Nil(t, p.Add(tx))
+	}
+	assert.Equal(t, 2, p.Len())
+	assert.LessOrEqual(t, p.Bytes(), size*2)
+}
+
+func TestTxPoolSenderLimit(t *testing.T) {
+	p := NewTxPoolWithOpts(TxPoolOpts{MaxPerSender: 2})
+	privKey := crypto.GeneratePrivateKey()
+
+	for i := 0; i < 2; i++ {
+		tx := core.NewTransaction([]byte(strconv.Itoa(i)))
+		tx.Nonce = uint64(i)
+		assert.Nil(t, tx.Sign(privKey))
+		assert.Nil(t, p.Add(tx))
+	}
+
+	tx := core.NewTransaction([]byte("2"))
+	tx.Nonce = 2
+	assert.Nil(t, tx.Sign(privKey))
+	assert.ErrorIs(t, p.Add(tx), ErrSenderLimit)
+
+	other := core.NewTransaction([]byte("3"))
+	assert.Nil(t, other.Sign(crypto.GeneratePrivateKey()))
+	assert.Nil(t, p.Add(other))
+
+	// Transações sem assinatura não compartilham o limite de um remetente vazio.
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, p.Add(core.NewTransaction([]byte("unsigned-"+strconv.Itoa(i)))))
+	}
+	assert.Equal(t, 6, p.Len())
+}
+
+func TestTxPoolRemoveIncluded(t *testing.T) {
+	p := NewTxPool()
+	b := randomBlockWithTxs(t, 3)
+	for i := range b.Transactions {
+		assert.Nil(t, p.Add(&b.Transactions[i]))
+	}
+	pending := core.NewTransaction([]byte("pending"))
+	assert.Nil(t, p.Add(pending))
+
+	assert.Equal(t, 3, p.RemoveIncluded(b))
+	assert.Equal(t, 1, p.Len())
+	assert.True(t, p.Has(pending.Hash(core.TxHasher{})))
+	assert.Equal(t, txSize(pending), p.Bytes())
+}
+
+func TestTxPoolOrderByFeeRate(t *testing.T) {
+	p := NewTxPool()
+	for i, fee := range []uint64{10, 500, 50} {
+		tx := core.NewTransaction([]byte(strconv.Itoa(i)))
+		tx.Fee = fee
+		assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+		assert.Nil(t, p.Add(tx))
+	}
+
+	var fees []uint64
+	for _, tx := range p.Transactions() {
+		fees = append(fees, tx.Fee)
+	}
+	assert.Equal(t, []uint64{500, 50, 10}, fees)
+}
+
+func TestTxPoolKeepsSenderNonceOrder(t *testing.T) {
+	p := NewTxPool()
+	privKey := crypto.GeneratePrivateKey()
+
+	// A transação de nonce 1 paga mais, mas não pode vir antes da de nonce 0.
+	for _, n := range []uint64{1, 0} {
+		tx := core.NewTransaction([]byte(fmt.Sprintf("nonce-%d", n)))
+		tx.Nonce = n
+		tx.Fee = 100 + n*1000
+		assert.Nil(t, tx.Sign(privKey))
+		assert.Nil(t, p.Add(tx))
+	}
+	other := core.NewTransaction([]byte("other"))
+	other.Fee = 500
+	assert.Nil(t, other.Sign(crypto.GeneratePrivateKey()))
+	assert.Nil(t, p.Add(other))
+
+	txx := p.Transactions()
+	assert.Len(t, txx, 3)
+	assert.Equal(t, other, txx[0])
+	assert.Equal(t, uint64(0), txx[1].Nonce)
+	assert.Equal(t, uint64(1), txx[2].Nonce)
+}
+
+func TestTxPoolReplaceByFee(t *testing.T) {
+	p := NewTxPool()
+	privKey := crypto.GeneratePrivateKey()
+
+	newTx := func(data string, fee uint64) *core.Transaction {
+		tx := core.NewTransaction([]byte(data))
+		tx.Fee = fee
+		assert.Nil(t, tx.Sign(privKey))
+		return tx
+	}
+
+	orig := newTx("orig", 100)
+	assert.Nil(t, p.Add(orig))
+
+	assert.ErrorIs(t, p.Add(newTx("same", 100)), ErrReplaceUnderpriced)
+	assert.ErrorIs(t, p.Add(newTx("small-bump", 109)), ErrReplaceUnderpriced)
+	assert.True(t, p.Has(orig.Hash(core.TxHasher{})))
+
+	replacement := newTx("replacement", 110)
+	assert.Nil(t, p.Add(replacement))
+	assert.Equal(t, 1, p.Len())
+	assert.False(t, p.Has(orig.Hash(core.TxHasher{})))
+	assert.True(t, p.Has(replacement.Hash(core.TxHasher{})))
+	assert.Equal(t, txSize(replacement), p.Bytes())
+}
+
+func TestTxPoolReplaceByFeeSamePayload(t *testing.T) {
+	p := NewTxPool()
+	privKey := crypto.GeneratePrivateKey()
+
+	// Um aumento de taxa sem mudar os dados gera outra transação, que substitui a original.
+	orig := signedTx(t, privKey, "payload", 100)
+	bump := signedTx(t, privKey, "payload", 110)
+	assert.NotEqual(t, orig.Hash(core.TxHasher{}), bump.Hash(core.TxHasher{}))
+
+	assert.Nil(t, p.Add(orig))
+	assert.Nil(t, p.Add(bump))
+	assert.Equal(t, []*core.Transaction{bump}, p.Pending())
+}
+
+func TestTxPoolReplaceByFeeKeepsOriginalWhenFull(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	orig := signedTx(t, privKey, "orig", 100)
+	other := signedTx(t, crypto.GeneratePrivateKey(), "other", 1000)
+	p := NewTxPoolWithOpts(TxPoolOpts{
+		MaxBytes: txSize(orig) + txSize(other),
+		Eviction: EvictLowestPriority,
+		Priority: func(tx *core.Transaction) int64 { return int64(tx.Fee) },
+	})
+	assert.Nil(t, p.Add(orig))
+	assert.Nil(t, p.Add(other))
+
+	// A substituição é maior que a original e só caberia tirando other, que tem prioridade maior.
+	replacement := signedTx(t, privKey, strings.Repeat("x", 64), 200)
+	assert.ErrorIs(t, p.Add(replacement), ErrTxPoolFull)
+
+	assert.Equal(t, 2, p.Len())
+	assert.True(t, p.Has(orig.Hash(core.TxHasher{})))
+	assert.True(t, p.Has(other.Hash(core.TxHasher{})))
+	assert.Equal(t, txSize(orig)+txSize(other), p.Bytes())
+}
+
+func TestTxPoolReplaceByFeeWithoutFrom(t *testing.T) {
+	p := NewTxPool()
+	privKey := crypto.GeneratePrivateKey()
+
+	orig := signedTxWithNonce(t, privKey, 0, 100)
+	assert.Nil(t, p.Add(orig))
+
+	// Sem From, o remetente é recuperado da assinatura e a substituição continua valendo.
+	replacement := signedTxWithNonce(t, privKey, 0, 200)
+	replacement.OmitFrom()
+	assert.Nil(t, p.Add(replacement))
+	assert.Equal(t, []*core.Transaction{replacement}, p.Pending())
+}
+
+func TestTxPoolMultisigSender(t *testing.T) {
+	p := NewTxPool()
+	privKey := crypto.GeneratePrivateKey()
+	multisig, err := crypto.NewMultisig(1, []crypto.PublicKey{privKey.PublicKey()})
+	assert.Nil(t, err)
+
+	txx := make([]*core.Transaction, 2)
+	for i := range txx {
+		txx[i] = core.NewTransaction([]byte(fmt.Sprintf("multisig-%d", i)))
+		txx[i].Nonce = uint64(i)
+		assert.Nil(t, txx[i].SignMultisig(multisig, privKey))
+	}
+
+	// As transações da conta multisig seguem a sequência de nonces do endereço da conta.
+	assert.Nil(t, p.Add(txx[1]))
+	assert.Empty(t, p.Pending())
+	assert.Nil(t, p.Add(txx[0]))
+	assert.Equal(t, txx, p.Pending())
+}
+
+func TestTxPoolPendingAndQueued(t *testing.T) {
+	p := NewTxPool()
+	privKey := crypto.GeneratePrivateKey()
+
+	txx := make([]*core.Transaction, 4)
+	for i := range txx {
+		txx[i] = signedTxWithNonce(t, privKey, uint64(i), 0)
+	}
+
+	assert.Nil(t, p.Add(txx[0]))
+	assert.Nil(t, p.Add(txx[2]))
+	assert.Nil(t, p.Add(txx[3]))
+	assert.Equal(t, []*core.Transaction{txx[0]}, p.Pending())
+	assert.Equal(t, []*core.Transaction{txx[2], txx[3]}, p.Queued())
+
+	// Preencher a lacuna promove as transações enfileiradas.
+	assert.Nil(t, p.Add(txx[1]))
+	assert.Equal(t, txx, p.Pending())
+	assert.Empty(t, p.Queued())
+
+	// Remover uma pendente faz as seguintes voltarem para a fila.
+	p.Remove(txx[1].Hash(core.TxHasher{}))
+	assert.Equal(t, []*core.Transaction{txx[0]}, p.Pending())
+	assert.Equal(t, []*core.Transaction{txx[2], txx[3]}, p.Queued())
+}
+
+func TestTxPoolDropsStaleAfterBlock(t *testing.T) {
+	p := NewTxPool()
+	privKey := crypto.GeneratePrivateKey()
+
+	included := signedTxWithNonce(t, privKey, 0, 10)
+	conflicting := signedTxWithNonce(t, crypto.GeneratePrivateKey(), 0, 10)
+	assert.Nil(t, p.Add(conflicting))
+	next := signedTxWithNonce(t, privKey, 1, 10)
+	queued := signedTxWithNonce(t, privKey, 3, 10)
+	assert.Nil(t, p.Add(next))
+	assert.Nil(t, p.Add(queued))
+	assert.Equal(t, []*core.Transaction{conflicting}, p.Pending())
+
+	b := randomBlockWithTxs(t, 0)
+	b.AddTransaction(included)
+	assert.Equal(t, 0, p.RemoveIncluded(b))
+
+	assert.ElementsMatch(t, []*core.Transaction{conflicting, next}, p.Pending())
+	assert.Equal(t, []*core.Transaction{queued}, p.Queued())
+	assert.ErrorIs(t, p.Add(signedTxWithNonce(t, privKey, 0, 100)), ErrNonceTooLow)
+}
+
+type staticNonces map[types.Address]uint64
+
+func (n staticNonces) Nonce(addr types.Address) uint64 { return n[addr] }
+
+func TestTxPoolNonceSource(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	nonces := staticNonces{privKey.PublicKey().Address(): 5}
+	p := NewTxPoolWithOpts(TxPoolOpts{Nonces: nonces})
+
+	assert.ErrorIs(t, p.Add(signedTxWithNonce(t, privKey, 4, 0)), ErrNonceTooLow)
+	assert.Nil(t, p.Add(signedTxWithNonce(t, privKey, 5, 0)))
+	assert.Nil(t, p.Add(signedTxWithNonce(t, privKey, 7, 0)))
+	assert.Len(t, p.Pending(), 1)
+	assert.Len(t, p.Queued(), 1)
+
+	// A conta avançou fora do pool: o próximo bloco descarta a de nonce 5 e promove a de 7.
+	nonces[privKey.PublicKey().Address()] = 7
+	p.RemoveIncluded(randomBlockWithTxs(t, 0))
+	assert.Len(t, p.Pending(), 1)
+	assert.Equal(t, uint64(7), p.Pending()[0].Nonce)
+	assert.Empty(t, p.Queued())
+}
+
+func TestTxPoolEvictsQueuedFirst(t *testing.T) {
+	p := NewTxPoolWithOpts(TxPoolOpts{MaxCount: 2})
+	privKey := crypto.GeneratePrivateKey()
+
+	pending := signedTxWithNonce(t, privKey, 0, 0)
+	queued := signedTxWithNonce(t, privKey, 2, 0)
+	pending.SetFirstSeen(2)
+	queued.SetFirstSeen(1)
+	assert.Nil(t, p.Add(pending))
+	assert.Nil(t, p.Add(queued))
+
+	assert.Nil(t, p.Add(signedTxWithNonce(t, crypto.GeneratePrivateKey(), 0, 0)))
+	assert.True(t, p.Has(pending.Hash(core.TxHasher{})))
+	assert.False(t, p.Has(queued.Hash(core.TxHasher{})))
+}
+
+func signedTx(t *testing.T, privKey crypto.PrivateKey, data string, fee uint64) *core.Transaction {
+	tx := core.NewTransaction([]byte(data))
+	tx.Fee = fee
+	assert.Nil(t, tx.Sign(privKey))
+	return tx
+}
+
+func signedTxWithNonce(t *testing.T, privKey crypto.PrivateKey, nonce, fee uint64) *core.Transaction {
+	tx := core.NewTransaction([]byte(fmt.Sprintf("%s-%d-%d", types.RandomHash(), nonce, fee)))
+	tx.Nonce = nonce
+	tx.Fee = fee
+	assert.Nil(t, tx.Sign(privKey))
+	return tx
+}
+
+func TestTxPoolReinjectsOrphanedTransactions(t *testing.T) {
+	genesis := chainBlock(t, 0, types.Hash{})
+	bc, err := core.NewBlockchain(genesis)
+	assert.Nil(t, err)
+
+	// Um pool lê os nonces da blockchain e o outro os acompanha pelos eventos.
+	withSource := NewTxPoolWithOpts(TxPoolOpts{Nonces: bc})
+	tracking := NewTxPool()
+	bc.Subscribe(withSource.HandleChainEvent)
+	bc.Subscribe(tracking.HandleChainEvent)
+
+	alice, bob, carol := crypto.GeneratePrivateKey(), crypto.GeneratePrivateKey(), crypto.GeneratePrivateKey()
+	orphaned := signedTxWithNonce(t, alice, 0, 10)
+	both := signedTxWithNonce(t, bob, 0, 10)    // Incluída nos dois ramos
+	spent := signedTxWithNonce(t, carol, 0, 10) // Conflita com conflicting no novo ramo
+	conflicting := signedTxWithNonce(t, carol, 0, 20)
+	pending := signedTxWithNonce(t, bob, 1, 10) // No pool, incluída só no novo ramo
+
+	for _, p := range []*TxPool{withSource, tracking} {
+		assert.Nil(t, p.Add(orphaned))
+		assert.Nil(t, p.Add(both))
+		assert.Nil(t, p.Add(spent))
+	}
+
+	a1 := chainBlock(t, 1, genesis.Hash(core.BlockHasher{}), orphaned, both, spent)
+	assert.Nil(t, bc.AddBlock(a1))
+	for _, p := range []*TxPool{withSource, tracking} {
+		assert.Equal(t, 0, p.Len())
+		assert.Nil(t, p.Add(pending))
+	}
+
+	b1 := chainBlock(t, 1, genesis.Hash(core.BlockHasher{}), both, conflicting)
+	b2 := chainBlock(t, 2, b1.Hash(core.BlockHasher{}), pending)
+	assert.Nil(t, bc.AddBlock(b1))
+	assert.Nil(t, bc.AddBlock(b2))
+	assert.Equal(t, uint32(2), bc.Height())
+
+	for _, p := range []*TxPool{withSource, tracking} {
+		assert.Equal(t, 1, p.Len())
+		assert.True(t, p.Has(orphaned.Hash(core.TxHasher{})))
+		assert.Len(t, p.Pending(), 1)
+	}
+}
+
+func chainBlock(t *testing.T, height uint32, prev types.Hash, txx ...*core.Transaction) *core.Block {
+	b := core.NewBlock(&core.Header{
+		Version:       1,
+		PrevBlockHash: prev,
+		Height:        height,
+		Timestamp:     uint64(time.Now().UnixNano()),
+	}, nil)
+	for _, tx := range txx {
+		b.AddTransaction(tx)
+	}
+	assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+	return b
+}
+
+func TestTxPoolPrunesExpired(t *testing.T) {
+	p := NewTxPool()
+
+	byHeight := randomTx(t, "height")
+	byHeight.ValidUntilHeight = 2
+	byDeadline := randomTx(t, "deadline")
+	byDeadline.Deadline = uint64(time.Now().Add(time.Hour).UnixNano())
+	forever := randomTx(t, "forever")
+	for _, tx := range []*core.Transaction{byHeight, byDeadline, forever} {
+		assert.Nil(t, p.Add(tx))
+	}
+
+	p.RemoveIncluded(chainBlock(t, 1, types.Hash{}))
+	assert.Equal(t, 3, p.Len())
+
+	// Depois do bloco 2, a transação válida até a altura 2 não pode mais ser incluída.
+	p.RemoveIncluded(chainBlock(t, 2, types.Hash{}))
+	assert.Equal(t, 2, p.Len())
+	assert.False(t, p.Has(byHeight.Hash(core.TxHasher{})))
+
+	late := chainBlock(t, 3, types.Hash{})
+	late.Timestamp = byDeadline.Deadline + 1
+	p.RemoveIncluded(late)
+	assert.Equal(t, []*core.Transaction{forever}, p.Transactions())
+
+	expired := randomTx(t, "expired")
+	expired.ValidUntilHeight = 3
+	assert.ErrorIs(t, p.Add(expired), ErrTxExpired)
+}
+
+func TestTxPoolRequiresWork(t *testing.T) {
+	p := NewTxPoolWithOpts(TxPoolOpts{MaxCount: 4, WorkBits: 8, CongestionWorkBits: 4})
+	assert.Equal(t, 8, p.RequiredWorkBits())
+
+	lazy := randomTx(t, "lazy")
+	for lazy.WorkBits() >= 8 {
+		lazy.Stamp++
+	}
+	assert.ErrorIs(t, p.Add(lazy), ErrInsufficientWork)
+
+	for i := 0; i < 2; i++ {
+		tx := randomTx(t, strconv.Itoa(i))
+		assert.Nil(t, tx.Mine(context.Background(), p.RequiredWorkBits()))
+		assert.Nil(t, p.Add(tx))
+	}
+
+	// Com o pool pela metade, a exigência sobe metade de CongestionWorkBits.
+	assert.Equal(t, 10, p.RequiredWorkBits())
+	tx := randomTx(t, "congested")
+	for bits := tx.WorkBits(); bits < 8 || bits >= 10; bits = tx.WorkBits() {
+		tx.Stamp++
+	}
+	assert.ErrorIs(t, p.Add(tx), ErrInsufficientWork)
+
+	// Uma configuração exagerada não exige mais bits do que o hash tem.
+	p = NewTxPoolWithOpts(TxPoolOpts{WorkBits: 300})
+	assert.Equal(t, core.MaxWorkBits, p.RequiredWorkBits())
+}
+
+func TestTxPoolSamePayloadFromDifferentSenders(t *testing.T) {
+	p := NewTxPool()
+	a, b := randomTx(t, "same payload"), randomTx(t, "same payload")
+	assert.Nil(t, p.Add(a))
+	assert.Nil(t, p.Add(b))
+	assert.Equal(t, 2, p.Len())
+}